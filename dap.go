@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/tiagolobocastro/gones/lib/dap"
+)
+
+// debugAdapter serves a single Debug Adapter Protocol session, over stdio or
+// a TCP connection. Returns the exit code
+func debugAdapter(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	listen := flags.String("listen", "", "address to listen on, eg: localhost:4711, instead of stdio")
+	_ = flags.Parse(args)
+
+	var rw io.ReadWriter
+	if *listen == "" {
+		rw = struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}
+		// the protocol owns stdout, anything else printed goes to stderr
+		os.Stdout = os.Stderr
+	} else {
+		l, err := net.Listen("tcp", *listen)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Failed to listen, err=%v\n", err)
+			return 1
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Listening for a debug adapter client on %s\n", l.Addr())
+		conn, err := l.Accept()
+		_ = l.Close()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Failed to accept the client, err=%v\n", err)
+			return 1
+		}
+		defer conn.Close()
+		rw = conn
+	}
+
+	if err := dap.Serve(rw); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Debug session failed, err=%v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+	"github.com/tiagolobocastro/gones/lib/disasm"
+	"github.com/tiagolobocastro/gones/lib/mappers"
+	"github.com/tiagolobocastro/gones/lib/symbols"
+)
+
+// disassemble prints a PRG bank of a rom, the flags can come before or after
+// the rom. Returns the exit code
+func disassemble(args []string) int {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	bank := flags.Int("bank", 0, "PRG bank to disassemble")
+	bankSize := flags.Int("banksize", 16, "PRG bank size in KB")
+	org := flags.String("org", "", "address the bank is mapped at (default $C000 for the last 16 KB bank, $8000 otherwise)")
+	symbolsPath := flags.String("symbols", "", "ld65 debug file, Mesen .mlb or FCEUX .nl file with the labels (default all the symbol files next to the rom)")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage of gones disasm: rom [flags]\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 1
+	}
+	rom := flags.Arg(0)
+	_ = flags.Parse(flags.Args()[1:])
+
+	fail := func(format string, a ...interface{}) int {
+		_, _ = fmt.Fprintf(os.Stderr, format+"\n", a...)
+		return 1
+	}
+
+	prg, offset, err := mappers.ReadPrgRom(rom)
+	if err != nil {
+		return fail("Failed to read the rom, err=%v", err)
+	}
+	if *bankSize <= 0 {
+		return fail("Invalid bank size %d KB", *bankSize)
+	}
+	size := *bankSize * 1024
+	banks := len(prg) / size
+	if *bank < 0 || *bank >= banks {
+		return fail("Invalid bank %d, the rom has %d banks of %d KB", *bank, banks, *bankSize)
+	}
+
+	base := uint16(0x8000)
+	if size == 0x4000 && *bank == banks-1 {
+		base = 0xC000
+	}
+	if *org != "" {
+		addr, err := strconv.ParseUint(strings.Replace(*org, "$", "0x", 1), 0, 16)
+		if err != nil {
+			return fail("Invalid org %q", *org)
+		}
+		base = uint16(addr)
+	}
+
+	info, err := symbols.Load(rom)
+	if *symbolsPath != "" {
+		info, err = symbols.LoadFile(*symbolsPath, rom)
+	}
+	if err != nil {
+		return fail("Failed to load the symbols, err=%v", err)
+	}
+	var labels disasm.Labels
+	if info != nil {
+		labels = info
+	}
+
+	d := disasm.New(cpu.Variant2A03, labels)
+	for _, line := range d.Disassemble(disasm.Block{
+		Code:   prg[*bank*size : (*bank+1)*size],
+		Org:    base,
+		Bank:   *bank,
+		Offset: offset + *bank*size,
+	}) {
+		if line.Label != "" {
+			fmt.Printf("%s:\n", line.Label)
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
@@ -26,6 +26,19 @@ const (
 	bD  = 1 << 4
 )
 
+// Status Registers Interrupt bits
+// IF-- ---- DMC interrupt (I), frame interrupt (F)
+const (
+	bF = 1 << 6
+	bI = 1 << 7
+)
+
+// The frame counter and the DMC drive their own IRQ lines
+const (
+	IntFrameIRQ = cpu.CpuIntIRQ << (1 + iota)
+	IntDmcIRQ
+)
+
 func (a *Apu) writeStatusReg() {
 	a.pulse1.Enable((a.status.Val & bP1) != 0)
 	a.pulse2.Enable((a.status.Val & bP2) != 0)
@@ -33,6 +46,7 @@ func (a *Apu) writeStatusReg() {
 	a.noise.Enable((a.status.Val & bN) != 0)
 	a.dmc.Enable((a.status.Val & bD) != 0)
 	// Clear the DMC interrupt flag
+	a.dmc.ClearIrq()
 }
 func (a *Apu) readStatusReg() uint8 {
 	status := uint8(0)
@@ -51,16 +65,31 @@ func (a *Apu) readStatusReg() uint8 {
 	if a.dmc.Enabled() {
 		status |= bD
 	}
+	if a.frameIrq {
+		status |= bF
+	}
+	if a.dmc.Irq() {
+		status |= bI
+	}
 	// Reading this register clears the frame interrupt flag
 	// (but not the DMC interrupt flag).
 	// If an interrupt flag was set at the same moment of the read, it will
 	// read back as 1 but it will not be cleared.
+	a.frameIrq = false
+	a.interrupts.Clear(IntFrameIRQ)
 	return status
 }
 
+// Audio generated by the cartridge (eg: Namco 163 wavetable channels)
+// which is mixed together with the APU channels
+type ExpansionAudio interface {
+	Sample() float64
+}
+
 type Apu struct {
 	common.BusInt
 	interrupts common.IiInterrupt
+	expansion  ExpansionAudio
 
 	pulse1 waves.Pulse
 	pulse2 waves.Pulse
@@ -79,6 +108,7 @@ type Apu struct {
 	frameStep    uint
 	frameMode    uint
 	frameIrqEn   bool
+	frameIrq     bool
 
 	logAudio      bool
 	samples       uint
@@ -98,7 +128,7 @@ func (a *Apu) Serialise(s common.Serialiser) error {
 	return s.Serialise(
 		&a.pulse1, &a.pulse2, &a.triangle, &a.noise, &a.dmc,
 		a.clock, a.enabled, a.frameCounter, a.frameStep, a.frameMode, a.frameIrqEn,
-		a.status, a.sampleTicks, a.sampleTargetTicks, a.samples, a.samplesTotal,
+		a.frameIrq, a.status, a.sampleTicks, a.sampleTargetTicks, a.samples, a.samplesTotal,
 		a.sampleLogTime,
 	)
 }
@@ -106,7 +136,7 @@ func (a *Apu) DeSerialise(s common.Serialiser) error {
 	return s.DeSerialise(
 		&a.pulse1, &a.pulse2, &a.triangle, &a.noise, &a.dmc,
 		&a.clock, &a.enabled, &a.frameCounter, &a.frameStep, &a.frameMode, &a.frameIrqEn,
-		&a.status, &a.sampleTicks, &a.sampleTargetTicks, &a.samples, &a.samplesTotal,
+		&a.frameIrq, &a.status, &a.sampleTicks, &a.sampleTargetTicks, &a.samples, &a.samplesTotal,
 		&a.sampleLogTime,
 	)
 }
@@ -135,6 +165,7 @@ func (a *Apu) Reset() {
 	a.frameStep = 0
 	a.frameMode = 0
 	a.frameIrqEn = true
+	a.frameIrq = false
 
 	a.status.Initx("status", 0, a.writeStatusReg, a.readStatusReg)
 }
@@ -150,6 +181,9 @@ func (a *Apu) Init(busInt common.BusInt, interrupts common.IiInterrupt, verbose
 
 	a.Reset()
 }
+func (a *Apu) SetExpansionAudio(expansion ExpansionAudio) {
+	a.expansion = expansion
+}
 func (a *Apu) Play() {
 	a.speaker.Play()
 }
@@ -210,6 +244,12 @@ func (a *Apu) tick() {
 		a.pulse2.Tick()
 		a.noise.Tick()
 		a.dmc.Tick()
+		// the dmc holds its IRQ line until acknowledged
+		if a.dmc.Irq() {
+			a.interrupts.Raise(IntDmcIRQ)
+		} else {
+			a.interrupts.Clear(IntDmcIRQ)
+		}
 	}
 	a.triangle.Tick()
 	a.sample()
@@ -228,6 +268,9 @@ func (a *Apu) sample() {
 		dmc := a.dmc.Sample()
 		//dmc := 0.0
 		mix := 0.00851*triangle + 0.00494*noise + 0.00335*dmc + mixPulses
+		if a.expansion != nil {
+			mix += a.expansion.Sample()
+		}
 
 		a.addSample(mix)
 	}
@@ -288,9 +331,12 @@ func (a *Apu) frameTick() {
 		a.frameStep = (a.frameStep + 1) % (a.frameMode + 4)
 	}
 }
+
+// the frame IRQ line is held until $4015 is read or the IRQ is inhibited
 func (a *Apu) raiseIrq() {
 	if a.frameIrqEn {
-		a.interrupts.Raise(cpu.CpuIntIRQ)
+		a.frameIrq = true
+		a.interrupts.Raise(IntFrameIRQ)
 	}
 }
 
@@ -322,6 +368,10 @@ func (a *Apu) Write8(addr uint16, val uint8) {
 	case addr == 0x4017:
 		a.frameMode = uint(val & 0x80)
 		a.frameIrqEn = (val & 0x40) == 0
+		if !a.frameIrqEn {
+			a.frameIrq = false
+			a.interrupts.Clear(IntFrameIRQ)
+		}
 		a.frameStep = 0
 		a.frameCounter = 0
 		if a.frameMode != 0 {
@@ -7,6 +7,7 @@ type Dmc struct {
 
 	// Flags and Rate
 	irqEnable bool
+	irq       bool
 	loopFlag  bool
 	rateTicks uint16
 
@@ -34,6 +35,7 @@ func (d *Dmc) Serialise(s common.Serialiser) error {
 		d.irqEnable, d.loopFlag, d.rateTicks, d.outputLevel, d.sampleAddrRld,
 		d.sampleLenRld, d.sampleBuffer, d.sampleReady, d.sampleAddr, d.sampleLen,
 		d.shiftRegister, d.bitsRemaining, d.silenceFlag, d.clock, d.enabled,
+		d.irq,
 	)
 }
 func (d *Dmc) DeSerialise(s common.Serialiser) error {
@@ -41,6 +43,7 @@ func (d *Dmc) DeSerialise(s common.Serialiser) error {
 		&d.irqEnable, &d.loopFlag, &d.rateTicks, &d.outputLevel, &d.sampleAddrRld,
 		&d.sampleLenRld, &d.sampleBuffer, &d.sampleReady, &d.sampleAddr, &d.sampleLen,
 		&d.shiftRegister, &d.bitsRemaining, &d.silenceFlag, &d.clock, &d.enabled,
+		&d.irq,
 	)
 }
 
@@ -68,6 +71,7 @@ func (d *Dmc) Init(busInt common.BusInt) {
 	d.BusInt = busInt
 
 	d.irqEnable = false
+	d.irq = false
 	d.loopFlag = false
 	d.rateTicks = rateTable()[0] / 2
 	d.outputLevel = 0
@@ -106,6 +110,9 @@ func (d *Dmc) Tick() {
 			// https://wiki.nesdev.com/w/index.php/APU_DMC
 			if d.loopFlag {
 				d.reload()
+			} else if d.sampleLen == 0 && d.irqEnable {
+				// the sample is over
+				d.irq = true
 			}
 		}
 
@@ -141,6 +148,9 @@ func (d *Dmc) Write8(addr uint16, val uint8) {
 	// Flags and Rate
 	case 0x4010:
 		d.irqEnable = (val & 0x80) != 0
+		if !d.irqEnable {
+			d.irq = false
+		}
 		d.loopFlag = (val & 0x40) != 0
 		d.rateTicks = rateTable()[val&0xF] / 2
 
@@ -165,6 +175,12 @@ func (d *Dmc) Sample() float64 {
 	}
 	return 0.0
 }
+func (d *Dmc) Irq() bool {
+	return d.irq
+}
+func (d *Dmc) ClearIrq() {
+	d.irq = false
+}
 func (d *Dmc) Enabled() bool {
 	return d.sampleLen > 0
 }
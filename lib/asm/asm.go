@@ -0,0 +1,501 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// A small two pass assembler, with the ca65 syntax for the instructions, eg:
+//
+//	      .org $C000
+//	ptr = $10
+//	reset:
+//	      ldx #0
+//	loop: lda (ptr),y      ; comments
+//	      sta a:$0000,x     ; a: forces the absolute addressing, z: the zero page
+//	      bne loop
+//	      jmp (vector)
+//	data: .byte 1, "text", <reset, >reset
+//	      .word reset, data+1
+//
+// The operands are expressions with the C operators, labels, decimal, $hex,
+// %binary and 'c' numbers, <expr and >expr for the low and high bytes and *
+// for the address of the current instruction. Operands which are known on
+// the first pass and fit in a byte use the zero page addressing, if there's
+// one
+
+// Segment is the code assembled after each .org
+type Segment struct {
+	Org  uint16
+	Data []uint8
+}
+
+type Program struct {
+	Segments []Segment
+	// the labels and the constants
+	Labels map[string]int
+}
+
+// Load writes the program, eg: to the memory of a test
+func (p *Program) Load(write func(addr uint16, val uint8)) {
+	for _, s := range p.Segments {
+		for i, b := range s.Data {
+			write(s.Org+uint16(i), b)
+		}
+	}
+}
+
+// the 65C02 bit instructions have the bit in their name, eg: RMB3
+var bitNames = map[string]bool{"RMB": true, "SMB": true, "BBR": true, "BBS": true}
+
+type opKey struct {
+	name string
+	mode uint8
+}
+
+// picks the official opcode when there are copies, eg: the NOPs
+func opcodes(variant cpu.Variant) map[opKey]*cpu.Instruction {
+	table := cpu.Instructions(variant)
+	ops := make(map[opKey]*cpu.Instruction)
+	for i := range table {
+		ins := &table[i]
+		name := ins.Name()
+		if bitNames[name] {
+			name += fmt.Sprint(ins.OpCode() >> 4 & 7)
+		}
+		key := opKey{name, ins.AddrMode()}
+		if prev, ok := ops[key]; !ok || prev.Unofficial() && !ins.Unofficial() {
+			ops[key] = ins
+		}
+	}
+	return ops
+}
+
+type stmtKind int
+
+const (
+	stmtNone stmtKind = iota
+	stmtIns
+	stmtByte
+	stmtWord
+	stmtOrg
+	stmtConst
+)
+
+type stmt struct {
+	line    int
+	kind    stmtKind
+	label   string
+	name    string
+	operand string
+	args    []string
+
+	// picked on the first pass
+	ins *cpu.Instruction
+}
+
+type assembler struct {
+	ops    map[opKey]*cpu.Instruction
+	names  map[string]bool
+	labels map[string]int
+	stmts  []*stmt
+	pc     int
+}
+
+// Assemble assembles src for a cpu variant, starting at org unless there's
+// an .org first
+func Assemble(variant cpu.Variant, org uint16, src string) (*Program, error) {
+	a := &assembler{ops: opcodes(variant), names: make(map[string]bool), labels: make(map[string]int)}
+	for key := range a.ops {
+		a.names[key.name] = true
+	}
+	for i, line := range strings.Split(src, "\n") {
+		s, err := parseLine(i+1, line)
+		if err != nil {
+			return nil, err
+		}
+		a.stmts = append(a.stmts, s)
+	}
+
+	a.pc = int(org)
+	for _, s := range a.stmts {
+		if err := a.layout(s); err != nil {
+			return nil, fmt.Errorf("line %d: %v", s.line, err)
+		}
+	}
+
+	p := &Program{Labels: a.labels}
+	a.pc = int(org)
+	for _, s := range a.stmts {
+		if err := a.emit(s, p); err != nil {
+			return nil, fmt.Errorf("line %d: %v", s.line, err)
+		}
+	}
+	return p, nil
+}
+
+// strips the comment, outside of the strings and the chars
+func stripComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '"':
+			quote = c
+		case c == '\'' && i+2 < len(line) && line[i+2] == '\'':
+			i += 2
+		case c == ';':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func isIdent(s string) bool {
+	for i, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func parseLine(n int, line string) (*stmt, error) {
+	s := &stmt{line: n}
+	line = strings.TrimSpace(stripComment(line))
+
+	if colon := strings.IndexByte(line, ':'); colon > 0 && isIdent(line[:colon]) {
+		s.label, line = line[:colon], strings.TrimSpace(line[colon+1:])
+	}
+	if line == "" {
+		return s, nil
+	}
+
+	if eq := strings.IndexByte(line, '='); eq > 0 && isIdent(strings.TrimSpace(line[:eq])) {
+		if s.label != "" {
+			return nil, fmt.Errorf("line %d: a constant can't have a label", n)
+		}
+		s.kind, s.label, s.operand = stmtConst, strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		return s, nil
+	}
+
+	s.name = line
+	if space := strings.IndexAny(line, " \t"); space >= 0 {
+		s.name, s.operand = line[:space], strings.TrimSpace(line[space+1:])
+	}
+
+	switch {
+	case strings.EqualFold(s.name, ".org"):
+		s.kind = stmtOrg
+	case strings.EqualFold(s.name, ".byte"), strings.EqualFold(s.name, ".db"):
+		s.kind = stmtByte
+	case strings.EqualFold(s.name, ".word"), strings.EqualFold(s.name, ".dw"):
+		s.kind = stmtWord
+	case isIdent(s.name):
+		s.kind, s.name = stmtIns, strings.ToUpper(s.name)
+	default:
+		return nil, fmt.Errorf("line %d: unexpected %q", n, s.name)
+	}
+
+	if s.kind == stmtByte || s.kind == stmtWord {
+		s.args = splitArgs(s.operand)
+	}
+	return s, nil
+}
+
+// splits on the commas outside of the strings and the parentheses
+func splitArgs(s string) []string {
+	var args []string
+	depth, quote, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quote = !quote
+		case quote:
+		case c == '\'' && i+2 < len(s) && s[i+2] == '\'':
+			i += 2
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(s[start:]))
+}
+
+func (a *assembler) define(name string, value int) error {
+	if _, ok := a.labels[name]; ok {
+		return fmt.Errorf("%s redefined", name)
+	}
+	a.labels[name] = value
+	return nil
+}
+
+// the first pass, defines the labels and sizes the statements
+func (a *assembler) layout(s *stmt) error {
+	if s.kind == stmtConst {
+		// the forward references are resolved on the second pass
+		if v, err := a.eval(s.operand, false); err == nil {
+			return a.define(s.label, v)
+		}
+		return nil
+	}
+	if s.label != "" {
+		if err := a.define(s.label, a.pc); err != nil {
+			return err
+		}
+	}
+
+	switch s.kind {
+	case stmtOrg:
+		org, err := a.eval(s.operand, true)
+		if err != nil {
+			return err
+		}
+		a.pc = org
+	case stmtByte:
+		for _, arg := range s.args {
+			if str, ok := stringArg(arg); ok {
+				a.pc += len(str)
+			} else {
+				a.pc++
+			}
+		}
+	case stmtWord:
+		a.pc += 2 * len(s.args)
+	case stmtIns:
+		ins, err := a.pick(s)
+		if err != nil {
+			return err
+		}
+		s.ins = ins
+		a.pc += ins.Length()
+	}
+	if a.pc > 0x10000 {
+		return fmt.Errorf("past the end of the address space")
+	}
+	return nil
+}
+
+func stringArg(arg string) (string, bool) {
+	if len(arg) >= 2 && arg[0] == '"' && arg[len(arg)-1] == '"' {
+		return arg[1 : len(arg)-1], true
+	}
+	return "", false
+}
+
+// the addressing mode of the operand syntax, zp and abs are the zero page
+// and absolute modes to choose from
+func syntax(operand string) (expr string, zp uint8, abs uint8) {
+	upper := strings.ToUpper(operand)
+	switch {
+	case operand == "":
+		return "", cpu.ModeImplied, cpu.ModeImplied
+	case upper == "A":
+		return "", cpu.ModeAccumulator, cpu.ModeAccumulator
+	case strings.HasPrefix(operand, "#"):
+		return operand[1:], cpu.ModeImmediate, cpu.ModeImmediate
+	case strings.HasPrefix(operand, "(") && strings.HasSuffix(upper, ",X)"):
+		return operand[1 : len(operand)-3], cpu.ModeIndexedIndirectX, cpu.ModeIndexedAbsoluteIndirectX
+	case strings.HasPrefix(operand, "(") && strings.HasSuffix(upper, "),Y") && closes(operand, len(operand)-3):
+		return operand[1 : len(operand)-3], cpu.ModeIndirectIndexedY, cpu.ModeIndirectIndexedY
+	case strings.HasPrefix(operand, "(") && closes(operand, len(operand)-1):
+		return operand[1 : len(operand)-1], cpu.ModeZeroPageIndirect, cpu.ModeIndirect
+	case strings.HasSuffix(upper, ",X"):
+		return operand[:len(operand)-2], cpu.ModeIndexedZeroPageX, cpu.ModeIndexedAbsoluteX
+	case strings.HasSuffix(upper, ",Y"):
+		return operand[:len(operand)-2], cpu.ModeIndexedZeroPageY, cpu.ModeIndexedAbsoluteY
+	}
+	return operand, cpu.ModeZeroPage, cpu.ModeAbsolute
+}
+
+// whether the parenthesis at i closes the one opening s
+func closes(s string, i int) bool {
+	depth := 0
+	for j := 0; j <= i; j++ {
+		switch s[j] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return j == i
+			}
+		}
+	}
+	return false
+}
+
+// picks the opcode, the expression is evaluated if it's already known
+func (a *assembler) pick(s *stmt) (*cpu.Instruction, error) {
+	if !a.names[s.name] {
+		return nil, fmt.Errorf("unknown instruction %s", s.name)
+	}
+	op := func(mode uint8) *cpu.Instruction {
+		return a.ops[opKey{s.name, mode}]
+	}
+
+	if ins := op(cpu.ModeRelative); ins != nil {
+		return ins, nil
+	}
+	if ins := op(cpu.ModeZeroPageRelative); ins != nil {
+		return ins, nil
+	}
+	if s.operand == "" && op(cpu.ModeImplied) == nil && op(cpu.ModeAccumulator) != nil {
+		return op(cpu.ModeAccumulator), nil
+	}
+
+	expr, zp, abs := syntax(s.operand)
+	forceZp, forceAbs := false, false
+	if strings.HasPrefix(expr, "z:") {
+		expr, forceZp = expr[2:], true
+	} else if strings.HasPrefix(expr, "a:") {
+		expr, forceAbs = expr[2:], true
+	}
+
+	useZp := forceZp
+	if !forceZp && !forceAbs && zp != abs {
+		v, err := a.eval(expr, false)
+		useZp = err == nil && v >= 0 && v < 0x100
+	}
+	if ins := op(zp); ins != nil && (useZp || zp == abs || op(abs) == nil && !forceAbs) {
+		return ins, nil
+	}
+	if ins := op(abs); ins != nil && !forceZp {
+		return ins, nil
+	}
+	return nil, fmt.Errorf("invalid addressing mode for %s %s", s.name, s.operand)
+}
+
+// the second pass
+func (a *assembler) emit(s *stmt, p *Program) error {
+	if s.kind == stmtConst {
+		if _, ok := a.labels[s.label]; !ok {
+			v, err := a.eval(s.operand, true)
+			if err != nil {
+				return err
+			}
+			a.labels[s.label] = v
+		}
+		return nil
+	}
+
+	var data []uint8
+	switch s.kind {
+	case stmtOrg:
+		org, _ := a.eval(s.operand, true)
+		a.pc = org
+		p.Segments = append(p.Segments, Segment{Org: uint16(org)})
+		return nil
+	case stmtByte:
+		for _, arg := range s.args {
+			if str, ok := stringArg(arg); ok {
+				data = append(data, str...)
+				continue
+			}
+			v, err := a.byteValue(arg)
+			if err != nil {
+				return err
+			}
+			data = append(data, v)
+		}
+	case stmtWord:
+		for _, arg := range s.args {
+			v, err := a.wordValue(arg)
+			if err != nil {
+				return err
+			}
+			data = append(data, uint8(v), uint8(v>>8))
+		}
+	case stmtIns:
+		var err error
+		if data, err = a.encode(s); err != nil {
+			return err
+		}
+	}
+
+	if len(data) > 0 {
+		if len(p.Segments) == 0 {
+			p.Segments = append(p.Segments, Segment{Org: uint16(a.pc)})
+		}
+		last := &p.Segments[len(p.Segments)-1]
+		last.Data = append(last.Data, data...)
+	}
+	a.pc += len(data)
+	return nil
+}
+
+func (a *assembler) byteValue(expr string) (uint8, error) {
+	v, err := a.eval(expr, true)
+	if err != nil {
+		return 0, err
+	}
+	if v < -128 || v > 0xFF {
+		return 0, fmt.Errorf("%s = %d doesn't fit in a byte", expr, v)
+	}
+	return uint8(v), nil
+}
+
+func (a *assembler) wordValue(expr string) (uint16, error) {
+	v, err := a.eval(expr, true)
+	if err != nil {
+		return 0, err
+	}
+	if v < -0x8000 || v > 0xFFFF {
+		return 0, fmt.Errorf("%s = %d doesn't fit in a word", expr, v)
+	}
+	return uint16(v), nil
+}
+
+// the branch offset from the next instruction
+func (a *assembler) relative(expr string, next int) (uint8, error) {
+	target, err := a.eval(expr, true)
+	if err != nil {
+		return 0, err
+	}
+	offset := target - next
+	if offset < -128 || offset > 127 {
+		return 0, fmt.Errorf("branch to %s is out of range by %d bytes", expr, offset)
+	}
+	return uint8(offset), nil
+}
+
+func (a *assembler) encode(s *stmt) ([]uint8, error) {
+	ins := s.ins
+	data := []uint8{ins.OpCode()}
+
+	switch ins.AddrMode() {
+	case cpu.ModeImplied, cpu.ModeAccumulator:
+		return data, nil
+	case cpu.ModeRelative:
+		offset, err := a.relative(s.operand, a.pc+2)
+		return append(data, offset), err
+	case cpu.ModeZeroPageRelative:
+		args := splitArgs(s.operand)
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s takes a zero page address and a branch target", s.name)
+		}
+		zp, err := a.byteValue(args[0])
+		if err != nil {
+			return nil, err
+		}
+		offset, err := a.relative(args[1], a.pc+3)
+		return append(data, zp, offset), err
+	}
+
+	expr, _, _ := syntax(s.operand)
+	expr = strings.TrimPrefix(strings.TrimPrefix(expr, "a:"), "z:")
+	if ins.Length() == 2 {
+		v, err := a.byteValue(expr)
+		return append(data, v), err
+	}
+	v, err := a.wordValue(expr)
+	return append(data, uint8(v), uint8(v>>8)), err
+}
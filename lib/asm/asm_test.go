@@ -0,0 +1,148 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+	"github.com/tiagolobocastro/gones/lib/disasm"
+)
+
+func TestAssemble(t *testing.T) {
+	src := `
+ptr = $10
+      .org $C000
+reset:
+      sei                 ; implied
+      ldx #<data          ; immediate
+loop: lda (ptr),y
+      sta a:ptr,x
+      sta $0300,y
+      asl
+      rol a
+      lda ptr+1
+      bne loop
+      jsr sub
+      jmp (vector)
+sub:  lda (ptr,x)
+      ldx var,y
+      rts
+var = $20
+data: .byte 1, "ab", >reset, 'c', -1
+vector:
+      .word reset, data+1
+      .org $FFFC
+      .word reset
+`
+	p, err := Assemble(cpu.Variant2A03, 0, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint8{
+		0x78,
+		0xA2, 0x1D,
+		0xB1, 0x10,
+		0x9D, 0x10, 0x00,
+		0x99, 0x00, 0x03,
+		0x0A,
+		0x2A,
+		0xA5, 0x11,
+		0xD0, 0xF2,
+		0x20, 0x17, 0xC0,
+		0x6C, 0x23, 0xC0,
+		0xA1, 0x10,
+		// var is only known on the second pass
+		0xBE, 0x20, 0x00,
+		0x60,
+		0x01, 'a', 'b', 0xC0, 'c', 0xFF,
+		0x00, 0xC0, 0x1E, 0xC0,
+	}
+	if len(p.Segments) != 2 || p.Segments[0].Org != 0xC000 || p.Segments[1].Org != 0xFFFC {
+		t.Fatalf("segments %+v", p.Segments)
+	}
+	if !bytes.Equal(p.Segments[0].Data, expected) {
+		t.Errorf("assembled % X\n expected % X", p.Segments[0].Data, expected)
+	}
+	if !bytes.Equal(p.Segments[1].Data, []uint8{0x00, 0xC0}) {
+		t.Errorf("vector % X", p.Segments[1].Data)
+	}
+	if p.Labels["sub"] != 0xC017 || p.Labels["var"] != 0x20 {
+		t.Errorf("sub = %X, var = %X", p.Labels["sub"], p.Labels["var"])
+	}
+
+	mem := map[uint16]uint8{}
+	p.Load(func(addr uint16, val uint8) { mem[addr] = val })
+	if len(mem) != len(expected)+2 || mem[0xFFFD] != 0xC0 {
+		t.Errorf("loaded %d bytes", len(mem))
+	}
+}
+
+// the disassembler output assembles back to the same code
+func TestRoundTrip(t *testing.T) {
+	for _, test := range []struct {
+		variant cpu.Variant
+		src     string
+	}{
+		{cpu.Variant2A03, `
+			lda #$05
+			bit $2002
+			lda ($10),y
+			lda a:$0010,y
+			sta $0300,x
+			ldx $10,y
+			inc $10,x
+			asl a
+			lax $20
+			jmp ($FFFC)
+			bne *-16
+			jsr $1234`},
+		{cpu.Variant65C02, `
+			bra *
+			lda ($10)
+			jmp ($0300,x)
+			bbs0 $12, *
+			rmb7 $80
+			inc a
+			stz $10`},
+	} {
+		p, err := Assemble(test.variant, 0x8000, test.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := p.Segments[0].Data
+		var lines []string
+		for _, line := range disasm.New(test.variant, nil).Disassemble(disasm.Block{Code: code, Org: 0x8000, Bank: -1, Offset: -1}) {
+			lines = append(lines, line.Text)
+		}
+		again, err := Assemble(test.variant, 0x8000, strings.Join(lines, "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again.Segments[0].Data, code) {
+			t.Errorf("%s\nassembled to % X\n expected % X", strings.Join(lines, "\n"), again.Segments[0].Data, code)
+		}
+		if n := strings.Count(strings.TrimSpace(test.src), "\n") + 1; len(lines) != n {
+			t.Errorf("%d instructions, expected %d", len(lines), n)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"lda #256", "line 1: 256 = 256 doesn't fit in a byte"},
+		{"nop\nlda (missing),y", "line 2: missing is not defined"},
+		{"a: nop\na: nop", "line 2: a redefined"},
+		{"loop: .org loop + $100\n.byte 0\nbne loop", "line 3: branch to loop is out of range by -259 bytes"},
+		{"stx $1234,x", "line 1: invalid addressing mode for STX $1234,x"},
+		{"bra *", "line 1: unknown instruction BRA"},
+		{"lda #", "line 1: \"\", column 1: missing operand"},
+	} {
+		_, err := Assemble(cpu.Variant2A03, 0, test.src)
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%q failed with %v, expected %s", test.src, err, test.err)
+		}
+	}
+}
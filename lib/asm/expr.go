@@ -0,0 +1,29 @@
+package asm
+
+import (
+	"fmt"
+
+	"github.com/tiagolobocastro/gones/lib/expr"
+)
+
+// eval evaluates an operand, which fails on the first pass if it references
+// labels which are not defined yet
+func (a *assembler) eval(src string, final bool) (int, error) {
+	v, err := expr.Parse(src, expr.Env{Name: func(name string) (expr.Value, error) {
+		if name == "*" {
+			return expr.Const(a.pc), nil
+		}
+		v, ok := a.labels[name]
+		if !ok {
+			if final {
+				return nil, fmt.Errorf("%s is not defined", name)
+			}
+			return nil, fmt.Errorf("%s is not known yet", name)
+		}
+		return expr.Const(v), nil
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return v()
+}
@@ -0,0 +1,158 @@
+package cdl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Code/Data Logger, which marks how every byte of the PRG-ROM and CHR-ROM was
+// used, by its offset in the rom rather than the address it was mapped at.
+// The log is saved in the FCEUX format, a byte per PRG-ROM byte followed by
+// a byte per CHR-ROM byte:
+//
+//	PRG xPdcAADC
+//	    |||||||+- C: executed as code
+//	    ||||||+-- D: read as data
+//	    ||||++--- AA: the 8 KB cpu window it was mapped at, $8000, $A000...
+//	    |||+----- c: jumped to indirectly, eg: JMP ($0200)
+//	    ||+------ d: read indirectly, eg: LDA ($10),Y
+//	    |+------- P: played as a DMC sample
+//
+//	CHR xxxxxxRD
+//	          |+- D: drawn by the PPU
+//	          +-- R: read through $2007
+const (
+	Code         = 0x01
+	Data         = 0x02
+	IndirectCode = 0x10
+	IndirectData = 0x20
+	Sample       = 0x40
+
+	Drawn   = 0x01
+	ChrRead = 0x02
+)
+
+// Logger is driven by the emulation goroutine, the controls and the saving
+// may come from any goroutine
+type Logger struct {
+	mu      sync.Mutex
+	running bool
+	prg     []uint8
+	chr     []uint8
+}
+
+// New logs a rom of prgSize bytes of PRG-ROM and chrSize of CHR-ROM, which is
+// 0 for CHR-RAM
+func New(prgSize int, chrSize int) *Logger {
+	return &Logger{prg: make([]uint8, prgSize), chr: make([]uint8, chrSize)}
+}
+
+func (l *Logger) Start() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running = true
+}
+
+// Stop keeps the log, until a Reset
+func (l *Logger) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running = false
+}
+
+func (l *Logger) Running() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running
+}
+
+// Reset forgets everything logged so far
+func (l *Logger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.prg {
+		l.prg[i] = 0
+	}
+	for i := range l.chr {
+		l.chr[i] = 0
+	}
+}
+
+// window bits of a cpu address
+func window(addr uint16) uint8 {
+	return uint8(addr>>13&3) << 2
+}
+
+// Prg marks the PRG-ROM byte at offset, accessed at a cpu address, with the
+// Code, Data, IndirectCode, IndirectData or Sample flags
+func (l *Logger) Prg(offset int, addr uint16, flags uint8) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running && offset >= 0 && offset < len(l.prg) {
+		l.prg[offset] |= flags | window(addr)
+	}
+}
+
+// Chr marks the CHR-ROM byte at offset with the Drawn or ChrRead flags
+func (l *Logger) Chr(offset int, flags uint8) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running && offset >= 0 && offset < len(l.chr) {
+		l.chr[offset] |= flags
+	}
+}
+
+// Stats counts the bytes logged as code and as data, of the PRG-ROM, and the
+// CHR-ROM bytes drawn or read
+func (l *Logger) Stats() (code, data, chr int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, b := range l.prg {
+		if b&Code != 0 {
+			code++
+		}
+		if b&(Data|Sample) != 0 {
+			data++
+		}
+	}
+	for _, b := range l.chr {
+		if b != 0 {
+			chr++
+		}
+	}
+	return code, data, chr
+}
+
+// WriteTo writes the log in the FCEUX format
+func (l *Logger) WriteTo(w io.Writer) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n, err := w.Write(l.prg)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(l.chr)
+	return int64(n + m), err
+}
+
+// Load merges a log saved before, eg: to carry on from a previous session
+func (l *Logger) Load(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(data) != len(l.prg)+len(l.chr) {
+		return fmt.Errorf("the log has %d bytes, expected %d for this rom", len(data), len(l.prg)+len(l.chr))
+	}
+	for i, b := range data[:len(l.prg)] {
+		l.prg[i] |= b
+	}
+	for i, b := range data[len(l.prg):] {
+		l.chr[i] |= b
+	}
+	return nil
+}
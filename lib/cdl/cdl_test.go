@@ -0,0 +1,64 @@
+package cdl
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	l := New(0x8000, 0x2000)
+	l.Prg(0, 0x8000, Code)
+	if code, _, _ := l.Stats(); code != 0 {
+		t.Fatal("logged before the start")
+	}
+
+	l.Start()
+	l.Prg(0, 0x8000, Code)
+	l.Prg(1, 0x8001, Code)
+	l.Prg(0x7FFC, 0xFFFC, Data)
+	l.Prg(0x7FFD, 0xFFFD, Data|IndirectData)
+	l.Prg(0x4000, 0xC000, Sample)
+	// out of the rom, eg: PRG-RAM
+	l.Prg(-1, 0x6000, Data)
+	l.Prg(0x8000, 0x6000, Data)
+	l.Chr(0x10, Drawn)
+	l.Chr(0x10, ChrRead)
+	l.Stop()
+	l.Chr(0x20, Drawn)
+
+	if code, data, chr := l.Stats(); code != 2 || data != 3 || chr != 1 {
+		t.Errorf("%d code, %d data and %d chr bytes", code, data, chr)
+	}
+
+	var buf bytes.Buffer
+	if n, err := l.WriteTo(&buf); err != nil || n != 0xA000 {
+		t.Fatalf("wrote %d bytes, %v", n, err)
+	}
+	log := buf.Bytes()
+	for offset, expected := range map[int]uint8{
+		0:               Code,
+		0x7FFC:          Data | 0x0C,
+		0x7FFD:          Data | IndirectData | 0x0C,
+		0x4000:          Sample | 0x08,
+		0x8000 + 0x0010: Drawn | ChrRead,
+		0x8000 + 0x0020: 0,
+	} {
+		if log[offset] != expected {
+			t.Errorf("$%04X is $%02X, expected $%02X", offset, log[offset], expected)
+		}
+	}
+
+	l.Reset()
+	if code, data, chr := l.Stats(); code+data+chr != 0 {
+		t.Error("logged after the reset")
+	}
+	if err := l.Load(bytes.NewReader(log)); err != nil {
+		t.Fatal(err)
+	}
+	if code, data, chr := l.Stats(); code != 2 || data != 3 || chr != 1 {
+		t.Errorf("loaded %d code, %d data and %d chr bytes", code, data, chr)
+	}
+	if err := l.Load(bytes.NewReader(log[:0x8000])); err == nil {
+		t.Error("expected an error for the log of another rom")
+	}
+}
@@ -35,18 +35,19 @@ func (d *Dma) Active() bool {
 	return d.nBytes > 0
 }
 
+// Ticks runs the transfer, for the cycles the cpu is halted
 func (d *Dma) Ticks(nTicks int) {
 
 	for i := 0; i < nTicks; i++ {
-		d.tick()
+		d.exec()
 	}
 }
 
-func (d *Dma) tick() {
+// Cycle keeps the dma clock in step with the cpu clock, every cpu cycle
+func (d *Dma) Cycle() {
 
 	// clock required for the delay logic
 	d.clock++
-	d.exec()
 }
 
 func (d *Dma) exec() {
@@ -70,8 +71,6 @@ func (d *Dma) exec() {
 				d.nBytes--
 			}
 		}
-	} else {
-		d.delay = true
 	}
 }
 
@@ -79,6 +78,9 @@ func (d *Dma) setupTransfer(cpuAddr uint16) {
 	d.cpuAddr = cpuAddr
 	d.ppuAddr = 0x2004 // OAMDATA
 	d.nBytes = 256
+	// the first cycle halts the cpu, plus another one to align the reads
+	// with the even cycles
+	d.delay = true
 }
 
 func (d *Dma) Read8(addr uint16) uint8 {
@@ -15,6 +15,8 @@ const (
 	// give this one access to the cartridge
 	QuadScreenMirroring
 	QuadScreenMirroringOnly
+	// SingleScreenMirroring uses the lower CIRAM page, this one the upper
+	SingleScreenMirroringUpper
 )
 
 // busInt
@@ -46,6 +48,20 @@ func (n *NameTables) Write8(addr uint16, val uint8) {
 	n.vRam.Write8(addr, val)
 }
 
+// direct access to the internal vRAM (CIRAM) pages, for mappers which drive
+// the CIRAM A10 line themselves or map it as CHR memory
+func (n *NameTables) ReadPage(page uint16, addr uint16) uint8 {
+	return n.vRam.Read8((page%4)*0x400 + addr%0x400)
+}
+func (n *NameTables) WritePage(page uint16, addr uint16, val uint8) {
+	n.vRam.Write8((page%4)*0x400+addr%0x400, val)
+}
+
+// the CIRAM page selected by the current mirroring for a nametable address
+func (n *NameTables) Page(addr uint16) uint16 {
+	return n.decode(addr) / 0x400
+}
+
 func (n *NameTables) decode(addr uint16) uint16 {
 	a := addr
 	addr -= 0x2000
@@ -83,7 +99,9 @@ func (n *NameTables) decode(addr uint16) uint16 {
 	case SingleScreenMirroring:
 		// All nametables refer to the same memory at any given time,
 		// and the mapper directly manipulates CIRAM address bit 10
-		panic("Not implemented")
+		table = 0
+	case SingleScreenMirroringUpper:
+		table = 1
 	case QuadScreenMirroring:
 		// CIRAM is disabled, and the cartridge contains additional VRAM used for all nametables
 		switch table {
@@ -9,9 +9,12 @@ import (
 //	busInt
 type Ram struct {
 	ram []byte
+
+	// the offset of the last read, which tells where a mapper maps an address
+	lastRead int
 }
 
-func (r *Ram) size() int {
+func (r *Ram) Size() int {
 	return len(r.ram)
 }
 
@@ -30,16 +33,32 @@ func (r *Ram) Serialise(s Serialiser) error {
 	return s.Serialise(r.ram)
 }
 func (r *Ram) DeSerialise(s Serialiser) error {
-	r.InitNfill(r.size(), 0)
+	r.InitNfill(r.Size(), 0)
 	return s.DeSerialise(&r.ram)
 }
 
 func (r *Ram) Read8(addr uint16) uint8 {
+	r.lastRead = int(addr)
 	return r.ram[addr]
 }
 func (r *Ram) Write8(addr uint16, val uint8) {
 	r.ram[addr] = val
 }
+func (r *Ram) Read8w(addr uint32) uint8 {
+	r.lastRead = int(addr)
+	return r.ram[addr]
+}
+
+// LastRead returns the offset of the last read since ClearLastRead, or -1
+func (r *Ram) LastRead() int {
+	return r.lastRead
+}
+func (r *Ram) ClearLastRead() {
+	r.lastRead = -1
+}
+func (r *Ram) Write8w(addr uint32, val uint8) {
+	r.ram[addr] = val
+}
 
 func (r *Ram) LoadFromFile(file *os.File) (int, error) {
 	return io.ReadFull(file, r.ram)
@@ -47,6 +66,10 @@ func (r *Ram) LoadFromFile(file *os.File) (int, error) {
 func (r *Ram) SaveToFile(file *os.File) error {
 	return ioutil.WriteFile(file.Name(), r.ram, 0700)
 }
+func (r *Ram) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(r.ram)
+	return int64(n), err
+}
 
 // little endian
 func (r *Ram) Read16(addr uint16) uint16 {
@@ -11,15 +11,37 @@ type Rom struct {
 	rom []byte
 
 	writable bool
+	// the offset of the last read, which tells where a mapper maps an address
+	lastRead int
 }
 
 func (r *Rom) Read8(addr uint16) uint8 {
+	r.lastRead = int(addr)
 	return r.rom[addr]
 }
 func (r *Rom) Read8w(addr uint32) uint8 {
+	r.lastRead = int(addr)
 	return r.rom[addr]
 }
 
+// LastRead returns the offset of the last read since ClearLastRead, or -1
+func (r *Rom) LastRead() int {
+	return r.lastRead
+}
+func (r *Rom) ClearLastRead() {
+	r.lastRead = -1
+}
+
+// Peek8w reads without tracking the offset, see LastRead
+func (r *Rom) Peek8w(addr uint32) uint8 {
+	return r.rom[addr]
+}
+
+// Poke8w writes even if the rom is not writable, eg: to patch it
+func (r *Rom) Poke8w(addr uint32, val uint8) {
+	r.rom[addr] = val
+}
+
 // little endian
 func (r *Rom) Read16(addr uint16) uint16 {
 	return uint16(r.Read8(addr)) | uint16(r.Read8(addr+1))<<8
@@ -55,9 +77,18 @@ func (r *Rom) Init(size int, writable bool) {
 	r.rom = make([]byte, size, size)
 	r.writable = writable
 }
+
+// SetWritable keeps the contents, eg: for a PRG-ROM flashed by the game
+func (r *Rom) SetWritable(writable bool) {
+	r.writable = writable
+}
 func (r *Rom) LoadFromFile(file *os.File) (int, error) {
 	return io.ReadFull(file, r.rom)
 }
+func (r *Rom) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(r.rom)
+	return int64(n), err
+}
 
 // do we even need to since this is rom...?
 func (r *Rom) Serialise(s Serialiser) error {
@@ -0,0 +1,64 @@
+package cpu
+
+// the call stack is tracked from the instructions being run, as the 6502
+// stack mixes the return addresses with any other data. It's shared by the
+// debugger, the profiler and the tracer
+
+// CallFrame is a subroutine or an interrupt handler being run
+type CallFrame struct {
+	// the first instruction of the routine
+	Entry uint16
+	// the JSR, or the instruction the interrupt came before
+	Caller    uint16
+	Interrupt bool
+	// the interrupt is an NMI, or an IRQ or BRK which it hijacked
+	NMI bool
+
+	// the stack pointer on entry, the frame is gone once it's above it
+	sp uint8
+	// tells apart the frames pushed with the same entry and stack pointer
+	id uint64
+}
+
+// deeper stacks are most likely code which never returns, eg: a reset
+const maxCallFrames = 64
+
+type callStack struct {
+	frames []CallFrame
+	nextID uint64
+}
+
+func (s *callStack) push(f CallFrame) {
+	if len(s.frames) == maxCallFrames {
+		s.frames = s.frames[1:]
+	}
+	s.nextID++
+	f.id = s.nextID
+	s.frames = append(s.frames, f)
+}
+
+// drops the frames returned from, called before every instruction
+func (s *callStack) pop(sp uint8) {
+	for len(s.frames) > 0 && sp > s.frames[len(s.frames)-1].sp {
+		s.frames = s.frames[:len(s.frames)-1]
+	}
+}
+
+func (s *callStack) inNmi() bool {
+	for _, f := range s.frames {
+		if f.NMI {
+			return true
+		}
+	}
+	return false
+}
+
+// CallStack returns the routines being run, the innermost first. It's only
+// up to date between instructions, eg: from the OnExec hook
+func (c *Cpu) CallStack() []CallFrame {
+	frames := make([]CallFrame, len(c.calls.frames))
+	for i, f := range c.calls.frames {
+		frames[len(frames)-1-i] = f
+	}
+	return frames
+}
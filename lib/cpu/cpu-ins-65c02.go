@@ -0,0 +1,179 @@
+package cpu
+
+// setupIns65C02 patches the NMOS table with the WDC 65C02 instructions
+// http://www.6502.org/tutorials/65c02opcodes.html
+// The unofficial opcodes are gone, the unused ones are NOPs of various sizes
+func (c *Cpu) setupIns65C02() {
+	for i := 0; i < 16; i++ {
+		op := uint8(i << 4)
+
+		if op != 0xa0 && op&0x10 == 0 {
+			c.addIns2("NOP", op|0x02, 2, 2, 0, ModeImmediate, c.nop)
+		}
+		c.addIns2("NOP", op|0x03, 1, 1, 0, ModeImplied, c.nop)
+		c.addIns2("NOP", op|0x0b, 1, 1, 0, ModeImplied, c.nop)
+
+		bit := uint8(i & 7)
+		if i < 8 {
+			c.addIns2("RMB", op|0x07, 2, 5, 0, ModeZeroPage, c.rmb(bit))
+			c.addIns2("BBR", op|0x0f, 3, 5, 1, ModeZeroPageRelative, c.bbr(bit))
+		} else {
+			c.addIns2("SMB", op|0x07, 2, 5, 0, ModeZeroPage, c.smb(bit))
+			c.addIns2("BBS", op|0x0f, 3, 5, 1, ModeZeroPageRelative, c.bbs(bit))
+		}
+	}
+
+	c.addIns2("ORA", 0x12, 2, 5, 0, ModeZeroPageIndirect, c.ora)
+	c.addIns2("AND", 0x32, 2, 5, 0, ModeZeroPageIndirect, c.and)
+	c.addIns2("EOR", 0x52, 2, 5, 0, ModeZeroPageIndirect, c.eor)
+	c.addIns2("ADC", 0x72, 2, 5, 0, ModeZeroPageIndirect, c.adc)
+	c.addIns2("STA", 0x92, 2, 5, 0, ModeZeroPageIndirect, c.sta)
+	c.addIns2("LDA", 0xb2, 2, 5, 0, ModeZeroPageIndirect, c.lda)
+	c.addIns2("CMP", 0xd2, 2, 5, 0, ModeZeroPageIndirect, c.cmp)
+	c.addIns2("SBC", 0xf2, 2, 5, 0, ModeZeroPageIndirect, c.sbc)
+
+	c.addIns2("TSB", 0x04, 2, 5, 0, ModeZeroPage, c.tsb)
+	c.addIns2("TSB", 0x0c, 3, 6, 0, ModeAbsolute, c.tsb)
+	c.addIns2("TRB", 0x14, 2, 5, 0, ModeZeroPage, c.trb)
+	c.addIns2("TRB", 0x1c, 3, 6, 0, ModeAbsolute, c.trb)
+
+	c.addIns2("BIT", 0x34, 2, 4, 0, ModeIndexedZeroPageX, c.bit)
+	c.addIns2("BIT", 0x3c, 3, 4, 1, ModeIndexedAbsoluteX, c.bit)
+	c.addIns2("BIT", 0x89, 2, 2, 0, ModeImmediate, c.bitImm)
+
+	c.addIns2("NOP", 0x44, 2, 3, 0, ModeZeroPage, c.nop)
+	c.addIns2("NOP", 0x54, 2, 4, 0, ModeIndexedZeroPageX, c.nop)
+	c.addIns2("NOP", 0xd4, 2, 4, 0, ModeIndexedZeroPageX, c.nop)
+	c.addIns2("NOP", 0xf4, 2, 4, 0, ModeIndexedZeroPageX, c.nop)
+	c.addIns2("NOP", 0x5c, 3, 8, 0, ModeAbsolute, c.nop5c)
+	c.addIns2("NOP", 0xdc, 3, 4, 0, ModeAbsolute, c.nop)
+	c.addIns2("NOP", 0xfc, 3, 4, 0, ModeAbsolute, c.nop)
+
+	c.addIns2("STZ", 0x64, 2, 3, 0, ModeZeroPage, c.stz)
+	c.addIns2("STZ", 0x74, 2, 4, 0, ModeIndexedZeroPageX, c.stz)
+	c.addIns2("STZ", 0x9c, 3, 4, 0, ModeAbsolute, c.stz)
+	c.addIns2("STZ", 0x9e, 3, 5, 0, ModeIndexedAbsoluteX, c.stz)
+
+	c.addIns2("INC", 0x1a, 1, 2, 0, ModeAccumulator, c.inc)
+	c.addIns2("DEC", 0x3a, 1, 2, 0, ModeAccumulator, c.dec)
+
+	c.addIns2("PHY", 0x5a, 1, 3, 0, ModeImplied, c.phy)
+	c.addIns2("PLY", 0x7a, 1, 4, 0, ModeImplied, c.ply)
+	c.addIns2("PHX", 0xda, 1, 3, 0, ModeImplied, c.phx)
+	c.addIns2("PLX", 0xfa, 1, 4, 0, ModeImplied, c.plx)
+
+	c.addIns2("BRA", 0x80, 2, 3, 1, ModeRelative, c.bra)
+
+	c.addIns2("JMP", 0x6c, 3, 6, 0, ModeIndirect, c.jmp)
+	c.addIns2("JMP", 0x7c, 3, 6, 0, ModeIndexedAbsoluteIndirectX, c.jmp)
+
+	// the indexed shifts only take the extra cycle on page crossings
+	c.addIns2("ASL", 0x1e, 3, 6, 1, ModeIndexedAbsoluteX, c.asl)
+	c.addIns2("ROL", 0x3e, 3, 6, 1, ModeIndexedAbsoluteX, c.rol)
+	c.addIns2("LSR", 0x5e, 3, 6, 1, ModeIndexedAbsoluteX, c.lsr)
+	c.addIns2("ROR", 0x7e, 3, 6, 1, ModeIndexedAbsoluteX, c.ror)
+
+	c.addIns2("WAI", 0xcb, 1, 3, 0, ModeImplied, c.wai)
+	c.addIns2("STP", 0xdb, 1, 3, 0, ModeImplied, c.stp)
+}
+
+func (c *Cpu) stz() {
+	c.write8(c.getOperandAddr(true), 0)
+}
+
+// Z is set from A & M, the bits of A are then set or reset in M
+func (c *Cpu) tsb() {
+	c.modify(func(v uint8) uint8 {
+		c.Rg.Spc.Ps.Set(BZ, int8(v&c.Rg.Gp.Ac.Read()))
+		return v | c.Rg.Gp.Ac.Read()
+	})
+}
+func (c *Cpu) trb() {
+	c.modify(func(v uint8) uint8 {
+		c.Rg.Spc.Ps.Set(BZ, int8(v&c.Rg.Gp.Ac.Read()))
+		return v &^ c.Rg.Gp.Ac.Read()
+	})
+}
+
+// the immediate BIT only sets the Z flag
+func (c *Cpu) bitImm() {
+	c.Rg.Spc.Ps.Set(BZ, int8(c.Rg.Gp.Ac.Read()&c.operand()))
+}
+
+func (c *Cpu) bra() {
+	c._jumpTo(c.getOperandAddr(false))
+}
+
+func (c *Cpu) phx() {
+	c._push8(c.Rg.Gp.Ix.X.Read())
+}
+func (c *Cpu) phy() {
+	c._push8(c.Rg.Gp.Ix.Y.Read())
+}
+func (c *Cpu) plx() {
+	c._stackRead()
+	c.Rg.Gp.Ix.X.Write(c._pull8())
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ix.X.Read()))
+}
+func (c *Cpu) ply() {
+	c._stackRead()
+	c.Rg.Gp.Ix.Y.Write(c._pull8())
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ix.Y.Read()))
+}
+
+func (c *Cpu) rmb(bit uint8) func() {
+	return func() {
+		c.modify(func(v uint8) uint8 {
+			return v &^ (1 << bit)
+		})
+	}
+}
+func (c *Cpu) smb(bit uint8) func() {
+	return func() {
+		c.modify(func(v uint8) uint8 {
+			return v | 1<<bit
+		})
+	}
+}
+
+// the zero page value is read twice before the branch offset is fetched
+func (c *Cpu) _branchBit(bit uint8, set bool) {
+	zp := uint16(c.fetch8())
+	v := c.read8(zp)
+	c.read8(zp)
+	offset := c.fetch8()
+	if ((v>>bit)&1 != 0) == set {
+		c._jumpTo(c.Rg.Spc.Pc.Read() + uint16(int8(offset)))
+	}
+}
+func (c *Cpu) bbr(bit uint8) func() {
+	return func() {
+		c._branchBit(bit, false)
+	}
+}
+func (c *Cpu) bbs(bit uint8) func() {
+	return func() {
+		c._branchBit(bit, true)
+	}
+}
+
+// the 8 cycle NOP reads $FFxx and then keeps reading $FFFF
+func (c *Cpu) nop5c() {
+	addr := c.fetch16()
+	c.read8(0xFF00 | addr&0xFF)
+	for i := 0; i < 4; i++ {
+		c.read8(0xFFFF)
+	}
+}
+
+// WAI sleeps until an interrupt line is asserted
+func (c *Cpu) wai() {
+	c.dummyRead()
+	c.waiting = true
+}
+
+// STP stops the clock until a reset
+func (c *Cpu) stp() {
+	c.dummyRead()
+	c.halted = true
+}
@@ -3,8 +3,6 @@ package cpu
 import (
 	"fmt"
 	"log"
-	"os"
-	"strings"
 
 	"github.com/tiagolobocastro/gones/lib/common"
 )
@@ -25,8 +23,31 @@ const (
 	ModeRelative
 	ModeIndexedIndirectX
 	ModeIndirectIndexedY
+	// 65C02 only
+	ModeZeroPageIndirect
+	ModeIndexedAbsoluteIndirectX
+	ModeZeroPageRelative
 )
 
+// Variant of the 6502 core
+type Variant int
+
+const (
+	// Ricoh 2A03 (NES), an NMOS 6502 without the decimal mode
+	Variant2A03 Variant = iota
+	// NMOS 6502, with the decimal mode and the unofficial opcodes
+	VariantNMOS
+	// WDC 65C02, the CMOS 6502 with the new instructions, the unused opcodes
+	// are NOPs and the decimal mode sets the flags correctly
+	Variant65C02
+)
+
+// Bus is the memory as seen by the cpu
+type Bus interface {
+	Read8(uint16) uint8
+	Write8(uint16, uint8)
+}
+
 type Instruction struct {
 	opLength     uint8
 	opCycles     uint8
@@ -40,6 +61,8 @@ type Instruction struct {
 	eval func()
 	// because can't compare fun() with cpu.unhandled
 	implemented bool
+	// marked with a '*' in the traces
+	unofficial bool
 }
 
 type Context struct {
@@ -48,24 +71,30 @@ type Context struct {
 	pgX bool
 }
 
+// The NMI is edge triggered, latched until it's serviced. The IRQ line is
+// level triggered and shared by all the sources, any other flag is an IRQ
+// source which keeps its own line asserted until it's acknowledged
 const (
-	CpuIntNMI = 1
-	CpuIntIRQ = 2
-	// split the IRQ into multiple sources?
+	CpuIntNMI = 1 << iota
+	CpuIntIRQ
+
+	cpuIntIRQs = 0xFF ^ CpuIntNMI
 )
 
 type interrupt struct {
 	flags uint8
 }
 
+// Clock is the rest of the system as seen by the cpu, it is ticked once per
+// cpu cycle right before the bus access of that cycle. Every 6502 cycle does
+// exactly one access, even if it's a dummy one
+type Clock interface {
+	// read is false on the write cycles, the cpu can only be halted on reads
+	CpuCycle(read bool)
+}
+
 // interrupt
 func (c *Cpu) Raise(flag uint8) {
-	switch flag {
-	case CpuIntIRQ:
-		if (c.Rg.Spc.Ps.Read() & BI) == BI {
-			return
-		}
-	}
 	c.interrupts |= flag
 }
 
@@ -73,10 +102,18 @@ func (c *Cpu) Clear(flag uint8) {
 	c.interrupts &= flag ^ 0xFF
 }
 
+// Halted reports whether the cpu is jammed by a KIL (or stopped by a STP)
+func (c *Cpu) Halted() bool {
+	return c.halted
+}
+
 type Cpu struct {
-	common.BusExtInt
+	Bus
 	interrupt
 
+	clock   Clock
+	variant Variant
+
 	ins [256]Instruction
 
 	curr Context
@@ -86,60 +123,83 @@ type Cpu struct {
 	clk      int
 	clkExtra int
 
-	verbose      bool
-	disableBreak bool
+	verbose bool
 
 	inInt      bool
 	interrupts uint8
-
-	// a bit messy but ok for now
-	f *os.File
-
-	// internal buffer to make logging compatible with previous fmt.print*
-	bufStr string
-}
-
-func (c *Cpu) Init(busInt common.BusExtInt, verbose bool) {
+	// the interrupts polled on the last two cycles, the instruction is
+	// followed by an interrupt if it was polled on its penultimate cycle
+	runNmi     bool
+	prevRunNmi bool
+	runIrq     bool
+	prevRunIrq bool
+
+	// set by KIL, cleared on reset
+	halted bool
+	// set by WAI, cleared by an interrupt
+	waiting bool
+
+	// instruction trace, see Trace and SetTracer
+	tracer    *Tracer
+	tracePeek func(uint16) uint8
+	tracePpu  func() (scanline, dot int)
+	// names the addresses, see TraceLabels
+	traceLabels func(addr uint16) (string, bool)
+
+	// called before every instruction, see OnExec
+	onExec func(pc uint16)
+
+	// nil unless profiling, see Profile
+	profiler *Profiler
+
+	// the routines being run, see calls.go
+	calls callStack
+}
+
+// Init sets up the core, the clock is optional and the verbose logs go
+// through the standard logger
+func (c *Cpu) Init(bus Bus, clock Clock, variant Variant, verbose bool) {
 	c.verbose = verbose
-	c.disableBreak = true
+	c.variant = variant
 
 	c.Rg.Init()
-	c.setupIns()
-
-	c.BusExtInt = busInt
+	c.setupTable()
 
-	if !c.verbose {
-		// set log to stdout just in case we change it during debugging
-		log.SetOutput(os.Stdout)
-		return
-	}
+	c.Bus = bus
+	c.clock = clock
+}
 
-	f, err := os.OpenFile("log.log", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		log.Fatalf("error opening file: %v", err)
-	}
-	c.f = f
-	//wrt := io.MultiWriter(os.Stdout, f)
-	log.SetOutput(f)
+func (c *Cpu) Variant() Variant {
+	return c.variant
+}
+func (c *Cpu) cmos() bool {
+	return c.variant == Variant65C02
 }
 
 func (c *Cpu) Reset() {
 	c.Rg.Init()
 	c.inInt = false
 	c.interrupts = 0
-	c.Rg.Spc.Pc.Write(c.Read16(0xFFFC))
+	c.runNmi, c.prevRunNmi = false, false
+	c.runIrq, c.prevRunIrq = false, false
+	c.halted = false
+	c.waiting = false
+	c.calls.frames = c.calls.frames[:0]
+	c.Rg.Spc.Pc.Write(c.peek16(0xFFFC))
 	c.curr.ins = nil
 }
 
 func (c *Cpu) Serialise(s common.Serialiser) error {
 	return s.Serialise(
-		c.clk, c.clkExtra, c.disableBreak, c.inInt, c.interrupts,
+		c.clk, c.clkExtra, c.inInt, c.interrupts, c.halted, c.waiting,
+		c.runNmi, c.prevRunNmi, c.runIrq, c.prevRunIrq,
 		c.Rg, c.curr.opr, c.curr.pgX,
 	)
 }
 func (c *Cpu) DeSerialise(s common.Serialiser) error {
 	return s.DeSerialise(
-		&c.clk, &c.clkExtra, &c.disableBreak, &c.inInt, &c.interrupts,
+		&c.clk, &c.clkExtra, &c.inInt, &c.interrupts, &c.halted, &c.waiting,
+		&c.runNmi, &c.prevRunNmi, &c.runIrq, &c.prevRunIrq,
 		&c.Rg, &c.curr.opr, &c.curr.pgX,
 	)
 }
@@ -171,251 +231,342 @@ func (c *Cpu) Log(a ...interface{}) {
 	}
 }
 
-func (c *Cpu) LogAf(align int, format string, a ...interface{}) {
-	if c.verbose {
-		s := fmt.Sprintf(format, a...)
-		n := len(s)
-		if align > n {
-			for i := n; i < align; i++ {
-				s = fmt.Sprintf("%s ", s)
-			}
-		}
-		c.bufStr += s
-
-		if strings.IndexByte(s, '\n') > 0 {
-			log.Printf(c.bufStr)
-			c.bufStr = ""
-		}
-	}
+// the interrupt lines are sampled at the end of every cycle
+func (c *Cpu) poll() {
+	c.prevRunNmi, c.prevRunIrq = c.runNmi, c.runIrq
+	c.runNmi = (c.interrupts & CpuIntNMI) != 0
+	c.runIrq = (c.interrupts&cpuIntIRQs) != 0 && (c.Rg.Spc.Ps.Read()&BI) == 0
 }
 
-func (c *Cpu) Logf(format string, a ...interface{}) {
-	if c.verbose {
-		s := fmt.Sprintf(format, a...)
-		c.bufStr += s
+// The interrupt sequence shared by BRK, IRQ and NMI, after the first two
+// cycles. The vector is only picked after the PC is pushed so an NMI polled
+// by then hijacks a BRK or IRQ, which then runs the NMI handler with their
+// own status pushed. Only BRK pushes the B flag
+func (c *Cpu) _interrupt(brk bool) {
+	caller := c.Rg.Spc.Pc.Read()
+	c._push16(caller)
 
-		if strings.IndexByte(s, '\n') > 0 {
-			log.Print(c.bufStr)
-			c.bufStr = ""
-		}
+	vector := uint16(0xFFFE)
+	if c.runNmi {
+		vector = 0xFFFA
+		c.Clear(CpuIntNMI)
+		c.runNmi = false
 	}
-}
 
-func (c *Cpu) nmi() {
-	c.Clear(CpuIntNMI)
-	c._push16(c.Rg.Spc.Pc.Read())
-	c.php()
-	c.Rg.Spc.Pc.Write(c.Read16(0xFFFA))
-	c.inInt = true
+	ps := c.Rg.Spc.Ps.Read() | BE
+	if brk {
+		ps |= BB
+	} else {
+		ps &^= BB
+	}
+	c._push8(ps)
 	c.Rg.Spc.Ps.Set(BI, BI)
-	c.clk += 7
-}
-func (c *Cpu) irq() {
-	c.Clear(CpuIntIRQ)
-	c._push16(c.Rg.Spc.Pc.Read())
-	c.php()
-	c.Rg.Spc.Pc.Write(c.Read16(0xFFFE))
+	if c.cmos() {
+		// the 65C02 also leaves the decimal mode
+		c.Rg.Spc.Ps.Set(BD, 0)
+	}
+
+	c.Rg.Spc.Pc.Write(c.read16(vector))
 	c.inInt = true
-	c.Rg.Spc.Ps.Set(BI, BI)
-	c.clk += 7
+
+	if brk {
+		// the BRK pushes the address after its padding byte
+		caller -= 2
+	}
+	c.calls.push(CallFrame{
+		Entry: c.Rg.Spc.Pc.Read(), Caller: caller,
+		Interrupt: true, NMI: vector == 0xFFFA, sp: c.Rg.Spc.Sp.Read(),
+	})
+}
+
+// hardware interrupts read the next opcode and discard it, the PC is not
+// incremented
+func (c *Cpu) interruptRequest() {
+	c.dummyRead()
+	c.dummyRead()
+	c._interrupt(false)
 }
 
+// Tick runs a single instruction and returns the number of cycles it took,
+// the rest of the system is clocked along on each of those cycles
 func (c *Cpu) Tick() int {
+	if c.tracer != nil && c.tracer.ring != nil {
+		defer c.tracer.dumpOnPanic()
+	}
 
 	clk := c.clk
+	pc := c.Rg.Spc.Pc.Val
 	c.exec()
 	ticks := c.clk - clk
+	if c.profiler != nil {
+		c.profiler.tick(c, pc, ticks)
+	}
 	return ticks
 }
 
-func (c *Cpu) exec() {
-
-	switch c.interrupts {
-	case CpuIntNMI:
-		c.nmi()
-	case CpuIntIRQ:
-		c.irq()
+// Stall runs the clock for a few cycles without running any instruction, the
+// next opcode is read like the reset sequence does
+func (c *Cpu) Stall(cycles int) {
+	for i := 0; i < cycles; i++ {
+		c.dummyRead()
 	}
+}
 
-	c.curr.pgX = false
-	c.curr.opr = c.fetch()
-	opCode := c.curr.opr & 0xFF
-	c.curr.ins = &c.ins[opCode]
-
-	if c.curr.ins.opLength == 0 {
-		c.Logf("Read 0x%02x - %s - which is an invalid instruction!\n", opCode, c.curr.ins.opName)
-		c.Rg.Spc.Pc.Val += uint16(c.curr.ins.opLength)
+// OnExec sets a hook called before each instruction is fetched, eg: for a
+// debugger which may block it until the emulation resumes
+func (c *Cpu) OnExec(hook func(pc uint16)) {
+	c.onExec = hook
+}
 
-		// testing
-		panic(fmt.Errorf("invalid instruction, opcode: 0x%02x", opCode))
+func (c *Cpu) exec() {
 
+	// a jammed cpu ignores the interrupts and keeps reading $FFFF
+	if c.halted {
+		c.read8(0xFFFF)
 		return
 	}
 
-	if c.verbose {
-		c.LogAf(30, "0x%04x: 0x%02x - %s %s", c.Rg.Spc.Pc.Val, opCode, c.curr.ins.opName, c.getOperandString(c.curr.ins))
+	// WAI resumes on any interrupt, even if it's an IRQ with the I flag set
+	if c.waiting {
+		if c.interrupts == 0 {
+			c.dummyRead()
+			return
+		}
+		c.waiting = false
 	}
 
-	c.curr.ins.eval()
-	c.Rg.Spc.Pc.Val += uint16(c.curr.ins.opLength)
+	c.calls.pop(c.Rg.Spc.Sp.Read())
+	if c.prevRunNmi || c.prevRunIrq {
+		c.interruptRequest()
+		return
+	}
 
-	if c.verbose {
-		c.Logf("%s\n", c.Rg)
+	c.curr.pgX = false
+	if c.onExec != nil {
+		c.onExec(c.Rg.Spc.Pc.Val)
+	}
+	if c.tracer != nil {
+		c.tracer.trace(c)
 	}
 
-	if c.curr.ins.opName == "BRK" {
-		// probably need to remove this...
-		return
+	pc := c.Rg.Spc.Pc.Val
+	c.curr.ins = &c.ins[c.fetch8()]
+
+	// the second cycle of the single byte instructions reads the next byte,
+	// except for the 65C02 single cycle NOPs
+	switch c.curr.ins.addrMode {
+	case ModeImplied, ModeAccumulator:
+		if c.curr.ins.opCycles > 1 {
+			c.dummyRead()
+		}
 	}
 
-	c.clk += int(c.curr.ins.opCycles)
-	if c.curr.pgX {
-		c.clk += int(c.curr.ins.opPageCycles)
+	c.curr.ins.eval()
+	if c.halted {
+		// KIL leaves the PC on the opcode
+		c.Rg.Spc.Pc.Write(pc)
+		if c.tracer != nil {
+			_ = c.tracer.Dump(fmt.Sprintf("%s at $%04X", c.curr.ins.opName, pc))
+		}
 	}
 }
 
-func (c *Cpu) fetch() uint32 {
-	op01 := c.Read16(c.Rg.Spc.Pc.Val)
-	op2 := c.Read8(c.Rg.Spc.Pc.Val + 2)
-	return uint32(op01) | uint32(op2)<<16
+// reads without touching the clock
+func (c *Cpu) peek16(addr uint16) uint16 {
+	return uint16(c.Read8(addr)) | uint16(c.Read8(addr+1))<<8
 }
 
-func (c *Cpu) brk() {
-
-	if c.disableBreak {
-		return
+// Bus accesses, one per cycle
+func (c *Cpu) cycle(read bool) {
+	c.clk++
+	if c.clock != nil {
+		c.clock.CpuCycle(read)
 	}
+}
+func (c *Cpu) read8(addr uint16) uint8 {
+	c.cycle(true)
+	v := c.Read8(addr)
+	c.poll()
+	return v
+}
+func (c *Cpu) read16(addr uint16) uint16 {
+	l := uint16(c.read8(addr))
+	return l | uint16(c.read8(addr+1))<<8
+}
+func (c *Cpu) write8(addr uint16, val uint8) {
+	c.cycle(false)
+	c.Write8(addr, val)
+	c.poll()
+}
 
-	c.Rg.Spc.Ps.Set(BB, BB)
-	// The BRK instruction forces the generation of an interrupt request.
-	// The program counter and processor status are pushed on the stack
-	// then the IRQ interrupt vector at $FFFE/F is loaded into the PC and the break flag
-	// in the status set to one.
-
-	c._push16(c.Rg.Spc.Pc.Read())
-	c.php()
-	c.sei()
+// reads the byte at the PC and moves past it
+func (c *Cpu) fetch8() uint8 {
+	v := c.read8(c.Rg.Spc.Pc.Val)
+	c.Rg.Spc.Pc.Val++
+	return v
+}
+func (c *Cpu) fetch16() uint16 {
+	l := uint16(c.fetch8())
+	return l | uint16(c.fetch8())<<8
+}
 
-	// needs more work, don't really understand it yet...
-	c.Rg.Spc.Pc.Write(c.Read16(0xFFFE))
+// the cpu reads the next instruction byte on the cycles without an useful
+// access, the value is discarded
+func (c *Cpu) dummyRead() {
+	c.read8(c.Rg.Spc.Pc.Val)
 }
 
-func (c *Cpu) getOperandString(ins *Instruction) string {
-	op1 := uint16(c.curr.opr&0xFF00) >> 8
-	op12 := uint16((c.curr.opr & 0xFFFF00) >> 8)
-	str := ""
-	switch ins.addrMode {
-	case ModeImplied:
-	case ModeAccumulator:
-	case ModeImmediate:
-		str = fmt.Sprintf("#$%02x", op1)
-	case ModeZeroPage:
-		str = fmt.Sprintf("$%02x", op1)
-	case ModeIndexedZeroPageX:
-		str = fmt.Sprintf("$%02x, X", op1)
-	case ModeIndexedZeroPageY:
-		str = fmt.Sprintf("$%02x, Y", op1)
-	case ModeAbsolute:
-		str = fmt.Sprintf("$%04x", op12)
-	case ModeIndexedAbsoluteX:
-		str = fmt.Sprintf("$%04x, X", op12)
-	case ModeIndexedAbsoluteY:
-		str = fmt.Sprintf("$%04x, Y", op12)
-	case ModeIndexedIndirectX:
-		str = fmt.Sprintf("($%04x, X)", op12)
-	case ModeIndirectIndexedY:
-		str = fmt.Sprintf("($%04x, Y)", op12)
-	case ModeIndirect:
-		str = fmt.Sprintf("($%04x)", op12)
-	case ModeRelative:
-		str = fmt.Sprintf("#$%02x", op1)
-	case ModeInvalid:
-		fallthrough
-	default:
-		panic(fmt.Sprintf("invalid address mode: %d", ins.addrMode))
-	}
-	return str
+// The BRK instruction forces the generation of an interrupt request.
+// The program counter and processor status are pushed on the stack
+// then the IRQ interrupt vector at $FFFE/F is loaded into the PC, the pushed
+// status has the break flag set. The byte after the BRK is skipped
+func (c *Cpu) brk() {
+	c.Rg.Spc.Pc.Val++
+	c._interrupt(true)
 }
 
 func pageCrossed(a, b uint16) bool {
 	return a&0xFF00 != b&0xFF00
 }
 
-func (c *Cpu) getOperandAddr(ins *Instruction) uint16 {
-	op1 := uint16(c.curr.opr&0xFF00) >> 8
-	op12 := uint16((c.curr.opr & 0xFFFF00) >> 8)
-	switch ins.addrMode {
+// getOperandAddr fetches the operand and returns its effective address, doing
+// every bus access of the addressing mode on its own cycle.
+// The indexed modes first read from the address before the page fixup, which
+// is already the operand unless a page was crossed, write is set for the
+// stores and read-modify-write instructions which always do the fixup read
+func (c *Cpu) getOperandAddr(write bool) uint16 {
+	switch c.curr.ins.addrMode {
 	case ModeImmediate:
-		return c.Rg.Spc.Pc.Read() + 1
+		addr := c.Rg.Spc.Pc.Read()
+		c.Rg.Spc.Pc.Val++
+		return addr
 	case ModeZeroPage:
-		return op1
+		return uint16(c.fetch8())
 	case ModeIndexedZeroPageX:
-		return (op1 + uint16(c.Rg.Gp.Ix.X.Read())) % 256
+		return c.zeroPageIndexed(c.Rg.Gp.Ix.X.Read())
 	case ModeIndexedZeroPageY:
-		return (op1 + uint16(c.Rg.Gp.Ix.Y.Read())) % 256
+		return c.zeroPageIndexed(c.Rg.Gp.Ix.Y.Read())
 	case ModeAbsolute:
-		return op12
+		return c.fetch16()
 	case ModeIndexedAbsoluteX:
-		x := uint16(c.Rg.Gp.Ix.X.Read())
-		addr := op12 + x
-		c.curr.pgX = pageCrossed(addr-x, addr)
-		return addr
+		return c.indexed(c.fetch16(), c.Rg.Gp.Ix.X.Read(), write)
 	case ModeIndexedAbsoluteY:
-		y := uint16(c.Rg.Gp.Ix.Y.Read())
-		addr := op12 + y
-		c.curr.pgX = pageCrossed(addr-y, addr)
-		return addr
+		return c.indexed(c.fetch16(), c.Rg.Gp.Ix.Y.Read(), write)
 	case ModeIndexedIndirectX:
-		return c.Read16(op1 + uint16(c.Rg.Gp.Ix.X.Read()))
+		return c.zeroPage16(uint8(c.zeroPageIndexed(c.Rg.Gp.Ix.X.Read())))
 	case ModeIndirectIndexedY:
-		y := uint16(c.Rg.Gp.Ix.Y.Read())
-		addr := c.Read16(op1) + y
-		c.curr.pgX = pageCrossed(addr-y, addr)
-		return addr
+		return c.indexed(c.zeroPage16(c.fetch8()), c.Rg.Gp.Ix.Y.Read(), write)
 	case ModeIndirect:
 		// http://www.obelisk.me.uk/6502/reference.html#JMP:
 		// An original 6502 has does not correctly fetch the target address if the indirect vector falls on a page boundary
 		// (e.g. $xxFF where xx is any value from $00 to $FF). In this case fetches the LSB from $xxFF as expected but takes
 		// the MSB from $xx00. This is fixed in some later chips like the 65SC02 so for compatibility always ensure the
 		// indirect vector is not at the end of the page.
-		if op1 == 0xFF {
-			l := uint16(c.Read8(op12))
-			h := uint16(c.Read8(op12 & 0xFF00))
-			return l | h<<8
-		} else {
-			return c.Read16(op12)
+		ptr := c.fetch16()
+		if c.cmos() {
+			// fixed on the 65C02, at the cost of an extra cycle
+			c.read8(c.Rg.Spc.Pc.Read() - 1)
+			return c.read16(ptr)
 		}
+		l := uint16(c.read8(ptr))
+		h := uint16(c.read8(ptr&0xFF00 | uint16(uint8(ptr)+1)))
+		return l | h<<8
+	case ModeZeroPageIndirect:
+		return c.zeroPage16(c.fetch8())
+	case ModeIndexedAbsoluteIndirectX:
+		ptr := c.fetch16() + uint16(c.Rg.Gp.Ix.X.Read())
+		c.read8(c.Rg.Spc.Pc.Read() - 1)
+		return c.read16(ptr)
 	case ModeRelative:
 		// op1 -128,127 so we can jump backwards
+		op1 := c.fetch8()
 		return c.Rg.Spc.Pc.Read() + uint16(int8(op1))
 	case ModeInvalid:
 		fallthrough
 	default:
-		panic(fmt.Errorf("invalid instruction address mode: %d", ins.addrMode))
+		panic(fmt.Errorf("invalid instruction address mode: %d", c.curr.ins.addrMode))
+	}
+}
+
+// the base is read while the index is added, wrapping around the zero page
+func (c *Cpu) zeroPageIndexed(index uint8) uint16 {
+	base := c.fetch8()
+	c.read8(uint16(base))
+	return uint16(base + index)
+}
+
+// pointers wrap around the zero page too
+func (c *Cpu) zeroPage16(ptr uint8) uint16 {
+	l := uint16(c.read8(uint16(ptr)))
+	return l | uint16(c.read8(uint16(ptr+1)))<<8
+}
+
+func (c *Cpu) indexed(base uint16, index uint8, write bool) uint16 {
+	addr := base + uint16(index)
+	c.curr.pgX = pageCrossed(base, addr)
+	if c.curr.pgX || write {
+		if c.cmos() {
+			// the 65C02 reads the last instruction byte again instead
+			c.read8(c.Rg.Spc.Pc.Read() - 1)
+		} else {
+			// the high byte is not fixed up yet
+			c.read8(base&0xFF00 | addr&0x00FF)
+		}
+	}
+	return addr
+}
+
+// operand reads the value used by the read instructions
+func (c *Cpu) operand() uint8 {
+	return c.read8(c.getOperandAddr(false))
+}
+
+// modify does the read-modify-write of the operand, whilst op works out the
+// new value the 6502 writes the old one back. The 65C02 reads it again
+// instead and its indexed shifts only do the fixup read on page crossings
+func (c *Cpu) modify(op func(uint8) uint8) uint8 {
+	if c.curr.ins.addrMode == ModeAccumulator {
+		v := op(c.Rg.Gp.Ac.Read())
+		c.Rg.Gp.Ac.Write(v)
+		return v
 	}
+	if c.cmos() {
+		addr := c.getOperandAddr(c.curr.ins.opPageCycles == 0)
+		v := c.read8(addr)
+		c.read8(addr)
+		v = op(v)
+		c.write8(addr, v)
+		return v
+	}
+	addr := c.getOperandAddr(true)
+	v := c.read8(addr)
+	c.write8(addr, v)
+	v = op(v)
+	c.write8(addr, v)
+	return v
 }
 
 // Move Commands:
 func (c *Cpu) sta() {
-	c.Write8(c.getOperandAddr(c.curr.ins), c.Rg.Gp.Ac.Read())
+	c.write8(c.getOperandAddr(true), c.Rg.Gp.Ac.Read())
 }
 func (c *Cpu) stx() {
-	c.Write8(c.getOperandAddr(c.curr.ins), c.Rg.Gp.Ix.X.Read())
+	c.write8(c.getOperandAddr(true), c.Rg.Gp.Ix.X.Read())
 }
 func (c *Cpu) sty() {
-	c.Write8(c.getOperandAddr(c.curr.ins), c.Rg.Gp.Ix.Y.Read())
+	c.write8(c.getOperandAddr(true), c.Rg.Gp.Ix.Y.Read())
 }
 
 func (c *Cpu) lda() {
-	c.Rg.Gp.Ac.Write(c.Read8(c.getOperandAddr(c.curr.ins)))
+	c.Rg.Gp.Ac.Write(c.operand())
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
 }
 func (c *Cpu) ldx() {
-	c.Rg.Gp.Ix.X.Write(c.Read8(c.getOperandAddr(c.curr.ins)))
+	c.Rg.Gp.Ix.X.Write(c.operand())
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ix.X.Read()))
 }
 func (c *Cpu) ldy() {
-	c.Rg.Gp.Ix.Y.Write(c.Read8(c.getOperandAddr(c.curr.ins)))
+	c.Rg.Gp.Ix.Y.Write(c.operand())
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ix.Y.Read()))
 }
 
@@ -446,7 +597,7 @@ func (c *Cpu) tsx() {
 
 func (c *Cpu) _push8(val uint8) {
 	sp := c.Rg.Spc.Sp.Read()
-	c.Write8(uint16(sp)|0x100, val)
+	c.write8(uint16(sp)|0x100, val)
 	c.Rg.Spc.Sp.Write(sp - 1)
 }
 func (c *Cpu) _push16(val uint16) {
@@ -456,31 +607,47 @@ func (c *Cpu) _push16(val uint16) {
 func (c *Cpu) _pull8() uint8 {
 	sp := c.Rg.Spc.Sp.Read() + 1
 	c.Rg.Spc.Sp.Write(sp)
-	return c.Read8(uint16(sp) | 0x100)
+	return c.read8(uint16(sp) | 0x100)
 }
 func (c *Cpu) _pull16() uint16 {
 	return uint16(c._pull8()) | uint16(c._pull8())<<8
 }
 
+// the stack is read while the stack pointer is incremented, before the pulls
+func (c *Cpu) _stackRead() {
+	c.read8(uint16(c.Rg.Spc.Sp.Read()) | 0x100)
+}
+
 func (c *Cpu) pha() {
 	c._push8(c.Rg.Gp.Ac.Read())
 }
 func (c *Cpu) php() {
-	c._push8(c.Rg.Spc.Ps.Read() | BB)
+	c._push8(c.Rg.Spc.Ps.Read() | BB | BE)
 }
 
 func (c *Cpu) pla() {
+	c._stackRead()
 	c.Rg.Gp.Ac.Write(c._pull8())
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
 }
+
+// the B and E flags don't exist in the register, they keep their value
+func (c *Cpu) _pullPs() {
+	ps := c.Rg.Spc.Ps.Read()
+	c.Rg.Spc.Ps.Write(c._pull8()&^(BB|BE) | ps&(BB|BE))
+}
+
+// the I flag changes on the last cycle, after the interrupts were polled, so
+// the change is only seen after the next instruction. Same with CLI and SEI
 func (c *Cpu) plp() {
-	c.Rg.Spc.Ps.Write(c._pull8())
+	c._stackRead()
+	c._pullPs()
 }
 
 // Jump/Flag Commands:
 func (c *Cpu) bit() {
 	mask := c.Rg.Gp.Ac.Read()
-	value := c.Read8(c.getOperandAddr(c.curr.ins))
+	value := c.operand()
 	result := value & mask
 	c.Rg.Spc.Ps.Set(BZ, int8(result))
 	c.Rg.Spc.Ps.Set(BN|BV, int8(value))
@@ -508,28 +675,34 @@ func (c *Cpu) cli() {
 	c.Rg.Spc.Ps.Set(BI, 0)
 }
 
-// branching requires more cycles
-func (c *Cpu) addBranchCycles(addr uint16) {
-	c.clk++
-	pc := c.Rg.Spc.Pc.Val + uint16(c.curr.ins.opLength)
-	if pageCrossed(pc, addr) {
-		c.clk++
-	}
-}
-
 func (c *Cpu) jmp() {
-	// take into account PC increment
-	// might be better to move operand get to exec
-	addr := c.getOperandAddr(c.curr.ins) - uint16(c.curr.ins.opLength)
-	c.Rg.Spc.Pc.Write(addr)
+	c.Rg.Spc.Pc.Write(c.getOperandAddr(false))
 }
 
+// a taken branch reads the next opcode while adding the offset and, when a
+// page is crossed, once more from the address before the high byte fixup
 func (c *Cpu) _branch(flag uint8, test uint8) {
+	// the interrupts polled by the opcode fetch
+	runNmi, runIrq := c.runNmi, c.runIrq
+	addr := c.getOperandAddr(false)
 	if (c.Rg.Spc.Ps.Read() & flag) == test {
-		addr := c.getOperandAddr(c.curr.ins)
-		c.addBranchCycles(addr)
-		c.Rg.Spc.Pc.Write(addr)
+		crossed := pageCrossed(c.Rg.Spc.Pc.Read(), addr)
+		c._jumpTo(addr)
+		if !crossed {
+			// the interrupts aren't polled on the extra cycle of a taken
+			// branch without a page crossing, one first seen while fetching
+			// the offset is delayed by one instruction
+			c.prevRunNmi, c.prevRunIrq = runNmi, runIrq
+		}
+	}
+}
+func (c *Cpu) _jumpTo(addr uint16) {
+	pc := c.Rg.Spc.Pc.Read()
+	c.dummyRead()
+	if pageCrossed(pc, addr) {
+		c.read8(pc&0xFF00 | addr&0x00FF)
 	}
+	c.Rg.Spc.Pc.Write(addr)
 }
 
 func (c *Cpu) bpl() {
@@ -560,38 +733,75 @@ func (c *Cpu) beq() {
 	c._branch(BZ, BZ)
 }
 
+// the return address pushed is the one of the last JSR byte, the high byte of
+// the target is only fetched after the pushes
 func (c *Cpu) jsr() {
-	retAddr := c.Rg.Spc.Pc.Read() + uint16(c.curr.ins.opLength)
-	c._push16(retAddr - 1)
-	c.jmp()
+	l := uint16(c.fetch8())
+	c._stackRead()
+	ret := c.Rg.Spc.Pc.Read()
+	c._push16(ret)
+	h := uint16(c.fetch8())
+	c.Rg.Spc.Pc.Write(l | h<<8)
+	c.calls.push(CallFrame{Entry: l | h<<8, Caller: ret - 2, sp: c.Rg.Spc.Sp.Read()})
 }
 func (c *Cpu) rts() {
+	c._stackRead()
 	c.Rg.Spc.Pc.Write(c._pull16())
+	c.fetch8()
 }
 
+// unlike PLP the I flag is restored before the polling
 func (c *Cpu) rti() {
-	c.plp()
-	c.Rg.Spc.Pc.Write(c._pull16() - 1) // ?? -1??
+	c._stackRead()
+	c._pullPs()
+	c.Rg.Spc.Pc.Write(c._pull16())
 	c.inInt = false
-	c.clk += 7
 }
 
-func (c *Cpu) nop() {}
+// the unofficial NOPs still read their operand
+func (c *Cpu) nop() {
+	switch c.curr.ins.addrMode {
+	case ModeImplied:
+	default:
+		c.operand()
+	}
+}
 
 // Logical and arithmetic commands:
 func (c *Cpu) ora() {
-	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() | c.Read8(c.getOperandAddr(c.curr.ins)))
+	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() | c.operand())
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
 }
 func (c *Cpu) and() {
-	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() & c.Read8(c.getOperandAddr(c.curr.ins)))
+	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() & c.operand())
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
 }
 func (c *Cpu) eor() {
-	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() ^ c.Read8(c.getOperandAddr(c.curr.ins)))
+	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() ^ c.operand())
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
 }
+
+// the 2A03 has the decimal mode flag but no BCD logic
+func (c *Cpu) decimal() bool {
+	return c.variant != Variant2A03 && (c.Rg.Spc.Ps.Read()&BD) != 0
+}
+
 func (c *Cpu) _add(opr uint8) {
+	if c.decimal() {
+		c._addDecimal(opr)
+		return
+	}
+	c._addBinary(opr)
+}
+func (c *Cpu) _sub(opr uint8) {
+	if c.decimal() {
+		c._subDecimal(opr)
+		return
+	}
+	c._addBinary(opr ^ 0xFF)
+}
+
+func (c *Cpu) _addBinary(opr uint8) {
 	result := uint16(c.Rg.Gp.Ac.Read()) + uint16(opr) + uint16(c.Rg.Spc.Ps.Read()&BC)>>C
 	if result > 0xFF {
 		c.Rg.Spc.Ps.Set(BC, BC)
@@ -609,18 +819,91 @@ func (c *Cpu) _add(opr uint8) {
 	}
 	c.Rg.Gp.Ac.Write(uint8(result & 0xFF))
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
-	// oh and we're not handling decimal mode...
+}
+
+// Decimal mode, http://www.6502.org/tutorials/decimal_mode.html#A
+// The NMOS flags are a by-product of the binary logic, only the carry is
+// valid. The 65C02 sets N and Z from the result at the cost of an extra cycle
+func (c *Cpu) _addDecimal(opr uint8) {
+	a := c.Rg.Gp.Ac.Read()
+	carry := c.Rg.Spc.Ps.Read() & BC
+
+	al := int(a&0x0F) + int(opr&0x0F) + int(carry)
+	if al >= 0x0A {
+		al = ((al + 0x06) & 0x0F) + 0x10
+	}
+	// N and V come from the signed sum before the high digit is adjusted
+	sum := int(int8(a&0xF0)) + int(int8(opr&0xF0)) + al
+	result := int(a&0xF0) + int(opr&0xF0) + al
+	if result >= 0xA0 {
+		result += 0x60
+	}
+
+	if result >= 0x100 {
+		c.Rg.Spc.Ps.Set(BC, BC)
+	} else {
+		c.Rg.Spc.Ps.Set(BC, 0)
+	}
+	if sum < -128 || sum > 127 {
+		c.Rg.Spc.Ps.Set(BV, BV)
+	} else {
+		c.Rg.Spc.Ps.Set(BV, 0)
+	}
+	c.Rg.Gp.Ac.Write(uint8(result))
+
+	if c.cmos() {
+		c.dummyRead()
+		c.Rg.Spc.Ps.Set(BZ|BN, int8(result))
+	} else {
+		c.Rg.Spc.Ps.Set(BZ, int8(a+opr+carry))
+		c.Rg.Spc.Ps.Set(BN, int8(sum))
+	}
+}
+func (c *Cpu) _subDecimal(opr uint8) {
+	a := c.Rg.Gp.Ac.Read()
+	carry := int(c.Rg.Spc.Ps.Read() & BC)
+
+	// the flags come from the binary subtraction
+	c._addBinary(opr ^ 0xFF)
+
+	al := int(a&0x0F) - int(opr&0x0F) + carry - 1
+	var result int
+	if c.cmos() {
+		result = int(a) - int(opr) + carry - 1
+		if result < 0 {
+			result -= 0x60
+		}
+		if al < 0 {
+			result -= 0x06
+		}
+	} else {
+		if al < 0 {
+			al = ((al - 0x06) & 0x0F) - 0x10
+		}
+		result = int(a&0xF0) - int(opr&0xF0) + al
+		if result < 0 {
+			result -= 0x60
+		}
+	}
+	c.Rg.Gp.Ac.Write(uint8(result))
+
+	if c.cmos() {
+		c.dummyRead()
+		c.Rg.Spc.Ps.Set(BZ|BN, int8(result))
+	}
 }
 
 func (c *Cpu) adc() {
-	c._add(c.Read8(c.getOperandAddr(c.curr.ins)))
+	c._add(c.operand())
 }
 func (c *Cpu) sbc() {
-	c._add(c.Read8(c.getOperandAddr(c.curr.ins)) ^ 0xFF)
+	c._sub(c.operand())
 }
 
 func (c *Cpu) _cmp(op1 uint8) {
-	op2 := c.Read8(c.getOperandAddr(c.curr.ins))
+	c._compare(op1, c.operand())
+}
+func (c *Cpu) _compare(op1 uint8, op2 uint8) {
 	r := int8(op1 - op2)
 
 	if op1 >= op2 {
@@ -643,106 +926,196 @@ func (c *Cpu) cpy() {
 	c._cmp(c.Rg.Gp.Ix.Y.Read())
 }
 
-func (c *Cpu) dec() {
-	v := c.Read8(c.getOperandAddr(c.curr.ins)) - 1
-	c.Write8(c.getOperandAddr(c.curr.ins), v)
+func (c *Cpu) _dec(v uint8) uint8 {
+	v--
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	return v
+}
+func (c *Cpu) dec() {
+	c.modify(c._dec)
 }
 
 func (c *Cpu) dex() {
-	v := c.Rg.Gp.Ix.X.Read() - 1
-	c.Rg.Gp.Ix.X.Write(v)
-	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	c.Rg.Gp.Ix.X.Write(c._dec(c.Rg.Gp.Ix.X.Read()))
 }
 
 func (c *Cpu) dey() {
-	v := c.Rg.Gp.Ix.Y.Read() - 1
-	c.Rg.Gp.Ix.Y.Write(v)
-	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	c.Rg.Gp.Ix.Y.Write(c._dec(c.Rg.Gp.Ix.Y.Read()))
 }
 
-func (c *Cpu) inc() {
-	v := c.Read8(c.getOperandAddr(c.curr.ins)) + 1
-	c.Write8(c.getOperandAddr(c.curr.ins), v)
+func (c *Cpu) _inc(v uint8) uint8 {
+	v++
 	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	return v
+}
+func (c *Cpu) inc() {
+	c.modify(c._inc)
 }
 
 func (c *Cpu) inx() {
-	v := c.Rg.Gp.Ix.X.Read() + 1
-	c.Rg.Gp.Ix.X.Write(v)
-	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	c.Rg.Gp.Ix.X.Write(c._inc(c.Rg.Gp.Ix.X.Read()))
 }
 
 func (c *Cpu) iny() {
-	v := c.Rg.Gp.Ix.Y.Read() + 1
-	c.Rg.Gp.Ix.Y.Write(v)
-	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	c.Rg.Gp.Ix.Y.Write(c._inc(c.Rg.Gp.Ix.Y.Read()))
 }
 
+func (c *Cpu) _asl(v uint8) uint8 {
+	c.Rg.Spc.Ps.Set(BC, int8(v>>7)&BC)
+	v <<= 1
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	return v
+}
 func (c *Cpu) asl() {
-	if c.curr.ins.addrMode == ModeAccumulator {
-		v := c.Rg.Gp.Ac.Read()
-		c.Rg.Spc.Ps.Set(BC, int8(v>>7)&BC)
-		v <<= 1
-		c.Rg.Gp.Ac.Write(v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
-	} else {
-		v := c.Read8(c.getOperandAddr(c.curr.ins))
-		c.Rg.Spc.Ps.Set(BC, int8(v>>7)&BC)
-		v <<= 1
-		c.Write8(c.getOperandAddr(c.curr.ins), v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
-	}
+	c.modify(c._asl)
 }
 
+func (c *Cpu) _rol(v uint8) uint8 {
+	fC := c.Rg.Spc.Ps.Read() & BC
+	c.Rg.Spc.Ps.Set(BC, int8(v>>7)&BC)
+	v = (v << 1) | fC
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	return v
+}
 func (c *Cpu) rol() {
-	if c.curr.ins.addrMode == ModeAccumulator {
-		v := c.Rg.Gp.Ac.Read()
-		fC := c.Rg.Spc.Ps.Read() & BC
-		c.Rg.Spc.Ps.Set(BC, int8(v>>7)&BC)
-		v = (v << 1) | fC
-		c.Rg.Gp.Ac.Write(v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
-	} else {
-		v := c.Read8(c.getOperandAddr(c.curr.ins))
-		fC := c.Rg.Spc.Ps.Read() & BC
-		c.Rg.Spc.Ps.Set(BC, int8(v>>7)&BC)
-		v = (v << 1) | fC
-		c.Write8(c.getOperandAddr(c.curr.ins), v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
-	}
+	c.modify(c._rol)
 }
 
+func (c *Cpu) _lsr(v uint8) uint8 {
+	c.Rg.Spc.Ps.Set(BC, int8(v)&BC)
+	v >>= 1
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	return v
+}
 func (c *Cpu) lsr() {
-	if c.curr.ins.addrMode == ModeAccumulator {
-		v := c.Rg.Gp.Ac.Read()
-		c.Rg.Spc.Ps.Set(BC, int8(v)&BC)
-		v >>= 1
-		c.Rg.Gp.Ac.Write(v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
-	} else {
-		v := c.Read8(c.getOperandAddr(c.curr.ins))
-		c.Rg.Spc.Ps.Set(BC, int8(v)&BC)
-		v >>= 1
-		c.Write8(c.getOperandAddr(c.curr.ins), v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
-	}
+	c.modify(c._lsr)
 }
 
+func (c *Cpu) _ror(v uint8) uint8 {
+	fC := c.Rg.Spc.Ps.Read() & BC
+	c.Rg.Spc.Ps.Set(BC, int8(v)&BC)
+	v = (v >> 1) | (fC << 7)
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	return v
+}
 func (c *Cpu) ror() {
-	if c.curr.ins.addrMode == ModeAccumulator {
-		v := c.Rg.Gp.Ac.Read()
-		fC := c.Rg.Spc.Ps.Read() & BC
-		c.Rg.Spc.Ps.Set(BC, int8(v)&BC)
-		v = (v >> 1) | (fC << 7)
-		c.Rg.Gp.Ac.Write(v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+	c.modify(c._ror)
+}
+
+// Unofficial commands:
+// http://www.oxyron.de/html/opcodes02.html
+// https://wiki.nesdev.com/w/index.php/Programming_with_unofficial_opcodes
+
+// read-modify-write combined with an accumulator operation
+func (c *Cpu) slo() {
+	v := c.modify(c._asl)
+	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() | v)
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
+}
+func (c *Cpu) rla() {
+	v := c.modify(c._rol)
+	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() & v)
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
+}
+func (c *Cpu) sre() {
+	v := c.modify(c._lsr)
+	c.Rg.Gp.Ac.Write(c.Rg.Gp.Ac.Read() ^ v)
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(c.Rg.Gp.Ac.Read()))
+}
+func (c *Cpu) rra() {
+	c._add(c.modify(c._ror))
+}
+func (c *Cpu) dcp() {
+	c._compare(c.Rg.Gp.Ac.Read(), c.modify(c._dec))
+}
+func (c *Cpu) isb() {
+	c._sub(c.modify(c._inc))
+}
+
+func (c *Cpu) sax() {
+	c.write8(c.getOperandAddr(true), c.Rg.Gp.Ac.Read()&c.Rg.Gp.Ix.X.Read())
+}
+func (c *Cpu) lax() {
+	v := c.operand()
+	c.Rg.Gp.Ac.Write(v)
+	c.Rg.Gp.Ix.X.Write(v)
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+}
+
+// immediate only
+func (c *Cpu) anc() {
+	c.and()
+	c.Rg.Spc.Ps.Set(BC, int8(c.Rg.Gp.Ac.Read()>>7)&BC)
+}
+func (c *Cpu) alr() {
+	c.Rg.Gp.Ac.Write(c._lsr(c.Rg.Gp.Ac.Read() & c.operand()))
+}
+
+// the AND is followed by a ROR with the carry and overflow taken from the
+// result bits 6 and 6 xor 5
+func (c *Cpu) arr() {
+	v := c._ror(c.Rg.Gp.Ac.Read() & c.operand())
+	c.Rg.Gp.Ac.Write(v)
+	c.Rg.Spc.Ps.Set(BC, int8(v>>6)&BC)
+	if ((v>>6)^(v>>5))&1 != 0 {
+		c.Rg.Spc.Ps.Set(BV, BV)
 	} else {
-		v := c.Read8(c.getOperandAddr(c.curr.ins))
-		fC := c.Rg.Spc.Ps.Read() & BC
-		c.Rg.Spc.Ps.Set(BC, int8(v)&BC)
-		v = (v >> 1) | (fC << 7)
-		c.Write8(c.getOperandAddr(c.curr.ins), v)
-		c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+		c.Rg.Spc.Ps.Set(BV, 0)
 	}
 }
+
+// X = A & X - imm, with the carry set like CMP and without borrowing
+func (c *Cpu) axs() {
+	ax := c.Rg.Gp.Ac.Read() & c.Rg.Gp.Ix.X.Read()
+	v := c.operand()
+	c._compare(ax, v)
+	c.Rg.Gp.Ix.X.Write(ax - v)
+}
+
+// Unstable commands, the common behaviour is used
+
+// the magic constant depends on the chip and temperature, 0xEE is the most
+// common value
+func (c *Cpu) xaa() {
+	v := (c.Rg.Gp.Ac.Read() | 0xEE) & c.Rg.Gp.Ix.X.Read() & c.operand()
+	c.Rg.Gp.Ac.Write(v)
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+}
+func (c *Cpu) las() {
+	v := c.operand() & c.Rg.Spc.Sp.Read()
+	c.Rg.Gp.Ac.Write(v)
+	c.Rg.Gp.Ix.X.Write(v)
+	c.Rg.Spc.Sp.Write(v)
+	c.Rg.Spc.Ps.Set(BZ|BN, int8(v))
+}
+
+// The indexed stores AND the value with the high byte of the base address
+// plus one. When the index crosses a page the written address high byte is
+// replaced by the stored value
+func (c *Cpu) _storeHigh(val uint8, index uint8) {
+	addr := c.getOperandAddr(true)
+	base := addr - uint16(index)
+	val &= uint8(base>>8) + 1
+	if c.curr.pgX {
+		addr = uint16(val)<<8 | addr&0xFF
+	}
+	c.write8(addr, val)
+}
+func (c *Cpu) ahx() {
+	c._storeHigh(c.Rg.Gp.Ac.Read()&c.Rg.Gp.Ix.X.Read(), c.Rg.Gp.Ix.Y.Read())
+}
+func (c *Cpu) tas() {
+	c.Rg.Spc.Sp.Write(c.Rg.Gp.Ac.Read() & c.Rg.Gp.Ix.X.Read())
+	c._storeHigh(c.Rg.Spc.Sp.Read(), c.Rg.Gp.Ix.Y.Read())
+}
+func (c *Cpu) shx() {
+	c._storeHigh(c.Rg.Gp.Ix.X.Read(), c.Rg.Gp.Ix.Y.Read())
+}
+func (c *Cpu) shy() {
+	c._storeHigh(c.Rg.Gp.Ix.Y.Read(), c.Rg.Gp.Ix.X.Read())
+}
+
+// jams the cpu, only a reset brings it back
+func (c *Cpu) kil() {
+	c.halted = true
+}
@@ -0,0 +1,334 @@
+package cpu
+
+import (
+	"strings"
+	"testing"
+)
+
+// flat 64 KB of memory, the code is loaded at $0200 and all the vectors
+// point at it
+type testBus struct {
+	mem [0x10000]uint8
+}
+
+func (b *testBus) Read8(addr uint16) uint8 {
+	return b.mem[addr]
+}
+func (b *testBus) Write8(addr uint16, val uint8) {
+	b.mem[addr] = val
+}
+
+const testCodeAddr = 0x0200
+
+func newCpu(variant Variant, code ...uint8) (*Cpu, *testBus) {
+	bus := &testBus{}
+	copy(bus.mem[testCodeAddr:], code)
+	for _, vector := range []uint16{0xFFFA, 0xFFFC, 0xFFFE} {
+		bus.mem[vector] = uint8(testCodeAddr & 0xFF)
+		bus.mem[vector+1] = uint8(testCodeAddr >> 8)
+	}
+
+	c := &Cpu{}
+	c.Init(bus, nil, variant, false)
+	c.Reset()
+	return c, bus
+}
+
+// runs n instructions and returns the cycles they took
+func run(c *Cpu, n int) int {
+	ticks := 0
+	for i := 0; i < n; i++ {
+		ticks += c.Tick()
+	}
+	return ticks
+}
+
+func checkReg(t *testing.T, name string, got uint8, expected uint8) {
+	if got != expected {
+		t.Errorf("%s: got %s=0x%02x, expected 0x%02x", t.Name(), name, got, expected)
+	}
+}
+
+func checkFlags(t *testing.T, c *Cpu, flags uint8, expected uint8) {
+	checkReg(t, "Ps", c.Rg.Spc.Ps.Read()&flags, expected)
+}
+
+// every instruction, with the index registers and the memory cleared, does
+// as many bus accesses as its table entry, without page crossings
+func TestCycles(t *testing.T) {
+	for _, variant := range []Variant{Variant2A03, VariantNMOS, Variant65C02} {
+		for op := 0; op < 256; op++ {
+			c, _ := newCpu(variant, uint8(op))
+			ins := c.ins[op]
+			if ins.addrMode == ModeRelative || ins.addrMode == ModeZeroPageRelative {
+				continue
+			}
+			if ticks := run(c, 1); ticks != int(ins.opCycles) {
+				t.Errorf("variant %d, %s (0x%02x): took %d cycles, expected %d", variant, ins.opName, op, ticks, ins.opCycles)
+			}
+			if !c.Halted() && c.Rg.Spc.Pc.Read() == testCodeAddr+uint16(ins.opLength) {
+				continue
+			}
+			switch ins.opName {
+			case "KIL", "STP", "BRK", "JMP", "JSR", "RTS", "RTI":
+			default:
+				t.Errorf("variant %d, %s (0x%02x): pc at 0x%04x", variant, ins.opName, op, c.Rg.Spc.Pc.Read())
+			}
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	// SED; CLC; LDA #$58; ADC #$46; SEC; SBC #$12
+	code := []uint8{0xF8, 0x18, 0xA9, 0x58, 0x69, 0x46, 0x38, 0xE9, 0x12}
+
+	c, _ := newCpu(VariantNMOS, code...)
+	run(c, 4)
+	checkReg(t, "Ac", c.Rg.Gp.Ac.Read(), 0x04)
+	checkFlags(t, c, BC, BC)
+	run(c, 2)
+	checkReg(t, "Ac", c.Rg.Gp.Ac.Read(), 0x92)
+	checkFlags(t, c, BC, 0)
+
+	// the 2A03 ignores the D flag
+	c, _ = newCpu(Variant2A03, code...)
+	run(c, 4)
+	checkReg(t, "Ac", c.Rg.Gp.Ac.Read(), 0x9E)
+	checkFlags(t, c, BC, 0)
+}
+
+// 99 + 1 = 00, the NMOS flags are from the binary logic
+func TestDecimalFlags(t *testing.T) {
+	// SED; CLC; LDA #$99; ADC #$01
+	code := []uint8{0xF8, 0x18, 0xA9, 0x99, 0x69, 0x01}
+
+	c, _ := newCpu(VariantNMOS, code...)
+	run(c, 3)
+	checkReg(t, "cycles", uint8(run(c, 1)), 2)
+	checkReg(t, "Ac", c.Rg.Gp.Ac.Read(), 0x00)
+	checkFlags(t, c, BC|BZ|BN, BC|BN)
+
+	c, _ = newCpu(Variant65C02, code...)
+	run(c, 3)
+	checkReg(t, "cycles", uint8(run(c, 1)), 3)
+	checkReg(t, "Ac", c.Rg.Gp.Ac.Read(), 0x00)
+	checkFlags(t, c, BC|BZ|BN, BC|BZ)
+}
+
+func TestUnofficial(t *testing.T) {
+	// LAX $10; DCP $11
+	code := []uint8{0xA7, 0x10, 0xC7, 0x11}
+
+	c, bus := newCpu(VariantNMOS, code...)
+	bus.mem[0x10] = 0x42
+	bus.mem[0x11] = 0x43
+	run(c, 2)
+	checkReg(t, "Ac", c.Rg.Gp.Ac.Read(), 0x42)
+	checkReg(t, "X", c.Rg.Gp.Ix.X.Read(), 0x42)
+	checkReg(t, "$11", bus.mem[0x11], 0x42)
+	checkFlags(t, c, BZ|BC, BZ|BC)
+
+	// SMB2 and SMB4 on the 65C02
+	c, bus = newCpu(Variant65C02, code...)
+	bus.mem[0x10] = 0x42
+	bus.mem[0x11] = 0x03
+	run(c, 2)
+	checkReg(t, "$10", bus.mem[0x10], 0x46)
+	checkReg(t, "$11", bus.mem[0x11], 0x13)
+}
+
+func Test65C02(t *testing.T) {
+	c, bus := newCpu(Variant65C02,
+		0xA9, 0x0F, // LDA #$0F
+		0x04, 0x10, // TSB $10
+		0x14, 0x11, // TRB $11
+		0x64, 0x12, // STZ $12
+		0xB2, 0x13, // LDA ($13)
+		0xDA,       // PHX
+		0x7A,       // PLY
+		0x80, 0x01, // BRA +1
+		0xDB,             // STP
+		0x6C, 0xFF, 0x10, // JMP ($10FF)
+	)
+	bus.mem[0x10] = 0x30
+	bus.mem[0x11] = 0x33
+	bus.mem[0x12] = 0xFF
+	bus.mem[0x13] = 0x00
+	bus.mem[0x14] = 0x03
+	bus.mem[0x0300] = 0x5A
+	bus.mem[0x10FF] = 0x34
+	bus.mem[0x1000] = 0x00
+	bus.mem[0x1100] = 0x12
+	c.Rg.Gp.Ix.X.Write(0x80)
+
+	run(c, 2)
+	checkReg(t, "$10", bus.mem[0x10], 0x3F)
+	checkFlags(t, c, BZ, BZ)
+	run(c, 1)
+	checkReg(t, "$11", bus.mem[0x11], 0x30)
+	checkFlags(t, c, BZ, 0)
+	run(c, 1)
+	checkReg(t, "$12", bus.mem[0x12], 0x00)
+	run(c, 1)
+	checkReg(t, "Ac", c.Rg.Gp.Ac.Read(), 0x5A)
+	run(c, 2)
+	checkReg(t, "Y", c.Rg.Gp.Ix.Y.Read(), 0x80)
+	checkFlags(t, c, BN, BN)
+	if ticks := run(c, 2); ticks != 9 || c.Halted() {
+		t.Errorf("%s: BRA and JMP took %d cycles", t.Name(), ticks)
+	}
+	// no page wrap bug
+	if pc := c.Rg.Spc.Pc.Read(); pc != 0x1234 {
+		t.Errorf("%s: JMP ($10FF) to 0x%04x", t.Name(), pc)
+	}
+}
+
+func TestWaitAndStop(t *testing.T) {
+	c, _ := newCpu(Variant65C02, 0xCB, 0xEA, 0xDB) // WAI; NOP; STP
+	run(c, 10)
+	if pc := c.Rg.Spc.Pc.Read(); pc != testCodeAddr+1 {
+		t.Fatalf("%s: pc 0x%04x while waiting", t.Name(), pc)
+	}
+	// with I set the IRQ just resumes the execution
+	c.Raise(CpuIntIRQ)
+	run(c, 3)
+	if !c.Halted() {
+		t.Errorf("%s: STP didn't stop the cpu", t.Name())
+	}
+}
+
+func TestInterrupts(t *testing.T) {
+	// CLI; NOP; NOP
+	c, bus := newCpu(VariantNMOS, 0x58, 0xEA, 0xEA)
+	handler := uint16(0x0300)
+	bus.mem[0xFFFE] = uint8(handler)
+	bus.mem[0xFFFF] = uint8(handler >> 8)
+
+	// the IRQ is taken after the instruction following the CLI
+	c.Raise(CpuIntIRQ)
+	run(c, 2)
+	if pc := c.Rg.Spc.Pc.Read(); pc != testCodeAddr+2 {
+		t.Fatalf("%s: pc 0x%04x after CLI", t.Name(), pc)
+	}
+	if ticks := run(c, 1); ticks != 7 || c.Rg.Spc.Pc.Read() != handler {
+		t.Fatalf("%s: IRQ took %d cycles to 0x%04x", t.Name(), ticks, c.Rg.Spc.Pc.Read())
+	}
+	checkReg(t, "pushed Ps", bus.mem[0x01FD]&BB, 0)
+	c.Clear(CpuIntIRQ)
+
+	// BRK pushes the B flag, an NMI during the pushes hijacks it
+	c, bus = newCpu(VariantNMOS, 0x00)
+	bus.mem[0xFFFA] = 0x00
+	bus.mem[0xFFFB] = 0x04
+	c.Rg.Spc.Ps.Set(BI, 0)
+	c.Raise(CpuIntNMI)
+	run(c, 1)
+	if pc := c.Rg.Spc.Pc.Read(); pc != 0x0400 {
+		t.Errorf("%s: BRK hijacked to 0x%04x", t.Name(), pc)
+	}
+	checkReg(t, "pushed Ps", bus.mem[0x01FD]&BB, BB)
+	checkReg(t, "pushed Pc", bus.mem[0x01FE], uint8((testCodeAddr+2)&0xFF))
+
+	// an interrupt first seen on the offset fetch of a taken branch without
+	// a page crossing waits for the next instruction
+	for name, irq := range map[string]uint8{"IRQ": CpuIntIRQ, "NMI": CpuIntNMI} {
+		// CLI; NOP; BEQ +1 (Z is set by the reset); NOP; NOP; NOP
+		c, bus := newCpu(VariantNMOS, 0x58, 0xEA, 0xF0, 0x01, 0xEA, 0xEA, 0xEA)
+		raise := &raiseBus{testBus: *bus, c: c, addr: testCodeAddr + 3, irq: irq}
+		c.Init(raise, nil, VariantNMOS, false)
+		c.Reset()
+		run(c, 4)
+		if pc := c.Rg.Spc.Pc.Read(); pc != testCodeAddr+6 {
+			t.Errorf("%s: %s taken right after the branch, pc 0x%04x", t.Name(), name, pc)
+			continue
+		}
+		run(c, 1)
+		sp := uint16(c.Rg.Spc.Sp.Read())
+		pushed := uint16(raise.mem[0x0100+sp+2]) | uint16(raise.mem[0x0100+sp+3])<<8
+		if pushed != testCodeAddr+6 {
+			t.Errorf("%s: %s pushed pc 0x%04x", t.Name(), name, pushed)
+		}
+	}
+}
+
+// raises an interrupt on the read of an address
+type raiseBus struct {
+	testBus
+	c    *Cpu
+	addr uint16
+	irq  uint8
+}
+
+func (b *raiseBus) Read8(addr uint16) uint8 {
+	if addr == b.addr {
+		b.c.Raise(b.irq)
+	}
+	return b.testBus.Read8(addr)
+}
+
+// a JSR, a BRK in the subroutine and an NMI in the BRK handler, the frames
+// are gone once their RTS or RTI ran
+func TestCallStack(t *testing.T) {
+	// JSR $0210; JMP *
+	c, bus := newCpu(VariantNMOS, 0x20, 0x10, 0x02, 0x4C, 0x03, 0x02)
+	copy(bus.mem[0x0210:], []uint8{0x00, 0x00, 0x60}) // BRK; RTS
+	copy(bus.mem[0x0230:], []uint8{0xEA, 0x40})       // irq: NOP; RTI
+	copy(bus.mem[0x0240:], []uint8{0xEA, 0x40})       // nmi: NOP; RTI
+	bus.mem[0xFFFA], bus.mem[0xFFFB] = 0x40, 0x02
+	bus.mem[0xFFFE], bus.mem[0xFFFF] = 0x30, 0x02
+
+	stacks := map[uint16][]CallFrame{}
+	c.OnExec(func(pc uint16) {
+		stacks[pc] = c.CallStack()
+	})
+	run(c, 2)
+	c.Raise(CpuIntNMI)
+	// NOP, the NMI, NOP, RTI, RTI, RTS
+	run(c, 6)
+
+	expected := map[uint16][]CallFrame{
+		0x0241: {
+			{Entry: 0x0240, Caller: 0x0231, Interrupt: true, NMI: true},
+			{Entry: 0x0230, Caller: 0x0210, Interrupt: true},
+			{Entry: 0x0210, Caller: 0x0200},
+		},
+		0x0231: {
+			{Entry: 0x0230, Caller: 0x0210, Interrupt: true},
+			{Entry: 0x0210, Caller: 0x0200},
+		},
+		0x0212: {{Entry: 0x0210, Caller: 0x0200}},
+		0x0203: {},
+	}
+	for pc, frames := range expected {
+		stack := stacks[pc]
+		if len(stack) != len(frames) {
+			t.Errorf("%s: %d frames at $%04X, expected %d", t.Name(), len(stack), pc, len(frames))
+			continue
+		}
+		for i, f := range frames {
+			s := stack[i]
+			if s.Entry != f.Entry || s.Caller != f.Caller || s.Interrupt != f.Interrupt || s.NMI != f.NMI {
+				t.Errorf("%s: frame %d at $%04X %+v, expected %+v", t.Name(), i, pc, s, f)
+			}
+		}
+	}
+}
+
+func TestTraceLabels(t *testing.T) {
+	// JSR $0210, then NOP at $0210
+	c, bus := newCpu(Variant2A03, 0x20, 0x10, 0x02)
+	bus.mem[0x0210] = 0xEA
+
+	var trace strings.Builder
+	c.Trace(&trace, nil, nil)
+	c.TraceLabels(func(addr uint16) (string, bool) {
+		return "sub", addr == 0x0210
+	})
+	run(c, 2)
+
+	lines := strings.Split(trace.String(), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "0200  20 10 02  JSR sub ") ||
+		lines[1] != "sub:" || !strings.HasPrefix(lines[2], "0210  EA        NOP ") {
+		t.Errorf("trace:\n%s", trace.String())
+	}
+}
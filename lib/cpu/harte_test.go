@@ -0,0 +1,211 @@
+package cpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Tom Harte's single step tests, https://github.com/SingleStepTests/65x02
+// Every opcode has a file with 10000 vectors, each one with the state before
+// and after the instruction and every bus access done on its cycles.
+// The vectors are not part of the repo, point GONES_HARTE_TESTS at a checkout
+// (the directory with the 6502, nes6502 and wdc65c02 folders) or copy it to
+// testdata/65x02
+const harteTestsEnv = "GONES_HARTE_TESTS"
+
+type harteState struct {
+	Pc  uint16     `json:"pc"`
+	S   uint8      `json:"s"`
+	A   uint8      `json:"a"`
+	X   uint8      `json:"x"`
+	Y   uint8      `json:"y"`
+	P   uint8      `json:"p"`
+	Ram [][2]int64 `json:"ram"`
+}
+
+type harteTest struct {
+	Name    string           `json:"name"`
+	Initial harteState       `json:"initial"`
+	Final   harteState       `json:"final"`
+	Cycles  [][3]interface{} `json:"cycles"`
+}
+
+type busAccess struct {
+	addr  uint16
+	val   uint8
+	write bool
+}
+
+func (a busAccess) String() string {
+	if a.write {
+		return fmt.Sprintf("write 0x%04x=0x%02x", a.addr, a.val)
+	}
+	return fmt.Sprintf("read  0x%04x=0x%02x", a.addr, a.val)
+}
+
+// recordingBus logs every access, on top of the flat memory
+type recordingBus struct {
+	testBus
+	record   bool
+	accesses []busAccess
+}
+
+func (b *recordingBus) Read8(addr uint16) uint8 {
+	val := b.testBus.Read8(addr)
+	if b.record {
+		b.accesses = append(b.accesses, busAccess{addr, val, false})
+	}
+	return val
+}
+func (b *recordingBus) Write8(addr uint16, val uint8) {
+	if b.record {
+		b.accesses = append(b.accesses, busAccess{addr, val, true})
+	}
+	b.testBus.Write8(addr, val)
+}
+
+func harteDir() string {
+	if dir := os.Getenv(harteTestsEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join("testdata", "65x02")
+}
+
+// the unstable opcodes depend on the chip, the jams and the 65C02 WAI and STP
+// stop the cpu which the vectors model with a fixed number of cycles
+var harteSkip = map[string]bool{
+	"XAA": true,
+	"KIL": true,
+	"WAI": true,
+	"STP": true,
+}
+
+func TestHarte2A03(t *testing.T) {
+	testHarte(t, Variant2A03, "nes6502")
+}
+func TestHarteNMOS(t *testing.T) {
+	testHarte(t, VariantNMOS, "6502")
+}
+func TestHarte65C02(t *testing.T) {
+	testHarte(t, Variant65C02, "wdc65c02")
+}
+
+func testHarte(t *testing.T, variant Variant, name string) {
+	dir := filepath.Join(harteDir(), name, "v1")
+	if _, err := os.Stat(dir); err != nil {
+		t.Skipf("no test vectors at %s, set %s", dir, harteTestsEnv)
+	}
+
+	bus := &recordingBus{}
+	c := &Cpu{}
+	c.Init(bus, nil, variant, false)
+
+	for op := 0; op < 256; op++ {
+		ins := &c.ins[op]
+		// LAX #imm is as unstable as XAA
+		if harteSkip[ins.opName] || (op == 0xab && variant != Variant65C02) {
+			continue
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%02x.json", op))
+		t.Run(fmt.Sprintf("%02x_%s", op, ins.opName), func(t *testing.T) {
+			tests, err := loadHarte(path)
+			if err != nil {
+				t.Skipf("failed to load %s: %v", path, err)
+			}
+			for _, test := range tests {
+				if err := runHarte(c, bus, &test); err != nil {
+					// one failure per opcode is enough
+					t.Fatalf("%s: %v", test.Name, err)
+				}
+			}
+		})
+	}
+}
+
+func loadHarte(path string) ([]harteTest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tests []harteTest
+	if err := json.NewDecoder(f).Decode(&tests); err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
+
+func runHarte(c *Cpu, bus *recordingBus, test *harteTest) error {
+	bus.record = false
+	c.Reset()
+
+	for _, m := range test.Initial.Ram {
+		bus.mem[m[0]] = uint8(m[1])
+	}
+	c.Rg.Spc.Pc.Write(test.Initial.Pc)
+	c.Rg.Spc.Sp.Write(test.Initial.S)
+	c.Rg.Spc.Ps.Write(test.Initial.P)
+	c.Rg.Gp.Ac.Write(test.Initial.A)
+	c.Rg.Gp.Ix.X.Write(test.Initial.X)
+	c.Rg.Gp.Ix.Y.Write(test.Initial.Y)
+
+	bus.accesses = bus.accesses[:0]
+	bus.record = true
+	c.Tick()
+	bus.record = false
+
+	// the memory is shared by the vectors, clear what this one used
+	defer func() {
+		for _, m := range test.Initial.Ram {
+			bus.mem[m[0]] = 0
+		}
+		for _, a := range bus.accesses {
+			bus.mem[a.addr] = 0
+		}
+	}()
+
+	final := test.Final
+	regs := []struct {
+		name     string
+		got, exp uint16
+	}{
+		{"pc", c.Rg.Spc.Pc.Read(), final.Pc},
+		{"s", uint16(c.Rg.Spc.Sp.Read()), uint16(final.S)},
+		{"a", uint16(c.Rg.Gp.Ac.Read()), uint16(final.A)},
+		{"x", uint16(c.Rg.Gp.Ix.X.Read()), uint16(final.X)},
+		{"y", uint16(c.Rg.Gp.Ix.Y.Read()), uint16(final.Y)},
+		// B and E are not part of the register
+		{"p", uint16(c.Rg.Spc.Ps.Read() &^ (BB | BE)), uint16(final.P &^ (BB | BE))},
+	}
+	for _, r := range regs {
+		if r.got != r.exp {
+			return fmt.Errorf("%s is 0x%02x, expected 0x%02x", r.name, r.got, r.exp)
+		}
+	}
+
+	for _, m := range final.Ram {
+		if got := bus.mem[m[0]]; got != uint8(m[1]) {
+			return fmt.Errorf("[0x%04x] is 0x%02x, expected 0x%02x", m[0], got, m[1])
+		}
+	}
+
+	if len(bus.accesses) != len(test.Cycles) {
+		return fmt.Errorf("%d bus accesses, expected %d\ngot:      %v\nexpected: %v",
+			len(bus.accesses), len(test.Cycles), bus.accesses, test.Cycles)
+	}
+	for i, cycle := range test.Cycles {
+		exp := busAccess{
+			addr:  uint16(cycle[0].(float64)),
+			val:   uint8(cycle[1].(float64)),
+			write: cycle[2].(string) == "write",
+		}
+		if bus.accesses[i] != exp {
+			return fmt.Errorf("cycle %d: %v, expected %v", i, bus.accesses[i], exp)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cpu
+
+// the unofficial NMOS opcodes, besides the NOPs and the SBC copy
+var unofficialNames = map[string]bool{
+	"SLO": true, "RLA": true, "SRE": true, "RRA": true, "SAX": true, "LAX": true,
+	"DCP": true, "ISB": true, "ANC": true, "ALR": true, "ARR": true, "XAA": true,
+	"AXS": true, "AHX": true, "SHY": true, "SHX": true, "TAS": true, "LAS": true,
+	"KIL": true,
+}
+
+func (c *Cpu) setupTable() {
+	c.setupIns()
+	if c.cmos() {
+		// the unused opcodes are all NOPs
+		c.setupIns65C02()
+		return
+	}
+
+	for i := range c.ins {
+		ins := &c.ins[i]
+		switch ins.opName {
+		case "NOP":
+			ins.unofficial = ins.opCode != 0xea
+		case "SBC":
+			ins.unofficial = ins.opCode == 0xeb
+		default:
+			ins.unofficial = unofficialNames[ins.opName]
+		}
+	}
+}
+
+// Instructions returns the instruction table of a variant, indexed by the
+// opcode, eg: for a disassembler
+func Instructions(variant Variant) [256]Instruction {
+	c := &Cpu{variant: variant}
+	c.setupTable()
+	return c.ins
+}
+
+func (i *Instruction) Name() string {
+	return i.opName
+}
+func (i *Instruction) OpCode() uint8 {
+	return i.opCode
+}
+
+// Length in bytes, with the opcode
+func (i *Instruction) Length() int {
+	return int(i.opLength)
+}
+
+// AddrMode is one of the Mode constants
+func (i *Instruction) AddrMode() uint8 {
+	return i.addrMode
+}
+
+// Cycles without the page crossings and the taken branches
+func (i *Instruction) Cycles() int {
+	return int(i.opCycles)
+}
+
+// Unofficial is set for the NMOS undocumented opcodes
+func (i *Instruction) Unofficial() bool {
+	return i.unofficial
+}
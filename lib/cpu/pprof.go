@@ -0,0 +1,158 @@
+package cpu
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// the pprof profile is a gzipped protocol buffer, see
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+// only the few fields needed here are written
+const (
+	// Profile
+	pprofSampleType  = 1
+	pprofSample      = 2
+	pprofLocation    = 4
+	pprofFunction    = 5
+	pprofStringTable = 6
+	pprofPeriodType  = 11
+	pprofPeriod      = 12
+	// ValueType
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+	// Sample
+	pprofSampleLocationId = 1
+	pprofSampleValue      = 2
+	// Location
+	pprofLocationId      = 1
+	pprofLocationAddress = 3
+	pprofLocationLine    = 4
+	// Line
+	pprofLineFunctionId = 1
+	// Function
+	pprofFunctionId         = 1
+	pprofFunctionName       = 2
+	pprofFunctionSystemName = 3
+)
+
+// protocol buffer encoder, only the varints and the length delimited fields
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.data = append(b.data, byte(v)|0x80)
+		v >>= 7
+	}
+	b.data = append(b.data, byte(v))
+}
+
+func (b *protoBuffer) uint64(field int, v uint64) {
+	b.varint(uint64(field) << 3)
+	b.varint(v)
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	b.varint(uint64(field)<<3 | 2)
+	b.varint(uint64(len(v)))
+	b.data = append(b.data, v...)
+}
+
+func (b *protoBuffer) message(field int, encode func(m *protoBuffer)) {
+	m := &protoBuffer{}
+	encode(m)
+	b.bytes(field, m.data)
+}
+
+// packed repeated varints
+func (b *protoBuffer) packed(field int, v []uint64) {
+	b.message(field, func(m *protoBuffer) {
+		for _, i := range v {
+			m.varint(i)
+		}
+	})
+}
+
+// WriteProfile writes the call tree as a pprof profile, which can be opened
+// with go tool pprof. Every routine is a function, its samples being the
+// cycles it ran for under each chain of callers. The code without a caller
+// is the (root) function
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	strings := []string{""}
+	str := func(s string) uint64 {
+		strings = append(strings, s)
+		return uint64(len(strings) - 1)
+	}
+
+	b := &protoBuffer{}
+	cycles, count := str("cycles"), str("count")
+	valueType := func(m *protoBuffer) {
+		m.uint64(pprofValueTypeType, cycles)
+		m.uint64(pprofValueTypeUnit, count)
+	}
+	b.message(pprofSampleType, valueType)
+
+	// a location and a function per routine, the root being the first
+	function := func(id uint64, addr uint16, name string) {
+		b.message(pprofFunction, func(m *protoBuffer) {
+			m.uint64(pprofFunctionId, id)
+			m.uint64(pprofFunctionName, str(name))
+			m.uint64(pprofFunctionSystemName, str(name))
+		})
+		b.message(pprofLocation, func(m *protoBuffer) {
+			m.uint64(pprofLocationId, id)
+			m.uint64(pprofLocationAddress, uint64(addr))
+			m.message(pprofLocationLine, func(l *protoBuffer) {
+				l.uint64(pprofLineFunctionId, id)
+			})
+		})
+	}
+	function(1, 0, "(root)")
+	p.root.id = 1
+	ids := map[RoutineKey]uint64{}
+	var locate func(node *callNode)
+	locate = func(node *callNode) {
+		for _, child := range node.children {
+			id, ok := ids[child.key]
+			if !ok {
+				id = uint64(len(ids) + 2)
+				ids[child.key] = id
+				function(id, child.key.Addr, p.name(child.key))
+			}
+			child.id = id
+			locate(child)
+		}
+	}
+	locate(p.root)
+
+	var sample func(node *callNode)
+	sample = func(node *callNode) {
+		if node.cycles > 0 {
+			var stack []uint64
+			for n := node; n != nil; n = n.parent {
+				stack = append(stack, n.id)
+			}
+			b.message(pprofSample, func(m *protoBuffer) {
+				m.packed(pprofSampleLocationId, stack)
+				m.packed(pprofSampleValue, []uint64{uint64(node.cycles)})
+			})
+		}
+		for _, child := range node.children {
+			sample(child)
+		}
+	}
+	sample(p.root)
+
+	b.message(pprofPeriodType, valueType)
+	b.uint64(pprofPeriod, 1)
+	for _, s := range strings {
+		b.bytes(pprofStringTable, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b.data); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,299 @@
+package cpu
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// ProfileContext tells the main code apart from the interrupt handlers
+type ProfileContext int
+
+const (
+	ProfileMain ProfileContext = iota
+	ProfileNMI
+	ProfileIRQ
+)
+
+func (c ProfileContext) String() string {
+	return [...]string{"main", "NMI", "IRQ"}[c]
+}
+
+// RoutineKey is where a routine starts, the same address in two PRG banks
+// being two different routines
+type RoutineKey struct {
+	// the 8 KB PRG-ROM bank, -1 outside of the PRG-ROM
+	Bank int
+	Addr uint16
+}
+
+// Routine is the cycle count of a subroutine, or an interrupt handler
+type Routine struct {
+	RoutineKey
+	Name  string
+	Calls int
+	// the cycles spent in the routine and what it called, and in the
+	// routine alone
+	Inclusive int
+	Exclusive int
+}
+
+// a routine as called from a chain of routines, the call tree
+type callNode struct {
+	key      RoutineKey
+	parent   *callNode
+	children map[RoutineKey]*callNode
+	calls    int
+	// exclusive cycles
+	cycles int
+	// the id of the pprof location, see WriteProfile
+	id uint64
+}
+
+func (n *callNode) child(key RoutineKey) *callNode {
+	if c, ok := n.children[key]; ok {
+		return c
+	}
+	c := &callNode{key: key, parent: n, children: map[RoutineKey]*callNode{}}
+	n.children[key] = c
+	return c
+}
+
+// the call tree node of a frame of the cpu call stack
+type profileFrame struct {
+	node    *callNode
+	context ProfileContext
+	id      uint64
+}
+
+// Profiler counts the cycles of every instruction, and of every routine
+// called by JSR or by an interrupt, as found on the cpu call stack
+type Profiler struct {
+	bank   func(addr uint16) int
+	labels func(addr uint16) (string, bool)
+
+	// the code not called from anywhere, eg: from the reset
+	root   *callNode
+	frames []profileFrame
+
+	pcs      map[RoutineKey]int
+	contexts [3]int
+}
+
+// NewProfiler names the PRG bank of a cpu address with bank, which returns -1
+// outside of the PRG-ROM, and the routines with the optional labels
+func NewProfiler(bank func(addr uint16) int, labels func(addr uint16) (string, bool)) *Profiler {
+	p := &Profiler{bank: bank, labels: labels}
+	p.Reset()
+	return p
+}
+
+// Reset forgets everything counted so far
+func (p *Profiler) Reset() {
+	p.root = &callNode{key: RoutineKey{Bank: -1}, children: map[RoutineKey]*callNode{}}
+	p.frames = p.frames[:0]
+	p.pcs = map[RoutineKey]int{}
+	p.contexts = [3]int{}
+}
+
+// Profile counts the cycles of every instruction from now on, a nil p stops
+// the profiling
+func (c *Cpu) Profile(p *Profiler) {
+	c.profiler = p
+}
+
+func (p *Profiler) key(addr uint16) RoutineKey {
+	return RoutineKey{Bank: p.bank(addr), Addr: addr}
+}
+
+func (p *Profiler) push(f CallFrame) {
+	parent, context := p.root, ProfileMain
+	if len(p.frames) > 0 {
+		top := &p.frames[len(p.frames)-1]
+		parent, context = top.node, top.context
+	}
+	if f.Interrupt {
+		context = ProfileIRQ
+		if f.NMI {
+			context = ProfileNMI
+		}
+	}
+	node := parent.child(p.key(f.Entry))
+	node.calls++
+	p.frames = append(p.frames, profileFrame{node: node, context: context, id: f.id})
+}
+
+// drops the frames the cpu returned from, or dropped as the oldest of a too
+// deep stack
+func (p *Profiler) sync(calls []CallFrame) {
+	for len(p.frames) > 0 && len(calls) > 0 && p.frames[0].id < calls[0].id {
+		p.frames = p.frames[1:]
+	}
+	n := 0
+	for n < len(p.frames) && n < len(calls) && p.frames[n].id == calls[n].id {
+		n++
+	}
+	p.frames = p.frames[:n]
+}
+
+// tick counts an instruction which started at pc, or the interrupt sequence
+// run instead of it
+func (p *Profiler) tick(c *Cpu, pc uint16, cycles int) {
+	p.sync(c.calls.frames)
+	fresh := c.calls.frames[len(p.frames):]
+
+	// the interrupt sequence is counted in the handler, a JSR in its caller
+	counted := len(fresh)
+	if counted > 0 && !fresh[counted-1].Interrupt {
+		counted--
+	}
+	for _, f := range fresh[:counted] {
+		p.push(f)
+	}
+
+	node, context := p.root, ProfileMain
+	if len(p.frames) > 0 {
+		top := &p.frames[len(p.frames)-1]
+		node, context = top.node, top.context
+	}
+	node.cycles += cycles
+	p.contexts[context] += cycles
+	p.pcs[p.key(pc)] += cycles
+
+	for _, f := range fresh[counted:] {
+		p.push(f)
+	}
+}
+
+// Cycles returns the cycles counted in the main code, in the NMI handlers and
+// in the IRQ handlers, BRK included
+func (p *Profiler) Cycles() (main, nmi, irq int) {
+	return p.contexts[ProfileMain], p.contexts[ProfileNMI], p.contexts[ProfileIRQ]
+}
+
+// PcCycles returns the cycles counted for the instruction at a cpu address,
+// in a PRG bank
+func (p *Profiler) PcCycles(key RoutineKey) int {
+	return p.pcs[key]
+}
+
+func (p *Profiler) name(key RoutineKey) string {
+	if p.labels != nil && key.Bank == p.bank(key.Addr) {
+		if name, ok := p.labels(key.Addr); ok {
+			return name
+		}
+	}
+	if key.Bank < 0 {
+		return fmt.Sprintf("$%04X", key.Addr)
+	}
+	return fmt.Sprintf("$%04X@%d", key.Addr, key.Bank)
+}
+
+// adds up the subtree under node, a recursive routine only counts the calls
+// from outside of itself as inclusive
+func (p *Profiler) collect(node *callNode, routines map[RoutineKey]*Routine, active map[RoutineKey]int) int {
+	total := node.cycles
+	if node != p.root {
+		active[node.key]++
+	}
+	for _, child := range node.children {
+		total += p.collect(child, routines, active)
+	}
+	if node == p.root {
+		return total
+	}
+	active[node.key]--
+
+	r, ok := routines[node.key]
+	if !ok {
+		r = &Routine{RoutineKey: node.key, Name: p.name(node.key)}
+		routines[node.key] = r
+	}
+	r.Calls += node.calls
+	r.Exclusive += node.cycles
+	if active[node.key] == 0 {
+		r.Inclusive += total
+	}
+	return total
+}
+
+// Routines returns the routines called so far, the most inclusive cycles
+// first
+func (p *Profiler) Routines() []Routine {
+	routines := map[RoutineKey]*Routine{}
+	p.collect(p.root, routines, map[RoutineKey]int{})
+
+	list := make([]Routine, 0, len(routines))
+	for _, r := range routines {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Inclusive != list[j].Inclusive {
+			return list[i].Inclusive > list[j].Inclusive
+		}
+		if list[i].Bank != list[j].Bank {
+			return list[i].Bank < list[j].Bank
+		}
+		return list[i].Addr < list[j].Addr
+	})
+	return list
+}
+
+// Report writes the top routines and instructions, the cycles per frame are
+// worked out from the number of frames profiled, eg:
+//
+//	frames 60, cycles 1790400 (29840 per frame): main 1250000, NMI 540400, IRQ 0
+//
+//	routine          calls  inclusive  exclusive  incl/frame
+//	nmi                 60     540400     120000      9006.7
+func (p *Profiler) Report(w io.Writer, top int, frames int) error {
+	main, nmi, irq := p.Cycles()
+	total := main + nmi + irq
+	perFrame := func(cycles int) float64 {
+		if frames == 0 {
+			return 0
+		}
+		return float64(cycles) / float64(frames)
+	}
+
+	fmt.Fprintf(w, "frames %d, cycles %d (%.0f per frame): main %d, NMI %d, IRQ %d\n\n",
+		frames, total, perFrame(total), main, nmi, irq)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "routine\tcalls\tinclusive\texclusive\tincl/frame\t")
+	routines := p.Routines()
+	if top > 0 && len(routines) > top {
+		routines = routines[:top]
+	}
+	for _, r := range routines {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.1f\t\n", r.Name, r.Calls, r.Inclusive, r.Exclusive, perFrame(r.Inclusive))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	pcs := make([]RoutineKey, 0, len(p.pcs))
+	for key := range p.pcs {
+		pcs = append(pcs, key)
+	}
+	sort.Slice(pcs, func(i, j int) bool {
+		if p.pcs[pcs[i]] != p.pcs[pcs[j]] {
+			return p.pcs[pcs[i]] > p.pcs[pcs[j]]
+		}
+		if pcs[i].Bank != pcs[j].Bank {
+			return pcs[i].Bank < pcs[j].Bank
+		}
+		return pcs[i].Addr < pcs[j].Addr
+	})
+	if top > 0 && len(pcs) > top {
+		pcs = pcs[:top]
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "instruction\tcycles\tper frame\t")
+	for _, key := range pcs {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t\n", p.name(key), p.pcs[key], perFrame(p.pcs[key]))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package cpu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	c, bus := newCpu(Variant2A03,
+		0x20, 0x10, 0x02, // JSR sub
+		0x20, 0x10, 0x02, // JSR sub
+		0x4C, 0x06, 0x02, // JMP *
+	)
+	copy(bus.mem[0x0210:], []uint8{0x20, 0x20, 0x02, 0x60}) // sub: JSR leaf, RTS
+	copy(bus.mem[0x0220:], []uint8{0xEA, 0x60})             // leaf: NOP, RTS
+	copy(bus.mem[0x0230:], []uint8{0xEA, 0x40})             // nmi: NOP, RTI
+	bus.mem[0xFFFA] = 0x30
+
+	labels := map[uint16]string{0x0210: "sub", 0x0220: "leaf", 0x0230: "nmi"}
+	p := NewProfiler(func(uint16) int { return 0 }, func(addr uint16) (string, bool) {
+		name, ok := labels[addr]
+		return name, ok
+	})
+	c.Profile(p)
+
+	run(c, 10)
+	c.Raise(CpuIntNMI)
+	// the JMP, the interrupt sequence, NOP and RTI
+	run(c, 4)
+	if pc := c.Rg.Spc.Pc.Read(); pc != 0x0206 {
+		t.Fatalf("pc at $%04X", pc)
+	}
+
+	if main, nmi, irq := p.Cycles(); main != 55 || nmi != 15 || irq != 0 {
+		t.Errorf("main %d, NMI %d, IRQ %d cycles, expected 55, 15 and 0", main, nmi, irq)
+	}
+	expected := map[string]Routine{
+		"sub":  {Calls: 2, Inclusive: 40, Exclusive: 24},
+		"leaf": {Calls: 2, Inclusive: 16, Exclusive: 16},
+		"nmi":  {Calls: 1, Inclusive: 15, Exclusive: 15},
+	}
+	routines := p.Routines()
+	if len(routines) != len(expected) {
+		t.Fatalf("%d routines, expected %d", len(routines), len(expected))
+	}
+	for _, r := range routines {
+		e := expected[r.Name]
+		if r.Calls != e.Calls || r.Inclusive != e.Inclusive || r.Exclusive != e.Exclusive {
+			t.Errorf("%s: %d calls, %d inclusive, %d exclusive, expected %d, %d and %d",
+				r.Name, r.Calls, r.Inclusive, r.Exclusive, e.Calls, e.Inclusive, e.Exclusive)
+		}
+	}
+	if cycles := p.PcCycles(RoutineKey{Bank: 0, Addr: 0x0200}); cycles != 6 {
+		t.Errorf("the first JSR took %d cycles", cycles)
+	}
+
+	var report bytes.Buffer
+	if err := p.Report(&report, 10, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report.String(), "cycles 70 (70 per frame): main 55, NMI 15, IRQ 0") {
+		t.Errorf("report:\n%s", report.String())
+	}
+
+	var profile bytes.Buffer
+	if err := p.WriteProfile(&profile); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"(root)", "sub", "leaf", "nmi", "cycles"} {
+		if !bytes.Contains(data, []byte(name)) {
+			t.Errorf("%s is not in the profile", name)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cpu
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Trace logs every instruction in the Nintendulator format used by the
+// nestest.log reference, eg:
+//
+// C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7
+//
+// The line is written right before the instruction runs. The memory shown by
+// the disassembly is read through peek, which should have no side effects,
+// and the PPU position is only printed if ppu is set. A nil w stops the trace,
+// see SetTracer for the other fields and the filters
+func (c *Cpu) Trace(w io.Writer, peek func(uint16) uint8, ppu func() (scanline, dot int)) {
+	c.TraceSources(peek, ppu)
+	c.tracer = nil
+	if w != nil {
+		c.tracer = NewTracer(w, TraceNintendulator, TraceFilter{Bank: -1})
+	}
+}
+
+// TraceSources sets where the trace reads the memory and the PPU position
+// from, as Trace does
+func (c *Cpu) TraceSources(peek func(uint16) uint8, ppu func() (scanline, dot int)) {
+	c.tracePeek = peek
+	c.tracePpu = ppu
+}
+
+// TraceLabels names the addresses in the trace, the labelled instructions
+// get a "name:" line before them and the jumps and branches show the name of
+// their target
+func (c *Cpu) TraceLabels(labels func(addr uint16) (string, bool)) {
+	c.traceLabels = labels
+}
+
+// the name of a jump target, or its address
+func (c *Cpu) traceTarget(addr uint16) string {
+	if c.traceLabels != nil {
+		if name, ok := c.traceLabels(addr); ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("$%04X", addr)
+}
+
+func (c *Cpu) tracePeek8(addr uint16) uint8 {
+	if c.tracePeek != nil {
+		return c.tracePeek(addr)
+	}
+	return c.Read8(addr)
+}
+func (c *Cpu) tracePeek16(addr uint16) uint16 {
+	return uint16(c.tracePeek8(addr)) | uint16(c.tracePeek8(addr+1))<<8
+}
+
+// pointers wrap around the zero page
+func (c *Cpu) tracePeekZp16(ptr uint8) uint16 {
+	return uint16(c.tracePeek8(uint16(ptr))) | uint16(c.tracePeek8(uint16(ptr+1)))<<8
+}
+
+// the bank is only shown with the TraceBank field
+func (c *Cpu) traceLine(fields TraceFields, bank int) string {
+	pc := c.Rg.Spc.Pc.Read()
+	ins := &c.ins[c.tracePeek8(pc)]
+
+	bytes := make([]string, ins.opLength)
+	for i := range bytes {
+		bytes[i] = fmt.Sprintf("%02X", c.tracePeek8(pc+uint16(i)))
+	}
+
+	name := " " + ins.opName
+	if ins.unofficial {
+		name = "*" + ins.opName
+	}
+	if operand := c.traceOperand(ins, pc); operand != "" {
+		name += " " + operand
+	}
+
+	line := fmt.Sprintf("%04X  %-8s %-33s", pc, strings.Join(bytes, " "), name)
+	if fields&TraceRegisters != 0 {
+		line += fmt.Sprintf("A:%02X X:%02X Y:%02X P:%02X SP:%02X",
+			c.Rg.Gp.Ac.Read(), c.Rg.Gp.Ix.X.Read(), c.Rg.Gp.Ix.Y.Read(),
+			c.Rg.Spc.Ps.Read(), c.Rg.Spc.Sp.Read())
+	}
+	if fields&TracePpu != 0 && c.tracePpu != nil {
+		scanline, dot := c.tracePpu()
+		line += fmt.Sprintf(" PPU:%3d,%3d", scanline, dot)
+	}
+	if fields&TraceCycles != 0 {
+		line += fmt.Sprintf(" CYC:%d", c.clk)
+	}
+	if fields&TraceBank != 0 {
+		line += fmt.Sprintf(" BANK:%d", bank)
+	}
+	line = strings.TrimRight(line, " ")
+	if c.traceLabels != nil {
+		if name, ok := c.traceLabels(pc); ok {
+			line = name + ":\n" + line
+		}
+	}
+	return line
+}
+
+// the operand as disassembled by Nintendulator, with the effective address
+// and the value there before the instruction runs
+func (c *Cpu) traceOperand(ins *Instruction, pc uint16) string {
+	op1 := c.tracePeek8(pc + 1)
+	op12 := c.tracePeek16(pc + 1)
+	x := c.Rg.Gp.Ix.X.Read()
+	y := c.Rg.Gp.Ix.Y.Read()
+
+	switch ins.addrMode {
+	case ModeAccumulator:
+		return "A"
+	case ModeImmediate:
+		return fmt.Sprintf("#$%02X", op1)
+	case ModeZeroPage:
+		return fmt.Sprintf("$%02X = %02X", op1, c.tracePeek8(uint16(op1)))
+	case ModeIndexedZeroPageX:
+		addr := op1 + x
+		return fmt.Sprintf("$%02X,X @ %02X = %02X", op1, addr, c.tracePeek8(uint16(addr)))
+	case ModeIndexedZeroPageY:
+		addr := op1 + y
+		return fmt.Sprintf("$%02X,Y @ %02X = %02X", op1, addr, c.tracePeek8(uint16(addr)))
+	case ModeAbsolute:
+		if ins.opName == "JMP" || ins.opName == "JSR" {
+			return c.traceTarget(op12)
+		}
+		return fmt.Sprintf("$%04X = %02X", op12, c.tracePeek8(op12))
+	case ModeIndexedAbsoluteX:
+		addr := op12 + uint16(x)
+		return fmt.Sprintf("$%04X,X @ %04X = %02X", op12, addr, c.tracePeek8(addr))
+	case ModeIndexedAbsoluteY:
+		addr := op12 + uint16(y)
+		return fmt.Sprintf("$%04X,Y @ %04X = %02X", op12, addr, c.tracePeek8(addr))
+	case ModeIndexedIndirectX:
+		ptr := op1 + x
+		addr := c.tracePeekZp16(ptr)
+		return fmt.Sprintf("($%02X,X) @ %02X = %04X = %02X", op1, ptr, addr, c.tracePeek8(addr))
+	case ModeIndirectIndexedY:
+		base := c.tracePeekZp16(op1)
+		addr := base + uint16(y)
+		return fmt.Sprintf("($%02X),Y = %04X @ %04X = %02X", op1, base, addr, c.tracePeek8(addr))
+	case ModeIndirect:
+		addr := c.tracePeek16(op12)
+		if !c.cmos() {
+			// the page wrap bug
+			addr = uint16(c.tracePeek8(op12)) | uint16(c.tracePeek8(op12&0xFF00|uint16(uint8(op12)+1)))<<8
+		}
+		return fmt.Sprintf("($%04X) = %04X", op12, addr)
+	case ModeRelative:
+		return c.traceTarget(pc + 2 + uint16(int8(op1)))
+	case ModeZeroPageIndirect:
+		addr := c.tracePeekZp16(op1)
+		return fmt.Sprintf("($%02X) = %04X = %02X", op1, addr, c.tracePeek8(addr))
+	case ModeIndexedAbsoluteIndirectX:
+		return fmt.Sprintf("($%04X,X) = %04X", op12, c.tracePeek16(op12+uint16(x)))
+	case ModeZeroPageRelative:
+		op2 := c.tracePeek8(pc + 2)
+		return fmt.Sprintf("$%02X = %02X, %s", op1, c.tracePeek8(uint16(op1)), c.traceTarget(pc+3+uint16(int8(op2))))
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package cpu
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// TraceFields picks what follows the disassembly on the trace lines
+type TraceFields uint8
+
+const (
+	// A:00 X:00 Y:00 P:24 SP:FD
+	TraceRegisters TraceFields = 1 << iota
+	// PPU:  0, 21, the scanline and the dot
+	TracePpu
+	// CYC:7
+	TraceCycles
+	// BANK:3, the 8 KB PRG-ROM bank, see Tracer.Banks
+	TraceBank
+
+	// the fields of the nestest.log reference
+	TraceNintendulator = TraceRegisters | TracePpu | TraceCycles
+)
+
+var traceFieldNames = []string{"regs", "ppu", "cycles", "bank"}
+
+// ParseTraceFields parses a comma separated list of fields, eg:
+// "regs,ppu,cycles,bank"
+func ParseTraceFields(s string) (TraceFields, error) {
+	var fields TraceFields
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for i, field := range traceFieldNames {
+			if name == field {
+				fields |= 1 << i
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown trace field %q, expected one of %s", name, strings.Join(traceFieldNames, ","))
+		}
+	}
+	return fields, nil
+}
+
+// TraceFilter picks the instructions which are traced
+type TraceFilter struct {
+	// the cpu addresses, both included, any if To is 0
+	From, To uint16
+	// the 8 KB PRG-ROM bank, any if negative
+	Bank int
+	// only the instructions run by an NMI handler
+	NmiOnly bool
+}
+
+// Tracer writes the instruction trace, a line right before each instruction
+// runs. In the ring buffer mode only the last lines are kept, they're dumped
+// when the cpu jams on an invalid opcode or when the emulation panics
+type Tracer struct {
+	mu sync.Mutex
+
+	w      io.Writer
+	fields TraceFields
+	filter TraceFilter
+	bank   func(addr uint16) int
+
+	// the ring buffer, nil unless in that mode
+	ring []string
+	next int
+	full bool
+}
+
+// NewTracer traces to w with the fields, the filtered instructions only
+func NewTracer(w io.Writer, fields TraceFields, filter TraceFilter) *Tracer {
+	return &Tracer{w: w, fields: fields, filter: filter}
+}
+
+// Banks names the PRG bank of a cpu address, -1 outside of the PRG-ROM, it's
+// needed by the TraceBank field and the bank filter
+func (t *Tracer) Banks(bank func(addr uint16) int) {
+	t.bank = bank
+}
+
+// Ring keeps the last n lines instead of writing them, see Dump. The lines
+// are written again if n is 0
+func (t *Tracer) Ring(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ring = nil
+	if n > 0 {
+		t.ring = make([]string, n)
+	}
+	t.next = 0
+	t.full = false
+}
+
+// Dump writes the lines kept by the ring buffer, the oldest first, after a
+// header line with the reason
+func (t *Tracer) Dump(reason string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dump(reason)
+}
+
+func (t *Tracer) dump(reason string) error {
+	if t.ring == nil {
+		return nil
+	}
+	lines := t.ring[:t.next]
+	if t.full {
+		lines = append(t.ring[t.next:len(t.ring):len(t.ring)], lines...)
+	}
+	if _, err := fmt.Fprintf(t.w, "--- %s, the last %d instructions:\n", reason, len(lines)); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(t.w, line); err != nil {
+			return err
+		}
+	}
+	t.next = 0
+	t.full = false
+	if f, ok := t.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// SetTracer traces the instructions from now on, a nil t stops the trace
+func (c *Cpu) SetTracer(t *Tracer) {
+	c.tracer = t
+}
+
+// traces the instruction at the PC, if it goes through the filter
+func (t *Tracer) trace(c *Cpu) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pc := c.Rg.Spc.Pc.Read()
+
+	f := &t.filter
+	if f.To != 0 && (pc < f.From || pc > f.To) {
+		return
+	}
+	if f.NmiOnly && !c.calls.inNmi() {
+		return
+	}
+	bank := -1
+	if t.bank != nil && (f.Bank >= 0 || t.fields&TraceBank != 0) {
+		bank = t.bank(pc)
+	}
+	if f.Bank >= 0 && bank != f.Bank {
+		return
+	}
+
+	line := c.traceLine(t.fields, bank)
+	if t.ring == nil {
+		fmt.Fprintln(t.w, line)
+		return
+	}
+	t.ring[t.next] = line
+	t.next++
+	if t.next == len(t.ring) {
+		t.next = 0
+		t.full = true
+	}
+}
+
+// dumps the ring buffer on a panic, which carries on
+func (t *Tracer) dumpOnPanic() {
+	if r := recover(); r != nil {
+		t.mu.Lock()
+		_ = t.dump(fmt.Sprintf("panic: %v", r))
+		t.mu.Unlock()
+		panic(r)
+	}
+}
@@ -0,0 +1,88 @@
+package cpu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTracerFilter(t *testing.T) {
+	c, bus := newCpu(Variant2A03,
+		0xEA,             // NOP
+		0x4C, 0x00, 0x02, // JMP $0200
+	)
+	copy(bus.mem[0x0230:], []uint8{0xEA, 0x40}) // nmi: NOP, RTI
+	bus.mem[0xFFFA] = 0x30
+
+	var trace strings.Builder
+	tracer := NewTracer(&trace, TraceCycles|TraceBank, TraceFilter{Bank: 5, NmiOnly: true})
+	tracer.Banks(func(addr uint16) int { return 5 })
+	c.SetTracer(tracer)
+
+	run(c, 2)
+	c.Raise(CpuIntNMI)
+	// NOP, the interrupt sequence, NOP, RTI and NOP again
+	run(c, 5)
+
+	lines := strings.Split(strings.TrimRight(trace.String(), "\n"), "\n")
+	if len(lines) != 2 || lines[0] != "0230  EA        NOP                              CYC:14 BANK:5" ||
+		!strings.HasPrefix(lines[1], "0231  40        RTI") {
+		t.Errorf("trace:\n%s", trace.String())
+	}
+}
+
+// panics on the writes to $0300
+type panicBus struct {
+	testBus
+}
+
+func (b *panicBus) Write8(addr uint16, val uint8) {
+	if addr == 0x0300 {
+		panic("bad write")
+	}
+	b.testBus.Write8(addr, val)
+}
+
+func TestTracerRing(t *testing.T) {
+	// NOP; NOP; NOP; KIL
+	c, _ := newCpu(Variant2A03, 0xEA, 0xEA, 0xEA, 0x02)
+	var trace strings.Builder
+	tracer := NewTracer(&trace, TraceRegisters, TraceFilter{Bank: -1})
+	tracer.Ring(2)
+	c.SetTracer(tracer)
+
+	run(c, 3)
+	if trace.Len() != 0 {
+		t.Fatalf("the ring buffer was written:\n%s", trace.String())
+	}
+	run(c, 1)
+	lines := strings.Split(strings.TrimRight(trace.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "--- KIL at $0203, the last 2 instructions:" ||
+		!strings.HasPrefix(lines[1], "0202  EA") || !strings.HasPrefix(lines[2], "0203  02       *KIL") {
+		t.Errorf("trace:\n%s", trace.String())
+	}
+
+	// NOP; STA $0300
+	bus := &panicBus{}
+	copy(bus.mem[testCodeAddr:], []uint8{0xEA, 0x8D, 0x00, 0x03})
+	bus.mem[0xFFFC] = uint8(testCodeAddr & 0xFF)
+	bus.mem[0xFFFD] = uint8(testCodeAddr >> 8)
+	c = &Cpu{}
+	c.Init(bus, nil, Variant2A03, false)
+	c.Reset()
+	trace.Reset()
+	c.SetTracer(tracer)
+
+	func() {
+		defer func() {
+			if r := recover(); r != "bad write" {
+				t.Errorf("recovered %v", r)
+			}
+		}()
+		run(c, 2)
+	}()
+	lines = strings.Split(strings.TrimRight(trace.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "--- panic: bad write, the last 2 instructions:" ||
+		!strings.HasPrefix(lines[2], "0201  8D 00 03  STA $0300") {
+		t.Errorf("trace:\n%s", trace.String())
+	}
+}
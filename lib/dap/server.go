@@ -0,0 +1,557 @@
+package dap
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	gones "github.com/tiagolobocastro/gones/lib"
+	"github.com/tiagolobocastro/gones/lib/debugger"
+	"github.com/tiagolobocastro/gones/lib/speakers"
+	"github.com/tiagolobocastro/gones/lib/symbols"
+)
+
+// the cpu is the only thread
+const threadID = 1
+
+// the variables references of the scopes
+const (
+	registersRef = iota + 1
+	ppuRef
+)
+
+// stepping by source line repeats the instruction steps, up to this many
+const maxLineSteps = 100000
+
+type session struct {
+	conn *conn
+
+	nes  gones.GoNes
+	d    *debugger.Debugger
+	info *symbols.DebugInfo
+
+	stopOnEntry bool
+	// closed on the first stop, the emulator is paused on entry until
+	// configured
+	entered chan struct{}
+	done    chan struct{}
+
+	mu sync.Mutex
+	// the debugger breakpoints set by each request, source breakpoints
+	// are keyed by path and the rest by the request name
+	breakpoints map[string][]int
+	// the DAP id of each debugger breakpoint, a source line can have
+	// several addresses
+	ids map[int]int
+	// the line being stepped out of, nil unless stepping by line
+	stepLine *symbols.Line
+	stepOver bool
+	steps    int
+}
+
+// Serve runs a debug session over rw until the client disconnects
+func Serve(rw io.ReadWriter) error {
+	s := &session{
+		conn:        newConn(rw),
+		info:        &symbols.DebugInfo{},
+		entered:     make(chan struct{}),
+		done:        make(chan struct{}),
+		breakpoints: make(map[string][]int),
+		ids:         make(map[int]int),
+	}
+	defer close(s.done)
+
+	for {
+		req, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		body, err := s.handle(req)
+		if err != nil {
+			err = s.conn.fail(req, err)
+		} else {
+			err = s.conn.respond(req, body)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch req.Command {
+		case "launch":
+			err = s.conn.event("initialized", nil)
+		case "configurationDone":
+			err = s.configured()
+		case "disconnect", "terminate":
+			if s.nes != nil {
+				s.nes.Stop()
+			}
+			_ = s.conn.event("terminated", nil)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *session) handle(req *request) (interface{}, error) {
+	if s.d == nil {
+		switch req.Command {
+		case "initialize", "launch", "disconnect", "terminate":
+		default:
+			return nil, fmt.Errorf("%s before launch", req.Command)
+		}
+	}
+
+	switch req.Command {
+	case "initialize":
+		return capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsInstructionBreakpoints:   true,
+			SupportsSteppingGranularity:      true,
+			SupportsReadMemoryRequest:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		args := launchArguments{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "configurationDone", "disconnect", "terminate", "setExceptionBreakpoints":
+		return nil, nil
+
+	case "setBreakpoints":
+		args := setBreakpointsArguments{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "setFunctionBreakpoints":
+		args := setFunctionBreakpointsArguments{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setFunctionBreakpoints(args), nil
+	case "setInstructionBreakpoints":
+		args := setInstructionBreakpointsArguments{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setInstructionBreakpoints(args), nil
+
+	case "threads":
+		return map[string]interface{}{"threads": []thread{{threadID, "6502"}}}, nil
+	case "stackTrace":
+		frames := s.stackTrace()
+		return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+	case "scopes":
+		return map[string]interface{}{"scopes": []scope{
+			{Name: "Registers", VariablesReference: registersRef},
+			{Name: "PPU", VariablesReference: ppuRef},
+		}}, nil
+	case "variables":
+		args := struct {
+			VariablesReference int `json:"variablesReference"`
+		}{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"variables": s.variables(args.VariablesReference)}, nil
+	case "evaluate":
+		args := evaluateArguments{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		v, err := s.d.Eval(args.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"result": fmt.Sprintf("$%X (%d)", v, v), "variablesReference": 0}, nil
+	case "readMemory":
+		args := readMemoryArguments{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.readMemory(args)
+
+	case "continue":
+		return map[string]bool{"allThreadsContinued": true}, s.d.Continue()
+	case "pause":
+		s.d.Pause()
+		return nil, nil
+	case "next", "stepIn", "stepOut":
+		args := stepArguments{}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.step(req.Command, args.Granularity)
+	}
+	return nil, fmt.Errorf("unsupported request %s", req.Command)
+}
+
+func (s *session) launch(args launchArguments) (err error) {
+	if s.nes != nil {
+		return fmt.Errorf("already launched")
+	}
+	if stat, err := os.Stat(args.Program); err != nil || stat.IsDir() {
+		return fmt.Errorf("invalid program %q", args.Program)
+	}
+
+	info, err := symbols.Load(args.Program)
+	if args.DebugFile != "" {
+		info, err = symbols.LoadFile(args.DebugFile, args.Program)
+	}
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		s.info = info
+	}
+
+	if args.Audio == "" {
+		args.Audio = speakers.Beep
+	}
+	// the emulator panics on bad roms
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to launch %s: %v", args.Program, r)
+		}
+	}()
+	s.nes = gones.NewNES(
+		gones.CartPath(args.Program),
+		gones.AudioLibrary(args.Audio),
+		gones.Headless(args.Headless),
+		gones.Debug(true),
+	)
+	s.d = s.nes.Debugger()
+	s.d.SetSymbols(s.info)
+	s.stopOnEntry = args.StopOnEntry
+
+	// nothing runs until the breakpoints are set
+	s.d.Pause()
+	go s.forwardStops()
+	go s.nes.Run()
+	return nil
+}
+
+// the client is done setting the breakpoints
+func (s *session) configured() error {
+	<-s.entered
+	if s.stopOnEntry {
+		return s.conn.event("stopped", stoppedBody{Reason: "entry", ThreadID: threadID, AllThreadsStopped: true})
+	}
+	return s.d.Continue()
+}
+
+func (s *session) forwardStops() {
+	entry := true
+	for {
+		var stop debugger.Stop
+		select {
+		case <-s.done:
+			return
+		case stop = <-s.d.Stops():
+		}
+		if entry {
+			entry = false
+			close(s.entered)
+			continue
+		}
+
+		body := stoppedBody{ThreadID: threadID, AllThreadsStopped: true}
+		switch stop.Reason {
+		case debugger.ReasonPause:
+			body.Reason = "pause"
+		case debugger.ReasonStep:
+			if s.stepAgain(stop.PC) {
+				continue
+			}
+			body.Reason = "step"
+		case debugger.ReasonBreakpoint:
+			body.Reason = "breakpoint"
+			if stop.Breakpoint.Kind&debugger.Exec == 0 {
+				body.Reason = "data breakpoint"
+			}
+			s.mu.Lock()
+			if id, ok := s.ids[stop.Breakpoint.ID]; ok {
+				body.HitBreakpointIds = []int{id}
+			}
+			s.mu.Unlock()
+		}
+		s.mu.Lock()
+		s.stepLine = nil
+		s.mu.Unlock()
+		_ = s.conn.event("stopped", body)
+	}
+}
+
+func (s *session) step(command string, granularity string) error {
+	s.mu.Lock()
+	s.stepLine = nil
+	if line, ok := s.lineAt(s.pc()); ok && granularity != "instruction" && command != "stepOut" {
+		s.stepLine = &line
+		s.stepOver = command == "next"
+		s.steps = 0
+	}
+	s.mu.Unlock()
+
+	switch command {
+	case "next":
+		return s.d.StepOver()
+	case "stepIn":
+		return s.d.StepInto()
+	}
+	return s.d.StepOut()
+}
+
+// keeps stepping while on the same source line, eg: a macro
+func (s *session) stepAgain(pc uint16) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stepLine == nil || s.steps == maxLineSteps {
+		return false
+	}
+	line, ok := s.lineAt(pc)
+	if !ok || line.File != s.stepLine.File || line.Line != s.stepLine.Line {
+		return false
+	}
+
+	s.steps++
+	if s.stepOver {
+		return s.d.StepOver() == nil
+	}
+	return s.d.StepInto() == nil
+}
+
+// the source of the instruction at pc, in the bank mapped there
+func (s *session) lineAt(pc uint16) (symbols.Line, bool) {
+	return s.info.LineAt(pc, s.d.Target().PrgFileOffset(pc))
+}
+
+func (s *session) pc() uint16 {
+	return s.d.Target().CPU().Rg.Spc.Pc.Read()
+}
+
+// replaces the breakpoints of a request
+func (s *session) replaceBreakpoints(key string, add func() []breakpoint) []breakpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.breakpoints[key] {
+		_ = s.d.RemoveBreakpoint(id)
+		delete(s.ids, id)
+	}
+	s.breakpoints[key] = nil
+	return add()
+}
+
+// adds an execution breakpoint on each address, the DAP id is the first one
+func (s *session) addBreakpoints(key string, addrs []uint16, condition string) breakpoint {
+	bps := make([]debugger.Breakpoint, len(addrs))
+	for i, addr := range addrs {
+		bps[i] = debugger.Breakpoint{Kind: debugger.Exec, Start: addr}
+	}
+	return s.add(key, bps, condition)
+}
+
+func (s *session) add(key string, bps []debugger.Breakpoint, condition string) breakpoint {
+	bp := breakpoint{}
+	for _, b := range bps {
+		b.Condition = condition
+		id, err := s.d.AddBreakpoint(b)
+		if err != nil {
+			return breakpoint{Message: err.Error()}
+		}
+		if bp.ID == 0 {
+			bp.ID = id
+		}
+		s.ids[id] = bp.ID
+		s.breakpoints[key] = append(s.breakpoints[key], id)
+	}
+	bp.Verified = bp.ID != 0
+	return bp
+}
+
+func (s *session) setBreakpoints(args setBreakpointsArguments) breakpointsBody {
+	key := "source:" + args.Source.Path
+	return breakpointsBody{s.replaceBreakpoints(key, func() []breakpoint {
+		bps := make([]breakpoint, 0, len(args.Breakpoints))
+		file, ok := s.info.SourceFile(args.Source.Path)
+		for _, sbp := range args.Breakpoints {
+			if !ok {
+				bps = append(bps, breakpoint{Message: "no debug information for the source"})
+				continue
+			}
+			lines, line := s.info.Addresses(file, sbp.Line)
+			if len(lines) == 0 {
+				bps = append(bps, breakpoint{Message: "no code on or after the line"})
+				continue
+			}
+			// the line only hits in the banks it was assembled in
+			dbps := make([]debugger.Breakpoint, len(lines))
+			for i, l := range lines {
+				dbps[i] = debugger.Breakpoint{Kind: debugger.Exec, Start: l.Addr, Offset: l.FileOffset}
+			}
+			bp := s.add(key, dbps, sbp.Condition)
+			bp.Source = &source{Name: filepath.Base(file), Path: file}
+			bp.Line = line
+			bps = append(bps, bp)
+		}
+		return bps
+	})}
+}
+
+func (s *session) setFunctionBreakpoints(args setFunctionBreakpointsArguments) breakpointsBody {
+	return breakpointsBody{s.replaceBreakpoints("function", func() []breakpoint {
+		bps := make([]breakpoint, 0, len(args.Breakpoints))
+		for _, fbp := range args.Breakpoints {
+			// the labels only hit in their bank
+			bp, err := s.d.SymbolBreakpoint(fbp.Name)
+			if err != nil {
+				addr, err := parseAddr(fbp.Name)
+				if err != nil {
+					bps = append(bps, breakpoint{Message: fmt.Sprintf("unknown symbol %s", fbp.Name)})
+					continue
+				}
+				bp = debugger.Breakpoint{Kind: debugger.Exec, Start: addr}
+			}
+			bps = append(bps, s.add("function", []debugger.Breakpoint{bp}, fbp.Condition))
+		}
+		return bps
+	})}
+}
+
+func (s *session) setInstructionBreakpoints(args setInstructionBreakpointsArguments) breakpointsBody {
+	return breakpointsBody{s.replaceBreakpoints("instruction", func() []breakpoint {
+		bps := make([]breakpoint, 0, len(args.Breakpoints))
+		for _, ibp := range args.Breakpoints {
+			addr, err := parseAddr(ibp.InstructionReference)
+			if err != nil {
+				bps = append(bps, breakpoint{Message: err.Error()})
+				continue
+			}
+			bps = append(bps, s.addBreakpoints("instruction", []uint16{addr + uint16(ibp.Offset)}, ibp.Condition))
+		}
+		return bps
+	})}
+}
+
+// $hex, 0xhex or decimal
+func parseAddr(ref string) (uint16, error) {
+	if strings.HasPrefix(ref, "$") {
+		ref = "0x" + ref[1:]
+	}
+	addr, err := strconv.ParseUint(ref, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q", ref)
+	}
+	return uint16(addr), nil
+}
+
+func (s *session) frame(id int, pc uint16, entry uint16) stackFrame {
+	name, ok := s.d.Label(entry)
+	if !ok {
+		name, ok = s.info.Nearest(entry)
+	}
+	if !ok {
+		name = fmt.Sprintf("$%04X", entry)
+	}
+	f := stackFrame{ID: id, Name: name, InstructionPointerReference: fmt.Sprintf("0x%04X", pc)}
+	if line, ok := s.lineAt(pc); ok {
+		f.Source = &source{Name: filepath.Base(line.File), Path: line.File}
+		f.Line = line.Line
+		f.Column = 1
+	}
+	return f
+}
+
+// the innermost routine is at the PC and the rest at their callers
+func (s *session) stackTrace() []stackFrame {
+	pc := s.pc()
+	var frames []stackFrame
+	for _, f := range s.d.CallStack() {
+		frames = append(frames, s.frame(len(frames)+1, pc, f.Entry))
+		pc = f.Caller
+	}
+	// the routine which was never called, eg: the reset handler
+	return append(frames, s.frame(len(frames)+1, pc, pc))
+}
+
+func (s *session) variables(ref int) []variable {
+	hex := func(name string, v uint8) variable {
+		return variable{Name: name, Value: fmt.Sprintf("$%02X", v)}
+	}
+	cpu := s.d.Target().CPU()
+	switch ref {
+	case registersRef:
+		rg := &cpu.Rg
+		p := rg.Spc.Ps.Read()
+		flags := []byte("nv-bdizc")
+		for i := range flags {
+			if p&(0x80>>uint(i)) != 0 {
+				flags[i] -= 'a' - 'A'
+			}
+		}
+		pc := rg.Spc.Pc.Read()
+		return []variable{
+			hex("A", rg.Gp.Ac.Read()),
+			hex("X", rg.Gp.Ix.X.Read()),
+			hex("Y", rg.Gp.Ix.Y.Read()),
+			{Name: "P", Value: fmt.Sprintf("$%02X %s", p, flags)},
+			{Name: "SP", Value: fmt.Sprintf("$%02X", rg.Spc.Sp.Read()), MemoryReference: fmt.Sprintf("0x%04X", 0x100+uint16(rg.Spc.Sp.Read()))},
+			{Name: "PC", Value: fmt.Sprintf("$%04X", pc), MemoryReference: fmt.Sprintf("0x%04X", pc)},
+		}
+	case ppuRef:
+		scanline, dot := s.d.Target().PpuPosition()
+		return []variable{
+			{Name: "scanline", Value: strconv.Itoa(scanline)},
+			{Name: "dot", Value: strconv.Itoa(dot)},
+			{Name: "frame", Value: strconv.Itoa(s.d.Target().Frame())},
+		}
+	}
+	return []variable{}
+}
+
+// the references are cpu addresses, or ppu addresses with a ppu: prefix
+func (s *session) readMemory(args readMemoryArguments) (interface{}, error) {
+	space := debugger.CPU
+	ref := args.MemoryReference
+	if strings.HasPrefix(ref, "ppu:") {
+		space, ref = debugger.PPU, ref[len("ppu:"):]
+	}
+	addr, err := parseAddr(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Count < 0 {
+		return nil, fmt.Errorf("invalid count %d", args.Count)
+	}
+	if args.Count > 0x10000 {
+		args.Count = 0x10000
+	}
+	start := int(addr) + args.Offset
+	data := make([]byte, 0, args.Count)
+	for a := start; a < start+args.Count && a >= 0 && a <= 0xFFFF; a++ {
+		data = append(data, s.d.Peek(space, uint16(a)))
+	}
+	body := map[string]interface{}{
+		"address": fmt.Sprintf("0x%04X", start),
+		"data":    base64.StdEncoding.EncodeToString(data),
+	}
+	if len(data) < args.Count {
+		body["unreadableBytes"] = args.Count - len(data)
+	}
+	return body, nil
+}
@@ -0,0 +1,259 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// main.s, assembled at $C000
+var testSource = []struct {
+	line  string
+	bytes []byte
+}{
+	{`.segment "CODE"`, nil},
+	{`reset:`, nil},
+	{`    sei`, []byte{0x78}},
+	{`    ldx #0`, []byte{0xA2, 0x00}},
+	{`loop:`, nil},
+	{`    jsr sub`, []byte{0x20, 0x0A, 0xC0}},
+	{`    inx`, []byte{0xE8}},
+	{`    jmp loop`, []byte{0x4C, 0x03, 0xC0}},
+	{`sub:`, nil},
+	{`    lda #5`, []byte{0xA9, 0x05}},
+	{`    sta $0300`, []byte{0x8D, 0x00, 0x03}},
+	{`    rts`, []byte{0x60}},
+}
+
+// writes an NROM rom, the source and what ld65 would output for them
+func writeTestRom(t *testing.T) (rom string, src string) {
+	dir := t.TempDir()
+	rom, src = filepath.Join(dir, "test.nes"), filepath.Join(dir, "main.s")
+
+	var code []string
+	prg := make([]byte, 0x4000)
+	dbg := []string{
+		"version\tmajor=2,minor=0",
+		"file\tid=0,name=\"main.s\",size=100,mtime=0x00000000,mod=0",
+		"scope\tid=0,name=\"\",mod=0,size=16",
+	}
+	offset, spans := 0, 0
+	for i, l := range testSource {
+		code = append(code, l.line)
+		if l.bytes == nil {
+			continue
+		}
+		copy(prg[offset:], l.bytes)
+		dbg = append(dbg,
+			fmt.Sprintf("span\tid=%d,seg=0,start=%d,size=%d", spans, offset, len(l.bytes)),
+			fmt.Sprintf("line\tid=%d,file=0,line=%d,span=%d", spans, i+1, spans))
+		offset += len(l.bytes)
+		spans++
+	}
+	dbg = append(dbg,
+		fmt.Sprintf("seg\tid=0,name=\"CODE\",start=0x00C000,size=0x%04X,addrsize=absolute,type=ro,oname=\"test.nes\",ooffs=16", offset),
+		"sym\tid=0,name=\"reset\",addrsize=absolute,scope=0,def=2,val=0xC000,seg=0,type=lab",
+		"sym\tid=1,name=\"loop\",addrsize=absolute,scope=0,def=5,val=0xC003,seg=0,type=lab",
+		"sym\tid=2,name=\"sub\",addrsize=absolute,scope=0,def=9,val=0xC00A,seg=0,type=lab")
+
+	// all the vectors point at reset
+	for i := 0x3FFA; i < 0x4000; i += 2 {
+		prg[i], prg[i+1] = 0x00, 0xC0
+	}
+	header := []byte{'N', 'E', 'S', 0x1A, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	files := map[string][]byte{
+		rom:                            append(append(header, prg...), make([]byte, 0x2000)...),
+		src:                            []byte(strings.Join(code, "\n") + "\n"),
+		filepath.Join(dir, "test.dbg"): []byte(strings.Join(dbg, "\n") + "\n"),
+	}
+	for path, data := range files {
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rom, src
+}
+
+// a scripted client, the responses and events are matched by name as their
+// order is not always deterministic
+type testClient struct {
+	t       *testing.T
+	w       io.Writer
+	r       *textproto.Reader
+	seq     int
+	pending []map[string]interface{}
+}
+
+func (c *testClient) send(command string, args interface{}) {
+	c.seq++
+	body, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) read() map[string]interface{} {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	length, _ := strconv.Atoi(header.Get("Content-Length"))
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		c.t.Fatal(err)
+	}
+	msg := map[string]interface{}{}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// waits for the response to a command, or for an event
+func (c *testClient) next(kind string, name string) map[string]interface{} {
+	key := map[string]string{"response": "command", "event": "event"}[kind]
+	for i := 0; ; i++ {
+		if i == len(c.pending) {
+			c.pending = append(c.pending, c.read())
+		}
+		msg := c.pending[i]
+		if msg["type"] != kind || msg[key] != name {
+			continue
+		}
+		c.pending = append(c.pending[:i], c.pending[i+1:]...)
+		return msg
+	}
+}
+
+// the body of a successful response, or of an event
+func (c *testClient) expect(kind string, name string) map[string]interface{} {
+	c.t.Helper()
+	msg := c.next(kind, name)
+	if kind == "response" && msg["success"] != true {
+		c.t.Fatalf("%s failed: %v", name, msg["message"])
+	}
+	body, _ := msg["body"].(map[string]interface{})
+	return body
+}
+
+func (c *testClient) request(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.send(command, args)
+	return c.expect("response", command)
+}
+
+// sends a request which is expected to fail, returns the error message
+func (c *testClient) requestError(command string, args interface{}) string {
+	c.t.Helper()
+	c.send(command, args)
+	msg := c.next("response", command)
+	if msg["success"] == true {
+		c.t.Fatalf("%s succeeded", command)
+	}
+	message, _ := msg["message"].(string)
+	return message
+}
+
+// checks where the cpu stopped, through the top stack frame
+func (c *testClient) stopped(reason string, line int) []interface{} {
+	c.t.Helper()
+	if body := c.expect("event", "stopped"); body["reason"] != reason {
+		c.t.Fatalf("stopped on %v, expected %s", body["reason"], reason)
+	}
+	frames := c.request("stackTrace", map[string]int{"threadId": threadID})["stackFrames"].([]interface{})
+	top := frames[0].(map[string]interface{})
+	if int(top["line"].(float64)) != line {
+		c.t.Fatalf("stopped at line %v of %v, expected %d", top["line"], top["name"], line)
+	}
+	return frames
+}
+
+func TestSession(t *testing.T) {
+	rom, src := writeTestRom(t)
+
+	server, client := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- Serve(server)
+	}()
+	_ = client.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &testClient{t: t, w: client, r: textproto.NewReader(bufio.NewReader(client))}
+
+	c.request("initialize", map[string]string{"adapterID": "gones"})
+	c.request("launch", launchArguments{Program: rom, StopOnEntry: true, Headless: true, Audio: "nil"})
+	c.expect("event", "initialized")
+
+	bps := c.request("setBreakpoints", setBreakpointsArguments{
+		Source: source{Path: src},
+		// the label line moves to the instruction after it
+		Breakpoints: []sourceBreakpoint{{Line: 9}, {Line: 1000}},
+	})["breakpoints"].([]interface{})
+	if bp := bps[0].(map[string]interface{}); bp["verified"] != true || bp["line"] != float64(10) {
+		t.Fatalf("breakpoint on %v", bp)
+	}
+	if bp := bps[1].(map[string]interface{}); bp["verified"] != false {
+		t.Fatalf("breakpoint past the end of the file on %v", bp)
+	}
+	c.request("configurationDone", nil)
+	c.stopped("entry", 3)
+
+	c.request("continue", map[string]int{"threadId": threadID})
+	frames := c.stopped("breakpoint", 10)
+	if len(frames) != 2 {
+		t.Fatalf("%d frames, expected sub and its caller", len(frames))
+	}
+	for i, expected := range []struct {
+		name string
+		line int
+	}{{"sub", 10}, {"loop", 6}} {
+		f := frames[i].(map[string]interface{})
+		if f["name"] != expected.name || f["line"] != float64(expected.line) {
+			t.Errorf("frame %d is %v:%v, expected %s:%d", i, f["name"], f["line"], expected.name, expected.line)
+		}
+	}
+
+	c.request("next", stepArguments{ThreadID: threadID})
+	c.stopped("step", 11)
+	c.request("stepOut", stepArguments{ThreadID: threadID})
+	c.stopped("step", 7)
+
+	vars := c.request("variables", map[string]int{"variablesReference": registersRef})["variables"].([]interface{})
+	if a := vars[0].(map[string]interface{}); a["name"] != "A" || a["value"] != "$05" {
+		t.Errorf("register %v = %v, expected A = $05", a["name"], a["value"])
+	}
+	mem := c.request("readMemory", readMemoryArguments{MemoryReference: "0x0300", Count: 1})
+	if mem["data"] != "BQ==" {
+		t.Errorf("read %v from $0300, expected 5", mem["data"])
+	}
+	if msg := c.requestError("readMemory", readMemoryArguments{MemoryReference: "0x0300", Count: -1}); msg != "invalid count -1" {
+		t.Errorf("read a negative count: %q", msg)
+	}
+	if v := c.request("evaluate", evaluateArguments{Expression: "A == 5 && [$0300] == 5"}); v["result"] != "$1 (1)" {
+		t.Errorf("evaluated to %v", v["result"])
+	}
+
+	c.request("setBreakpoints", setBreakpointsArguments{Source: source{Path: src}})
+	c.request("setFunctionBreakpoints", setFunctionBreakpointsArguments{
+		Breakpoints: []functionBreakpoint{{Name: "loop", Condition: "X == 2"}},
+	})
+	c.request("continue", map[string]int{"threadId": threadID})
+	c.stopped("breakpoint", 6)
+	if x := c.request("evaluate", evaluateArguments{Expression: "X"}); x["result"] != "$2 (2)" {
+		t.Errorf("stopped with X = %v, expected 2", x["result"])
+	}
+
+	c.request("disconnect", nil)
+	c.expect("event", "terminated")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
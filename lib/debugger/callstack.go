@@ -0,0 +1,14 @@
+package debugger
+
+import "github.com/tiagolobocastro/gones/lib/cpu"
+
+// Frame is a subroutine or an interrupt handler being run, as tracked by the
+// cpu
+type Frame = cpu.CallFrame
+
+// CallStack returns the routines being run, the innermost first
+func (d *Debugger) CallStack() []Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.target.CPU().CallStack()
+}
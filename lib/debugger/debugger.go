@@ -0,0 +1,490 @@
+package debugger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+	"github.com/tiagolobocastro/gones/lib/symbols"
+)
+
+// Space is the address space of a breakpoint
+type Space int
+
+const (
+	CPU Space = iota
+	PPU
+)
+
+func (s Space) String() string {
+	if s == PPU {
+		return "ppu"
+	}
+	return "cpu"
+}
+
+// Kind is what triggers a breakpoint, the kinds can be or'ed together
+type Kind int
+
+const (
+	// execution of the instruction at the address
+	Exec Kind = 1 << iota
+	// watchpoints, on the bus accesses
+	Read
+	Write
+)
+
+// Breakpoint stops the emulation on the accesses to an address range, if the
+// condition (see expr.go) is empty or true
+type Breakpoint struct {
+	ID        int
+	Kind      Kind
+	Space     Space
+	Start     uint16
+	End       uint16
+	Condition string
+	Enabled   bool
+	Hits      int
+	// the rom file offset of the code at Start, the execution breakpoints
+	// with one only hit while that PRG bank is mapped. 0 hits in any bank
+	Offset int
+	// the symbol the breakpoint was set on, if any
+	Label string
+
+	cond exprFunc
+}
+
+func (b *Breakpoint) String() string {
+	s := fmt.Sprintf("#%d %s $%04X", b.ID, b.Space, b.Start)
+	if b.End != b.Start {
+		s += fmt.Sprintf("-$%04X", b.End)
+	}
+	if b.Label != "" {
+		s += " (" + b.Label + ")"
+	}
+	for _, k := range []struct {
+		kind Kind
+		name string
+	}{{Exec, "x"}, {Read, "r"}, {Write, "w"}} {
+		if b.Kind&k.kind != 0 {
+			s += " " + k.name
+		}
+	}
+	if b.Condition != "" {
+		s += " if " + b.Condition
+	}
+	if !b.Enabled {
+		s += " (disabled)"
+	}
+	return s
+}
+
+// Reason of a stop
+type Reason int
+
+const (
+	ReasonPause Reason = iota
+	ReasonBreakpoint
+	ReasonStep
+)
+
+// Stop is sent on Stops every time the emulation is paused
+type Stop struct {
+	Reason Reason
+	// the breakpoint hit, nil unless the Reason is ReasonBreakpoint
+	Breakpoint *Breakpoint
+	// the access which hit a watchpoint
+	Space Space
+	Addr  uint16
+	Value uint8
+	Write bool
+	// the registers of the cpu when it stopped
+	PC uint16
+	// the symbol at the PC, if any
+	Label string
+}
+
+// Target is the emulator being debugged
+type Target interface {
+	CPU() *cpu.Cpu
+	// reads the memory without side effects
+	Peek(space Space, addr uint16) uint8
+	PpuPosition() (scanline, dot int)
+	Frame() int
+	// where the PRG-ROM byte mapped at a cpu address is in the rom file, or
+	// -1, eg: to tell the banks apart
+	PrgFileOffset(addr uint16) int
+}
+
+// what the emulation runs until
+type stepMode int
+
+const (
+	running stepMode = iota
+	stepInto
+	stepOver
+	stepOut
+	stepScanline
+	stepFrame
+)
+
+// Debugger pauses the emulation from within the cpu and bus hooks. The hooks
+// run on the emulation goroutine, which blocks while paused, and the
+// frontends call the rest from their own goroutines
+type Debugger struct {
+	target  Target
+	symbols *symbols.DebugInfo
+
+	mu          sync.Mutex
+	breakpoints map[int]*Breakpoint
+	// the breakpoints sorted by id, the first one hit stops the emulation
+	ordered []*Breakpoint
+	nextID  int
+	// skips the accesses hooks when there are no watchpoints, read without
+	// the lock
+	watching int32
+
+	paused   bool
+	pauseReq bool
+	resume   chan struct{}
+	stops    chan Stop
+
+	mode stepMode
+	// where the step started
+	stepPc       uint16
+	stepSp       uint8
+	stepScanline int
+	stepFrame    int
+}
+
+func New(target Target) *Debugger {
+	return &Debugger{
+		target:      target,
+		breakpoints: make(map[int]*Breakpoint),
+		nextID:      1,
+		resume:      make(chan struct{}),
+		stops:       make(chan Stop, 16),
+	}
+}
+
+func (d *Debugger) Target() Target {
+	return d.target
+}
+
+// SetSymbols names the addresses in the stops, and in the conditions
+func (d *Debugger) SetSymbols(info *symbols.DebugInfo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.symbols = info
+}
+
+func (d *Debugger) Symbols() *symbols.DebugInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.symbols
+}
+
+// Label names a cpu address, in the PRG bank mapped there now. The mapper
+// is asked which bank that is, so the emulation should be paused
+func (d *Debugger) Label(addr uint16) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.label(addr)
+}
+
+func (d *Debugger) label(addr uint16) (string, bool) {
+	if d.symbols == nil {
+		return "", false
+	}
+	return d.symbols.Label(addr, d.target.PrgFileOffset(addr))
+}
+
+// SymbolBreakpoint returns an execution breakpoint on a label, which only
+// hits in the label's bank
+func (d *Debugger) SymbolBreakpoint(name string) (Breakpoint, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.symbols == nil {
+		return Breakpoint{}, fmt.Errorf("no symbols loaded")
+	}
+	s, ok := d.symbols.Find(name)
+	if !ok {
+		return Breakpoint{}, fmt.Errorf("unknown symbol %q", name)
+	}
+	bp := Breakpoint{Kind: Exec, Start: s.Addr, End: s.Addr, Label: s.Name}
+	if s.FileOffset > 0 {
+		bp.Offset = s.FileOffset
+	}
+	return bp, nil
+}
+
+// Stops receives an event every time the emulation pauses, the oldest events
+// are dropped if nobody reads them
+func (d *Debugger) Stops() <-chan Stop {
+	return d.stops
+}
+
+// AddBreakpoint returns the new breakpoint id
+func (d *Debugger) AddBreakpoint(bp Breakpoint) (int, error) {
+	if bp.End < bp.Start {
+		bp.End = bp.Start
+	}
+	if bp.Kind == 0 {
+		bp.Kind = Exec
+	}
+	if bp.Kind&Exec != 0 && bp.Space != CPU {
+		return 0, fmt.Errorf("only the cpu executes instructions")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if bp.Condition != "" {
+		cond, err := parseExpr(bp.Condition, d.symbols)
+		if err != nil {
+			return 0, err
+		}
+		bp.cond = cond
+	}
+	bp.ID = d.nextID
+	bp.Enabled = true
+	d.nextID++
+	d.breakpoints[bp.ID] = &bp
+	d.ordered = append(d.ordered, &bp)
+	d.updateWatching()
+	return bp.ID, nil
+}
+
+func (d *Debugger) RemoveBreakpoint(id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.breakpoints[id]; !ok {
+		return fmt.Errorf("no breakpoint #%d", id)
+	}
+	delete(d.breakpoints, id)
+	for i, bp := range d.ordered {
+		if bp.ID == id {
+			d.ordered = append(d.ordered[:i], d.ordered[i+1:]...)
+			break
+		}
+	}
+	d.updateWatching()
+	return nil
+}
+
+func (d *Debugger) EnableBreakpoint(id int, enabled bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bp, ok := d.breakpoints[id]
+	if !ok {
+		return fmt.Errorf("no breakpoint #%d", id)
+	}
+	bp.Enabled = enabled
+	d.updateWatching()
+	return nil
+}
+
+// Breakpoints returns a copy of the breakpoints, sorted by id
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bps := make([]Breakpoint, 0, len(d.ordered))
+	for _, bp := range d.ordered {
+		bps = append(bps, *bp)
+	}
+	return bps
+}
+
+func (d *Debugger) updateWatching() {
+	watching := int32(0)
+	for _, bp := range d.ordered {
+		if bp.Enabled && bp.Kind&(Read|Write) != 0 {
+			watching = 1
+		}
+	}
+	atomic.StoreInt32(&d.watching, watching)
+}
+
+// Paused reports whether the emulation is stopped
+func (d *Debugger) Paused() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paused
+}
+
+// Pause stops the emulation before the next instruction
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pauseReq = true
+}
+
+// Continue runs until the next breakpoint
+func (d *Debugger) Continue() error {
+	return d.run(running)
+}
+
+// StepInto runs a single instruction
+func (d *Debugger) StepInto() error {
+	return d.run(stepInto)
+}
+
+// StepOver runs a single instruction, or a whole subroutine on a JSR
+func (d *Debugger) StepOver() error {
+	return d.run(stepOver)
+}
+
+// StepOut runs until the current subroutine or interrupt handler returns
+func (d *Debugger) StepOut() error {
+	return d.run(stepOut)
+}
+
+// StepScanline runs until the PPU moves to the next scanline
+func (d *Debugger) StepScanline() error {
+	return d.run(stepScanline)
+}
+
+// StepFrame runs until the PPU starts the next frame
+func (d *Debugger) StepFrame() error {
+	return d.run(stepFrame)
+}
+
+func (d *Debugger) run(mode stepMode) error {
+	d.mu.Lock()
+	if !d.paused {
+		d.mu.Unlock()
+		return fmt.Errorf("not paused")
+	}
+
+	rg := &d.target.CPU().Rg
+	d.mode = mode
+	d.stepPc = rg.Spc.Pc.Read()
+	d.stepSp = rg.Spc.Sp.Read()
+	d.stepScanline, _ = d.target.PpuPosition()
+	d.stepFrame = d.target.Frame()
+	if mode == stepOver && d.target.Peek(CPU, d.stepPc) == 0x20 {
+		// JSR, stop on the return
+		d.stepPc += 3
+	} else if mode == stepOver {
+		d.mode = stepInto
+	}
+	d.paused = false
+	d.mu.Unlock()
+
+	d.resume <- struct{}{}
+	return nil
+}
+
+// the stops are sent from the emulation goroutine, then it waits for a run
+func (d *Debugger) stop(s Stop) {
+	s.PC = d.target.CPU().Rg.Spc.Pc.Read()
+	s.Label, _ = d.label(s.PC)
+	d.paused = true
+	d.pauseReq = false
+	d.mode = running
+	select {
+	case d.stops <- s:
+	default:
+		// drop the oldest, unless the frontend just drained them. The stops
+		// are only sent under the lock, so there's room for this one then
+		select {
+		case <-d.stops:
+		default:
+		}
+		d.stops <- s
+	}
+	d.mu.Unlock()
+
+	<-d.resume
+	d.mu.Lock()
+}
+
+func (d *Debugger) hit(bp *Breakpoint, e *env) bool {
+	if !bp.Enabled || (bp.cond != nil && bp.cond(e) == 0) {
+		return false
+	}
+	bp.Hits++
+	return true
+}
+
+// Exec is the cpu hook, called before every instruction
+func (d *Debugger) Exec(pc uint16) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pauseReq {
+		d.stop(Stop{Reason: ReasonPause})
+		return
+	}
+
+	e := &env{d: d, addr: pc}
+	for _, bp := range d.ordered {
+		if bp.Kind&Exec != 0 && pc >= bp.Start && pc <= bp.End && d.inBank(bp, pc) && d.hit(bp, e) {
+			d.stop(Stop{Reason: ReasonBreakpoint, Breakpoint: bp, Addr: pc})
+			return
+		}
+	}
+
+	if d.stepDone(pc) {
+		d.stop(Stop{Reason: ReasonStep})
+	}
+}
+
+func (d *Debugger) inBank(bp *Breakpoint, pc uint16) bool {
+	return bp.Offset <= 0 || d.target.PrgFileOffset(pc) == bp.Offset+int(pc-bp.Start)
+}
+
+func (d *Debugger) stepDone(pc uint16) bool {
+	rg := &d.target.CPU().Rg
+	switch d.mode {
+	case stepInto:
+		return true
+	case stepOver:
+		// the stack pointer check skips the recursive calls
+		return pc == d.stepPc && rg.Spc.Sp.Read() >= d.stepSp
+	case stepOut:
+		// RTS pulls 2 bytes and RTI 3
+		return int(rg.Spc.Sp.Read()) >= int(d.stepSp)+2
+	case stepScanline:
+		scanline, _ := d.target.PpuPosition()
+		return scanline != d.stepScanline
+	case stepFrame:
+		return d.target.Frame() != d.stepFrame
+	}
+	return false
+}
+
+// Access is the bus hook, called after every cpu and ppu bus access
+func (d *Debugger) Access(space Space, addr uint16, value uint8, write bool) {
+	if atomic.LoadInt32(&d.watching) == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	kind := Read
+	if write {
+		kind = Write
+	}
+	e := &env{d: d, addr: addr, value: value}
+	for _, bp := range d.ordered {
+		if bp.Kind&kind != 0 && bp.Space == space && addr >= bp.Start && addr <= bp.End && d.hit(bp, e) {
+			d.stop(Stop{Reason: ReasonBreakpoint, Breakpoint: bp, Space: space, Addr: addr, Value: value, Write: write})
+			return
+		}
+	}
+}
+
+// Peek reads the memory of the target without side effects
+func (d *Debugger) Peek(space Space, addr uint16) uint8 {
+	return d.target.Peek(space, addr)
+}
+
+// Eval evaluates an expression, eg: to watch a value while paused
+func (d *Debugger) Eval(expression string) (int, error) {
+	e, err := parseExpr(expression, d.Symbols())
+	if err != nil {
+		return 0, err
+	}
+	return e(&env{d: d}), nil
+}
@@ -0,0 +1,344 @@
+package debugger
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+	"github.com/tiagolobocastro/gones/lib/symbols"
+)
+
+// a cpu on a flat 64 KB bus, the ppu position is derived from the cycles
+type testTarget struct {
+	cpu    cpu.Cpu
+	mem    [0x10000]uint8
+	d      *Debugger
+	cycles int64
+	quit   int32
+}
+
+func (t *testTarget) Read8(addr uint16) uint8 {
+	t.d.Access(CPU, addr, t.mem[addr], false)
+	return t.mem[addr]
+}
+func (t *testTarget) Write8(addr uint16, val uint8) {
+	t.d.Access(CPU, addr, val, true)
+	t.mem[addr] = val
+}
+func (t *testTarget) CpuCycle(bool) {
+	atomic.AddInt64(&t.cycles, 1)
+}
+
+func (t *testTarget) CPU() *cpu.Cpu {
+	return &t.cpu
+}
+func (t *testTarget) Peek(_ Space, addr uint16) uint8 {
+	return t.mem[addr]
+}
+func (t *testTarget) PpuPosition() (scanline, dot int) {
+	dots := int(atomic.LoadInt64(&t.cycles)) * 3
+	return dots/341%262 - 1, dots % 341
+}
+func (t *testTarget) Frame() int {
+	return int(atomic.LoadInt64(&t.cycles)) * 3 / (341 * 262)
+}
+
+// the code is in the first bank of a rom, after its header
+func (t *testTarget) PrgFileOffset(addr uint16) int {
+	if addr < 0x0200 {
+		return -1
+	}
+	return 16 + int(addr)
+}
+
+// $0200: LDX #0
+// $0202: JSR $0210
+// $0205: INX
+// $0206: STX $10
+// $0208: JMP $0202
+// $0210: LDA #5
+// $0212: STA $0300
+// $0215: RTS
+var testCode = map[uint16][]uint8{
+	0x0200: {0xA2, 0x00, 0x20, 0x10, 0x02, 0xE8, 0x86, 0x10, 0x4C, 0x02, 0x02},
+	0x0210: {0xA9, 0x05, 0x8D, 0x00, 0x03, 0x60},
+}
+
+func newTarget(t *testing.T) (*testTarget, *Debugger) {
+	tt := &testTarget{}
+	for addr, code := range testCode {
+		copy(tt.mem[addr:], code)
+	}
+	tt.mem[0xFFFC] = 0x00
+	tt.mem[0xFFFD] = 0x02
+
+	tt.d = New(tt)
+	tt.cpu.Init(tt, tt, cpu.Variant2A03, false)
+	tt.cpu.Reset()
+	tt.cpu.OnExec(tt.d.Exec)
+	return tt, tt.d
+}
+
+// runs the cpu until the test is done
+func (tt *testTarget) start(t *testing.T) {
+	go func() {
+		for atomic.LoadInt32(&tt.quit) == 0 {
+			tt.cpu.Tick()
+		}
+	}()
+	t.Cleanup(func() {
+		for _, bp := range tt.d.Breakpoints() {
+			_ = tt.d.RemoveBreakpoint(bp.ID)
+		}
+		atomic.StoreInt32(&tt.quit, 1)
+		if tt.d.Paused() {
+			_ = tt.d.Continue()
+		}
+	})
+}
+
+func waitStop(t *testing.T, d *Debugger, reason Reason, pc uint16) Stop {
+	t.Helper()
+	select {
+	case s := <-d.Stops():
+		if s.Reason != reason || s.PC != pc {
+			t.Fatalf("stopped with reason %d at $%04X, expected %d at $%04X", s.Reason, s.PC, reason, pc)
+		}
+		if !d.Paused() {
+			t.Fatal("not paused after a stop")
+		}
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a stop")
+	}
+	return Stop{}
+}
+
+func TestExpr(t *testing.T) {
+	tt, d := newTarget(t)
+	tt.cpu.Rg.Gp.Ac.Write(0x10)
+	tt.cpu.Rg.Gp.Ix.X.Write(3)
+	tt.mem[0x0300] = 0x42
+
+	for src, expected := range map[string]int{
+		"A":                        0x10,
+		"a == $10 && [$0300] != 0": 1,
+		"X >= 8 || [$300] == 0x42": 1,
+		"1 + 2 * 3 == 7":           1,
+		"(1 + 2) * 3":              9,
+		"-1 < 0":                   1,
+		"!X":                       0,
+		"~0 & $FF":                 0xFF,
+		"1 << 4 | 1":               0x11,
+		"[PC] == $A2":              1,
+		"10 / 0":                   0,
+		"X<<1>=6":                  1,
+		"[$2FF + 1] - 2 * (X - 1)": 0x3E,
+	} {
+		v, err := d.Eval(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+		} else if v != expected {
+			t.Errorf("%s: got %d, expected %d", src, v, expected)
+		}
+	}
+
+	for _, src := range []string{"", "A ==", "foo", "(1", "[1", "1 2", "A = 1"} {
+		if _, err := d.Eval(src); err == nil {
+			t.Errorf("%q: expected an error", src)
+		}
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	tt, d := newTarget(t)
+	if _, err := d.AddBreakpoint(Breakpoint{Start: 0x0210, Condition: "A =="}); err == nil {
+		t.Fatal("expected an invalid condition error")
+	}
+	if _, err := d.AddBreakpoint(Breakpoint{Kind: Exec, Space: PPU, Start: 0x2000}); err == nil {
+		t.Fatal("expected an exec breakpoint on the ppu error")
+	}
+	id, err := d.AddBreakpoint(Breakpoint{Start: 0x0210})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt.start(t)
+
+	s := waitStop(t, d, ReasonBreakpoint, 0x0210)
+	if s.Breakpoint == nil || s.Breakpoint.ID != id || s.Breakpoint.Hits != 1 {
+		t.Fatalf("stopped on %v", s.Breakpoint)
+	}
+	if frames := d.CallStack(); len(frames) != 1 || frames[0].Entry != 0x0210 || frames[0].Caller != 0x0202 {
+		t.Fatalf("call stack %+v", frames)
+	}
+	if err := d.StepInto(); err != nil {
+		t.Fatal(err)
+	}
+	waitStop(t, d, ReasonStep, 0x0212)
+	if err := d.StepOut(); err != nil {
+		t.Fatal(err)
+	}
+	waitStop(t, d, ReasonStep, 0x0205)
+	if err := d.StepOver(); err != nil {
+		t.Fatal(err)
+	}
+	waitStop(t, d, ReasonStep, 0x0206)
+
+	_ = d.EnableBreakpoint(id, false)
+	if _, err := d.AddBreakpoint(Breakpoint{Start: 0x0205, Condition: "X == 3"}); err != nil {
+		t.Fatal(err)
+	}
+	_ = d.Continue()
+	waitStop(t, d, ReasonBreakpoint, 0x0205)
+	if x := tt.cpu.Rg.Gp.Ix.X.Read(); x != 3 {
+		t.Fatalf("stopped with X=%d, expected 3", x)
+	}
+	if err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Continue(); err == nil {
+		t.Fatal("expected an error continuing while running")
+	}
+}
+
+func TestStepOverCall(t *testing.T) {
+	tt, d := newTarget(t)
+	_, _ = d.AddBreakpoint(Breakpoint{Start: 0x0202})
+	tt.start(t)
+
+	waitStop(t, d, ReasonBreakpoint, 0x0202)
+	_ = d.StepOver()
+	waitStop(t, d, ReasonStep, 0x0205)
+}
+
+// the overlapping breakpoints are checked by id, the first one hit stops
+func TestOverlappingBreakpoints(t *testing.T) {
+	tt, d := newTarget(t)
+	var ids []int
+	for _, bp := range []Breakpoint{
+		{Start: 0x0210},
+		{Start: 0x0210},
+		{Start: 0x020F, End: 0x0210, Condition: "1"},
+		{Kind: Write, Start: 0x0300},
+		{Kind: Write, Start: 0x02FF, End: 0x0301},
+	} {
+		id, err := d.AddBreakpoint(bp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	_ = d.RemoveBreakpoint(ids[0])
+	tt.start(t)
+
+	for i := 1; i <= 3; i++ {
+		s := waitStop(t, d, ReasonBreakpoint, 0x0210)
+		if s.Breakpoint.ID != ids[1] || s.Breakpoint.Hits != i {
+			t.Fatalf("stopped on %+v", s.Breakpoint)
+		}
+		_ = d.Continue()
+		s = waitStop(t, d, ReasonBreakpoint, 0x0215)
+		if s.Breakpoint.ID != ids[3] || s.Breakpoint.Hits != i {
+			t.Fatalf("stopped on %+v", s.Breakpoint)
+		}
+		_ = d.Continue()
+	}
+	for _, bp := range d.Breakpoints() {
+		if bp.ID == ids[2] || bp.ID == ids[4] {
+			if bp.Hits != 0 {
+				t.Errorf("breakpoint #%d hit %d times", bp.ID, bp.Hits)
+			}
+		}
+	}
+}
+
+func TestWatchpoints(t *testing.T) {
+	tt, d := newTarget(t)
+	_, _ = d.AddBreakpoint(Breakpoint{Kind: Write, Start: 0x0300})
+	_, _ = d.AddBreakpoint(Breakpoint{Kind: Read | Write, Start: 0x0010, End: 0x001F, Condition: "value == 2"})
+	tt.start(t)
+
+	// the access stops the cpu midway through the instruction
+	s := waitStop(t, d, ReasonBreakpoint, 0x0215)
+	if s.Space != CPU || s.Addr != 0x0300 || s.Value != 5 || !s.Write {
+		t.Fatalf("stopped on %+v", s)
+	}
+	if tt.mem[0x0300] != 0 {
+		t.Fatal("the watchpoint should stop before the write")
+	}
+
+	_ = d.RemoveBreakpoint(s.Breakpoint.ID)
+	_ = d.Continue()
+	s = waitStop(t, d, ReasonBreakpoint, 0x0208)
+	if s.Addr != 0x0010 || s.Value != 2 {
+		t.Fatalf("stopped on %+v", s)
+	}
+}
+
+func TestStepPpu(t *testing.T) {
+	tt, d := newTarget(t)
+	tt.start(t)
+
+	d.Pause()
+	select {
+	case s := <-d.Stops():
+		if s.Reason != ReasonPause {
+			t.Fatalf("stopped with reason %d", s.Reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the pause")
+	}
+
+	scanline, _ := tt.PpuPosition()
+	_ = d.StepScanline()
+	s := <-d.Stops()
+	if next, _ := tt.PpuPosition(); s.Reason != ReasonStep || next == scanline {
+		t.Fatalf("still on scanline %d", scanline)
+	}
+
+	frame := tt.Frame()
+	_ = d.StepFrame()
+	s = <-d.Stops()
+	if s.Reason != ReasonStep || tt.Frame() != frame+1 {
+		t.Fatalf("on frame %d, expected %d", tt.Frame(), frame+1)
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	tt, d := newTarget(t)
+	d.SetSymbols(&symbols.DebugInfo{Symbols: []symbols.Symbol{
+		{Name: "counter", Addr: 0x0010, FileOffset: -1},
+		// the same address in another bank
+		{Name: "other", Addr: 0x0205, FileOffset: 16 + 0x4000 + 0x0205},
+		{Name: "loop", Addr: 0x0205, FileOffset: 16 + 0x0205},
+		{Name: "sub", Addr: 0x0210, FileOffset: 16 + 0x0210},
+	}})
+
+	if name, ok := d.Label(0x0205); !ok || name != "loop" {
+		t.Errorf("$0205 is %q", name)
+	}
+	if v, err := d.Eval("counter + 1"); err != nil || v != 0x11 {
+		t.Errorf("counter + 1 = %d, %v", v, err)
+	}
+	if _, err := d.SymbolBreakpoint("missing"); err == nil {
+		t.Error("expected an unknown symbol error")
+	}
+	for _, name := range []string{"other", "sub"} {
+		bp, err := d.SymbolBreakpoint(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bp.Condition = "[counter] == 1"
+		if _, err := d.AddBreakpoint(bp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tt.start(t)
+
+	// other is not mapped
+	s := waitStop(t, d, ReasonBreakpoint, 0x0210)
+	if s.Label != "sub" || s.Breakpoint.String() != "#2 cpu $0210 (sub) x if [counter] == 1" {
+		t.Errorf("stopped at %q on %v", s.Label, s.Breakpoint)
+	}
+}
@@ -0,0 +1,98 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tiagolobocastro/gones/lib/expr"
+	"github.com/tiagolobocastro/gones/lib/symbols"
+)
+
+// Conditions are C like expressions on the registers and memory, eg:
+//
+// A == $10 && [$0300] != 0
+// X >= 8 || (P & $80)
+// value == $FF && addr == $2007
+//
+// The numbers and the operators are the ones of lib/expr, [addr] reads the
+// CPU memory.
+// The names are the registers A, X, Y, P, SP and PC, the PPU SCANLINE, DOT
+// and FRAME, the ADDR and VALUE of the access which hit a watchpoint, and
+// the labels of the symbols, eg: [player::x_pos] > 100.
+// Anything non zero is true
+type exprFunc func(e *env) int
+
+// the state an expression is evaluated on
+type env struct {
+	d     *Debugger
+	addr  uint16
+	value uint8
+}
+
+var names = map[string]bool{
+	"A": true, "X": true, "Y": true, "P": true, "SP": true, "PC": true,
+	"SCANLINE": true, "DOT": true, "FRAME": true, "ADDR": true, "VALUE": true,
+}
+
+func (e *env) lookup(name string) int {
+	rg := &e.d.target.CPU().Rg
+	switch name {
+	case "A":
+		return int(rg.Gp.Ac.Read())
+	case "X":
+		return int(rg.Gp.Ix.X.Read())
+	case "Y":
+		return int(rg.Gp.Ix.Y.Read())
+	case "P":
+		return int(rg.Spc.Ps.Read())
+	case "SP":
+		return int(rg.Spc.Sp.Read())
+	case "PC":
+		return int(rg.Spc.Pc.Read())
+	case "SCANLINE":
+		scanline, _ := e.d.target.PpuPosition()
+		return scanline
+	case "DOT":
+		_, dot := e.d.target.PpuPosition()
+		return dot
+	case "FRAME":
+		return e.d.target.Frame()
+	case "ADDR":
+		return int(e.addr)
+	case "VALUE":
+		return int(e.value)
+	}
+	return 0
+}
+
+// parses a condition, the names read the env it's evaluated on, which is
+// only ever one at a time as the conditions are evaluated under the lock
+func parseExpr(src string, info *symbols.DebugInfo) (exprFunc, error) {
+	var cur *env
+	v, err := expr.Parse(src, expr.Env{
+		Name: func(tok string) (expr.Value, error) {
+			name := strings.ToUpper(tok)
+			if names[name] {
+				return func() (int, error) { return cur.lookup(name), nil }, nil
+			}
+			if info != nil {
+				if addr, ok := info.Lookup(tok); ok {
+					return expr.Const(int(addr)), nil
+				}
+			}
+			return nil, fmt.Errorf("%q: unknown name %q", src, tok)
+		},
+		Peek: func(addr int) int {
+			return int(cur.d.target.Peek(CPU, uint16(addr)))
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("condition %v", err)
+	}
+	// a division by zero is 0
+	return func(e *env) int {
+		cur = e
+		n, _ := v()
+		return n
+	}, nil
+}
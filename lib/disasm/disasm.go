@@ -0,0 +1,189 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// The output is in the ca65 syntax, eg:
+//
+// 02:C00C  8D 00 03  STA $0300
+// 02:C00F  B9 10 00  LDA a:$0010,Y
+// 02:C012  D0 F8     BNE loop
+
+// Labels names the addresses, offset is where the address is in the rom file
+// or -1 when not known, eg: an operand pointing outside of the code
+type Labels interface {
+	Label(addr uint16, offset int) (string, bool)
+}
+
+// Block is code mapped at Org, Bank and Offset locate it in the rom file and
+// are -1 when it's not from there, eg: code copied to RAM
+type Block struct {
+	Code   []uint8
+	Org    uint16
+	Bank   int
+	Offset int
+}
+
+// offset of addr in the rom file, if it's in the block
+func (b *Block) offset(addr uint16) int {
+	if b.Offset < 0 || addr < b.Org || int(addr-b.Org) >= len(b.Code) {
+		return -1
+	}
+	return b.Offset + int(addr-b.Org)
+}
+
+// Line is a disassembled instruction, or a byte which isn't one
+type Line struct {
+	Addr   uint16
+	Bank   int
+	Offset int
+	Bytes  []uint8
+	// the name of Addr, if any
+	Label string
+	// the mnemonic and the operand, eg: LDA ($10),Y
+	Text       string
+	Unofficial bool
+}
+
+func (l Line) String() string {
+	bytes := make([]string, len(l.Bytes))
+	for i, b := range l.Bytes {
+		bytes[i] = fmt.Sprintf("%02X", b)
+	}
+	addr := fmt.Sprintf("%04X", l.Addr)
+	if l.Bank >= 0 {
+		addr = fmt.Sprintf("%02X:%04X", l.Bank, l.Addr)
+	}
+	return fmt.Sprintf("%s  %-8s  %s", addr, strings.Join(bytes, " "), l.Text)
+}
+
+type Disassembler struct {
+	ins    [256]cpu.Instruction
+	labels Labels
+}
+
+// New uses the instruction table of the cpu variant, labels is optional
+func New(variant cpu.Variant, labels Labels) *Disassembler {
+	return &Disassembler{ins: cpu.Instructions(variant), labels: labels}
+}
+
+func (d *Disassembler) label(addr uint16, offset int) (string, bool) {
+	if d.labels == nil {
+		return "", false
+	}
+	return d.labels.Label(addr, offset)
+}
+
+// Disassemble decodes the whole block, the instructions cut short by its end
+// are left as bytes
+func (d *Disassembler) Disassemble(b Block) []Line {
+	var lines []Line
+	for pos := 0; pos < len(b.Code); {
+		addr := b.Org + uint16(pos)
+		ins := &d.ins[b.Code[pos]]
+
+		line := Line{Addr: addr, Bank: b.Bank, Offset: b.offset(addr)}
+		line.Label, _ = d.label(addr, line.Offset)
+		if pos+ins.Length() > len(b.Code) {
+			line.Bytes = b.Code[pos : pos+1]
+			line.Text = fmt.Sprintf(".byte $%02X", b.Code[pos])
+			pos++
+		} else {
+			line.Bytes = b.Code[pos : pos+ins.Length()]
+			line.Text = d.text(&b, ins, addr, line.Bytes)
+			line.Unofficial = ins.Unofficial()
+			pos += ins.Length()
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (d *Disassembler) text(b *Block, ins *cpu.Instruction, addr uint16, bytes []uint8) string {
+	// the names, or the addresses with as many digits as the operand
+	zp := func(a uint8) string {
+		if name, ok := d.label(uint16(a), -1); ok {
+			return name
+		}
+		return fmt.Sprintf("$%02X", a)
+	}
+	abs := func(a uint16) string {
+		name, ok := d.label(a, b.offset(a))
+		if !ok {
+			name = fmt.Sprintf("$%04X", a)
+		}
+		if a < 0x100 {
+			// or ca65 picks the zero page addressing
+			name = "a:" + name
+		}
+		return name
+	}
+	branch := func(a uint16) string {
+		if name, ok := d.label(a, b.offset(a)); ok {
+			return name
+		}
+		return fmt.Sprintf("$%04X", a)
+	}
+
+	var op1 uint8
+	var op12 uint16
+	if len(bytes) > 1 {
+		op1 = bytes[1]
+		op12 = uint16(op1)
+	}
+	if len(bytes) > 2 {
+		op12 |= uint16(bytes[2]) << 8
+	}
+
+	operand := ""
+	switch ins.AddrMode() {
+	case cpu.ModeAccumulator:
+		operand = "A"
+	case cpu.ModeImmediate:
+		operand = fmt.Sprintf("#$%02X", op1)
+	case cpu.ModeZeroPage:
+		operand = zp(op1)
+	case cpu.ModeIndexedZeroPageX:
+		operand = zp(op1) + ",X"
+	case cpu.ModeIndexedZeroPageY:
+		operand = zp(op1) + ",Y"
+	case cpu.ModeAbsolute:
+		operand = abs(op12)
+		if ins.Name() == "JMP" || ins.Name() == "JSR" {
+			operand = branch(op12)
+		}
+	case cpu.ModeIndexedAbsoluteX:
+		operand = abs(op12) + ",X"
+	case cpu.ModeIndexedAbsoluteY:
+		operand = abs(op12) + ",Y"
+	case cpu.ModeIndirect:
+		operand = "(" + branch(op12) + ")"
+	case cpu.ModeIndexedIndirectX:
+		operand = "(" + zp(op1) + ",X)"
+	case cpu.ModeIndirectIndexedY:
+		operand = "(" + zp(op1) + "),Y"
+	case cpu.ModeRelative:
+		operand = branch(addr + 2 + uint16(int8(op1)))
+	case cpu.ModeZeroPageIndirect:
+		operand = "(" + zp(op1) + ")"
+	case cpu.ModeIndexedAbsoluteIndirectX:
+		operand = "(" + branch(op12) + ",X)"
+	case cpu.ModeZeroPageRelative:
+		operand = zp(op1) + ", " + branch(addr+3+uint16(int8(bytes[2])))
+	}
+
+	name := ins.Name()
+	switch name {
+	case "RMB", "SMB", "BBR", "BBS":
+		// the bit is in the opcode
+		name += fmt.Sprint(ins.OpCode() >> 4 & 7)
+	}
+	if operand == "" {
+		return name
+	}
+	return name + " " + operand
+}
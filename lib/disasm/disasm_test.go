@@ -0,0 +1,92 @@
+package disasm
+
+import (
+	"testing"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+type testLabels map[uint16]string
+
+func (l testLabels) Label(addr uint16, _ int) (string, bool) {
+	name, ok := l[addr]
+	return name, ok
+}
+
+func TestDisassemble(t *testing.T) {
+	d := New(cpu.Variant2A03, testLabels{0xC000: "reset", 0x0010: "ptr", 0x2002: "PPUSTATUS"})
+	lines := d.Disassemble(Block{
+		Code: []uint8{
+			0xA9, 0x05, // LDA #$05
+			0x2C, 0x02, 0x20, // BIT PPUSTATUS
+			0xB1, 0x10, // LDA (ptr),Y
+			0xB9, 0x10, 0x00, // LDA a:ptr,Y
+			0x9D, 0x00, 0x03, // STA $0300,X
+			0x0A,       // ASL A
+			0xA7, 0x20, // LAX $20
+			0x6C, 0xFC, 0xFF, // JMP ($FFFC)
+			0xD0, 0xEB, // BNE reset
+			0x20, 0x34, 0x12, // JSR $1234
+			0xAD, 0x00, // cut short
+		},
+		Org:    0xC000,
+		Bank:   3,
+		Offset: 16 + 3*0x4000,
+	})
+
+	expected := []string{
+		"03:C000  A9 05     LDA #$05",
+		"03:C002  2C 02 20  BIT PPUSTATUS",
+		"03:C005  B1 10     LDA (ptr),Y",
+		"03:C007  B9 10 00  LDA a:ptr,Y",
+		"03:C00A  9D 00 03  STA $0300,X",
+		"03:C00D  0A        ASL A",
+		"03:C00E  A7 20     LAX $20",
+		"03:C010  6C FC FF  JMP ($FFFC)",
+		"03:C013  D0 EB     BNE reset",
+		"03:C015  20 34 12  JSR $1234",
+		"03:C018  AD        .byte $AD",
+		"03:C019  00        BRK",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("%d lines, expected %d", len(lines), len(expected))
+	}
+	for i, line := range lines {
+		if line.String() != expected[i] {
+			t.Errorf("got %q, expected %q", line.String(), expected[i])
+		}
+	}
+	if lines[0].Label != "reset" || lines[0].Offset != 16+3*0x4000 {
+		t.Errorf("first line is %q at %d", lines[0].Label, lines[0].Offset)
+	}
+	if !lines[6].Unofficial || lines[7].Unofficial {
+		t.Error("LAX is the only unofficial opcode")
+	}
+}
+
+func TestDisassemble65C02(t *testing.T) {
+	d := New(cpu.Variant65C02, nil)
+	lines := d.Disassemble(Block{
+		Code: []uint8{
+			0x80, 0xFE, // BRA $0200
+			0xB2, 0x10, // LDA ($10)
+			0x7C, 0x00, 0x03, // JMP ($0300,X)
+			0x8F, 0x12, 0xF7, // BBS0 $12, $0201
+		},
+		Org:    0x0200,
+		Bank:   -1,
+		Offset: -1,
+	})
+
+	expected := []string{
+		"0200  80 FE     BRA $0200",
+		"0202  B2 10     LDA ($10)",
+		"0204  7C 00 03  JMP ($0300,X)",
+		"0207  8F 12 F7  BBS0 $12, $0201",
+	}
+	for i, line := range lines {
+		if line.String() != expected[i] {
+			t.Errorf("got %q, expected %q", line.String(), expected[i])
+		}
+	}
+}
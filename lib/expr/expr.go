@@ -0,0 +1,294 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The C like expressions of the assembler operands and of the debugger
+// conditions, eg:
+//
+// <(table + 2 * 3)
+// A == $10 && [$0300] != 0
+//
+// Numbers are decimal, $hex, 0xhex, %binary or 'c', <expr and >expr are the
+// low and high bytes and [addr] reads the memory. The names, the labels
+// included, and * are resolved by the caller. Anything non zero is true
+
+// Value is a parsed expression, worked out again on every call as the names
+// it reads may have changed, eg: the registers
+type Value func() (int, error)
+
+// Const is a Value which never changes
+func Const(n int) Value {
+	return func() (int, error) { return n, nil }
+}
+
+// Env resolves what an expression refers to
+type Env struct {
+	// Name returns the value of a name, or of *, its errors are returned as
+	// they are by Parse
+	Name func(name string) (Value, error)
+	// Peek reads [addr], nil if there's no memory to read
+	Peek func(addr int) int
+}
+
+// binary operators, from the lowest precedence
+var binaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func applyBinary(op string, l, r int) int {
+	switch op {
+	case "||":
+		return boolInt(l != 0 || r != 0)
+	case "&&":
+		return boolInt(l != 0 && r != 0)
+	case "|":
+		return l | r
+	case "^":
+		return l ^ r
+	case "&":
+		return l & r
+	case "==":
+		return boolInt(l == r)
+	case "!=":
+		return boolInt(l != r)
+	case "<=":
+		return boolInt(l <= r)
+	case ">=":
+		return boolInt(l >= r)
+	case "<":
+		return boolInt(l < r)
+	case ">":
+		return boolInt(l > r)
+	case "<<":
+		return l << uint(r)
+	case ">>":
+		return l >> uint(r)
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		return l / r
+	case "%":
+		return l % r
+	}
+	panic("unknown operator " + op)
+}
+
+type parser struct {
+	src string
+	pos int
+	env Env
+}
+
+// Parse parses src, the names are resolved right away
+func Parse(src string, env Env) (Value, error) {
+	p := &parser{src: src, env: env}
+	v, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpaces(); p.pos != len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return v, nil
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%q, column %d: %s", p.src, p.pos+1, fmt.Sprintf(format, a...))
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// consumes tok if it's next, "<" doesn't match the start of "<=" or "<<"
+func (p *parser) accept(tok string) bool {
+	p.skipSpaces()
+	if !strings.HasPrefix(p.src[p.pos:], tok) {
+		return false
+	}
+	if next := p.pos + len(tok); len(tok) == 1 && next < len(p.src) {
+		switch tok + p.src[next:next+1] {
+		case "<=", ">=", "<<", ">>", "&&", "||", "==", "!=":
+			return false
+		}
+	}
+	p.pos += len(tok)
+	return true
+}
+
+func (p *parser) binary(level int) (Value, error) {
+	if level == len(binaryOps) {
+		return p.unary()
+	}
+	l, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range binaryOps[level] {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		r, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left, zero := l, p.errorf("division by zero")
+		l = func() (int, error) {
+			lv, err := left()
+			if err != nil {
+				return 0, err
+			}
+			rv, err := r()
+			if err != nil {
+				return 0, err
+			}
+			if rv == 0 && (op == "/" || op == "%") {
+				return 0, zero
+			}
+			return applyBinary(op, lv, rv), nil
+		}
+	}
+}
+
+func (p *parser) unary() (Value, error) {
+	for _, op := range []string{"!", "-", "~", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func() (int, error) {
+			n, err := v()
+			switch op {
+			case "!":
+				n = boolInt(n == 0)
+			case "-":
+				n = -n
+			case "~":
+				n = ^n
+			case "<":
+				n &= 0xFF
+			case ">":
+				n = n >> 8 & 0xFF
+			}
+			return n, err
+		}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (Value, error) {
+	switch {
+	case p.accept("("):
+		v, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return v, nil
+	case p.env.Peek != nil && p.accept("["):
+		addr, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept("]") {
+			return nil, p.errorf("missing ]")
+		}
+		return func() (int, error) {
+			a, err := addr()
+			if err != nil {
+				return 0, err
+			}
+			return p.env.Peek(a), nil
+		}, nil
+	case p.accept("*"):
+		return p.env.Name("*")
+	}
+
+	start := p.pos
+	if p.pos+2 < len(p.src) && p.src[p.pos] == '\'' && p.src[p.pos+2] == '\'' {
+		p.pos += 3
+		return Const(int(p.src[start+1])), nil
+	}
+	base := 10
+	if p.pos < len(p.src) && p.src[p.pos] == '%' {
+		base, p.pos = 2, p.pos+1
+	}
+	for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+		p.pos++
+	}
+	tok := p.src[start:p.pos]
+	switch {
+	case tok == "":
+		return nil, p.errorf("missing operand")
+	case base == 2:
+		n, err := strconv.ParseInt(tok[1:], 2, 32)
+		if err != nil {
+			return nil, p.errorf("bad number %q", tok)
+		}
+		return Const(int(n)), nil
+	case tok[0] == '$' || unicode.IsDigit(rune(tok[0])):
+		n, ok := parseNumber(tok)
+		if !ok {
+			return nil, p.errorf("bad number %q", tok)
+		}
+		return Const(n), nil
+	}
+	return p.env.Name(tok)
+}
+
+// the labels may have scopes, eg: player::update
+func isNameChar(c byte) bool {
+	return c == '$' || c == '_' || c == ':' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// $hex, 0xhex or decimal
+func parseNumber(tok string) (int, bool) {
+	base := 10
+	switch {
+	case strings.HasPrefix(tok, "$"):
+		tok, base = tok[1:], 16
+	case strings.HasPrefix(tok, "0x"), strings.HasPrefix(tok, "0X"):
+		tok, base = tok[2:], 16
+	}
+	n, err := strconv.ParseInt(tok, base, 32)
+	return int(n), err == nil
+}
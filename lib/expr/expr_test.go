@@ -0,0 +1,82 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	env := Env{
+		Name: func(name string) (Value, error) {
+			switch name {
+			case "*":
+				return Const(0xC000), nil
+			case "label":
+				return Const(0x1234), nil
+			}
+			return nil, fmt.Errorf("unknown name %q", name)
+		},
+		Peek: func(addr int) int { return addr & 0xFF },
+	}
+
+	for _, test := range []struct {
+		src      string
+		expected int
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"$10 | %101 ^ 0x3", 0x16},
+		{"1 << 4 >> 2", 4},
+		{"7 % 4 - 10 / 3", 0},
+		{"<label + >label", 0x46},
+		{"-1 & ~$0F", -16},
+		{"'c'", 'c'},
+		{"* + 2", 0xC002},
+		{"[label] == $34 && !(2 < 1)", 1},
+		{"1 <= 1 || 0", 1},
+		{"label != label", 0},
+		{"\t3 >= 4 ", 0},
+	} {
+		v, err := Parse(test.src, env)
+		if err != nil {
+			t.Errorf("%q failed with %v", test.src, err)
+			continue
+		}
+		if n, err := v(); err != nil || n != test.expected {
+			t.Errorf("%q is %d (%v), expected %d", test.src, n, err, test.expected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	env := Env{Name: func(name string) (Value, error) {
+		return nil, fmt.Errorf("unknown name %q", name)
+	}}
+
+	for _, test := range []struct {
+		src, err string
+	}{
+		{"", `"", column 1: missing operand`},
+		{"(1", `"(1", column 3: missing )`},
+		{"1 2", `"1 2", column 3: unexpected "2"`},
+		{"$1G", `"$1G", column 4: bad number "$1G"`},
+		{"%12", `"%12", column 4: bad number "%12"`},
+		{"1 + foo", `unknown name "foo"`},
+		// there's no memory to read
+		{"[1]", `"[1]", column 1: missing operand`},
+	} {
+		_, err := Parse(test.src, env)
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%q failed with %v, expected %s", test.src, err, test.err)
+		}
+	}
+
+	v, err := Parse("1 / (2 - 2)", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v(); err == nil || err.Error() != `"1 / (2 - 2)", column 12: division by zero` {
+		t.Errorf("division by zero failed with %v", err)
+	}
+}
@@ -1,6 +1,14 @@
 package lib
 
-import "github.com/tiagolobocastro/gones/lib/nesInternal"
+import (
+	"time"
+
+	"github.com/tiagolobocastro/gones/lib/cdl"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+	"github.com/tiagolobocastro/gones/lib/debugger"
+	"github.com/tiagolobocastro/gones/lib/nesInternal"
+	"github.com/tiagolobocastro/gones/lib/symbols"
+)
 
 type GoNes interface {
 	// Runs the emulator (blocking)
@@ -12,6 +20,20 @@ type GoNes interface {
 	// (excluding some settings like audio library and logging verbosity)
 	Save()
 	Load()
+	// The debugger, nil unless created with the Debug option
+	Debugger() *debugger.Debugger
+	// The labels of the symbol files next to the rom, nil if there are none
+	Symbols() *symbols.DebugInfo
+	// The code/data logger, nil unless created with the CodeDataLog option
+	CodeDataLogger() *cdl.Logger
+	// The cpu profiler, nil unless created with the Profile option
+	Profiler() *cpu.Profiler
+	// The frames run since the profiler started
+	ProfiledFrames() int
+	// The instruction tracer, nil unless created with the Trace option
+	Tracer() *cpu.Tracer
+	// Peeks and pokes every address space without side effects
+	Memory() *Memory
 }
 
 func CartPath(path string) func(n *nesInternal.GoNes) error {
@@ -38,6 +60,56 @@ func SpriteLimit(limit bool) func(n *nesInternal.GoNes) error {
 	return nesInternal.SpriteLimit(limit)
 }
 
+func Headless(headless bool) func(n *nesInternal.GoNes) error {
+	return nesInternal.Headless(headless)
+}
+
+// Debug hooks up a debugger, see GoNes.Debugger
+func Debug(debug bool) func(n *nesInternal.GoNes) error {
+	return nesInternal.Debug(debug)
+}
+
+// CodeDataLog logs the PRG-ROM and CHR-ROM usage to an FCEUX .cdl file, which
+// is saved on Stop, merged with the file if it exists
+func CodeDataLog(path string) func(n *nesInternal.GoNes) error {
+	return nesInternal.CodeDataLog(path)
+}
+
+// Profile counts the cycles of every cpu routine, the report of the top ones
+// is printed on Stop and a pprof profile is written to path
+func Profile(path string) func(n *nesInternal.GoNes) error {
+	return nesInternal.Profile(path)
+}
+
+type TraceOptions = nesInternal.TraceOptions
+
+// Trace writes the instructions to a file, with the fields and the filters of
+// the options, or keeps the last ones to write when the cpu jams or panics
+func Trace(options TraceOptions) func(n *nesInternal.GoNes) error {
+	return nesInternal.Trace(options)
+}
+
+type Memory = nesInternal.Memory
+type MemorySpace = nesInternal.MemorySpace
+
+const (
+	MemoryCPU     = nesInternal.MemoryCPU
+	MemoryPPU     = nesInternal.MemoryPPU
+	MemoryOAM     = nesInternal.MemoryOAM
+	MemoryPalette = nesInternal.MemoryPalette
+	MemoryPrgRom  = nesInternal.MemoryPrgRom
+	MemoryPrgRam  = nesInternal.MemoryPrgRam
+	MemoryChr     = nesInternal.MemoryChr
+)
+
+type TestRomResult = nesInternal.TestRomResult
+
+// RunTestRom runs one of blargg's test roms without a screen, until it reports
+// its result through $6000 or the timeout (in emulated time) expires
+func RunTestRom(path string, timeout time.Duration) (TestRomResult, error) {
+	return nesInternal.RunTestRom(path, timeout)
+}
+
 // Example usage:
 // 	nes := gones.NewNES(
 //		gones.CartPath("rom.nes"),
@@ -23,6 +23,9 @@ const (
 type NesView interface {
 	PPU() *ppu.Ppu
 	CPU() *cpu.Cpu
+	// the last value driven on the cpu data bus, which is what the cpu
+	// reads back from addresses nothing answers to
+	OpenBus() uint8
 }
 
 type Mapper interface {
@@ -31,6 +34,55 @@ type Mapper interface {
 	Tick()
 }
 
+// Optional mapper features
+
+// mappers with logic clocked by the CPU M2 line rather than the PPU,
+// eg: cpu cycle IRQ counters and expansion audio
+type cpuTicker interface {
+	CpuTick()
+}
+
+// mappers which take over the PPU nametable space, eg: to use CHR-ROM as
+// nametables or to select the CIRAM page per nametable
+type tableMapper interface {
+	ReadTable(addr uint16) uint8
+	WriteTable(addr uint16, val uint8)
+}
+
+// mappers with extra battery backed memory which is saved after the prgRam
+type batteryMapper interface {
+	BatteryRams() []*common.Ram
+}
+
+// mappers with a PRG-ROM flashed by the game, which is saved after the
+// battery backed RAMs
+type flashMapper interface {
+	flashesPrg() bool
+}
+
+// mappers which also decode the expansion area $4020-$5FFF
+type expansionMapper interface {
+	decodesExpansion() bool
+}
+
+// mappers with the console reset line wired, eg: multicarts which change the
+// outer bank on every reset
+type resetMapper interface {
+	Reset()
+}
+
+// mappers with side effects on the reads, eg: latches, which can also be read
+// without them, eg: by the debuggers
+type peekMapper interface {
+	Peek8(addr uint16) uint8
+}
+
+// mappers with registers, as listed by MapperRegisters, which leave out the
+// banks worked out from them and the emulator bookkeeping
+type registerMapper interface {
+	mapperRegisters() []MapperRegister
+}
+
 var CartEndianness = binary.LittleEndian
 
 func (c *Cartridge) defaultInit() error {
@@ -83,7 +135,9 @@ func (c *Cartridge) Init(cartPath string, nes NesView) error {
 		log.Panicf("Unsupported console type %v", c.config.console)
 	}
 
+	c.prgStart = len(header.Flags)
 	if c.config.trainer {
+		c.prgStart += 512
 		trainer := make([]byte, 512)
 		if _, err = io.ReadFull(file, trainer); err != nil {
 			return err
@@ -95,11 +149,11 @@ func (c *Cartridge) Init(cartPath string, nes NesView) error {
 	if _, err = c.prgRom.LoadFromFile(file); err != nil {
 		return err
 	}
+	// named after the PRG as dumped, as the flashable boards rewrite it
+	c.ramSave = c.ramSavePath()
 
-	c.prgRam.Init(c.config.prgRamSize)
-	if c.config.battery {
-		c.prgRam.LoadFromFile(c.getRamSaveFile())
-	}
+	// a single PRG RAM holds both the volatile and the battery backed RAM
+	c.prgRam.Init(c.config.prgRamSize + c.config.prgNVRamSize)
 
 	// todo: when is this "rom" writable??
 	c.chr.Init(c.config.chrRomSize, true)
@@ -110,9 +164,14 @@ func (c *Cartridge) Init(cartPath string, nes NesView) error {
 		c.chr.Init(0x4000, true)
 	}
 
+	// the mappers may override the header mirroring on Init
+	c.Tables.Init(common.NameTableMirroring(c.config.mirror))
 	c.Mapper = c.newCartMapper(c.config.mapper)
 	c.Mapper.Init()
-	c.Tables.Init(common.NameTableMirroring(c.config.mirror))
+
+	if c.hasBattery() {
+		c.loadBattery()
+	}
 	return nil
 }
 
@@ -122,18 +181,112 @@ func (c *Cartridge) Ticks(nTicks int) {
 	}
 }
 
+func (c *Cartridge) CpuTicks(nTicks int) {
+	if ticker, ok := c.Mapper.(cpuTicker); ok {
+		for i := 0; i < nTicks; i++ {
+			ticker.CpuTick()
+		}
+	}
+}
+
 func (c *Cartridge) Stop() {
-	if c.config.battery {
-		if err := c.prgRam.SaveToFile(c.getRamSaveFile()); err != nil {
+	if c.hasBattery() {
+		if err := c.saveBattery(); err != nil {
 			log.Panicf("Failed to save game: %v", err)
 		}
 	}
 }
 
+// the prgRam followed by any other battery backed memory of the mapper
+func (c *Cartridge) batteryRams() []*common.Ram {
+	rams := []*common.Ram{c.prgRam}
+	if battery, ok := c.Mapper.(batteryMapper); ok {
+		rams = append(rams, battery.BatteryRams()...)
+	}
+	return rams
+}
+
+func (c *Cartridge) flashable() bool {
+	flash, ok := c.Mapper.(flashMapper)
+	return ok && flash.flashesPrg()
+}
+
+// boards such as the Bandai EEPROM ones save data without declaring a battery
+func (c *Cartridge) hasBattery() bool {
+	return c.config.battery || len(c.batteryRams()) > 1 || c.flashable()
+}
+
+func (c *Cartridge) loadBattery() {
+	file := c.getRamSaveFile()
+	defer file.Close()
+	for _, ram := range c.batteryRams() {
+		// a new save file is empty, so just keep the power on contents
+		if _, err := ram.LoadFromFile(file); err != nil {
+			return
+		}
+	}
+	if c.flashable() {
+		_, _ = c.prgRom.LoadFromFile(file)
+	}
+}
+
+func (c *Cartridge) saveBattery() error {
+	save := c.getRamSaveFile()
+	_ = save.Close()
+
+	file, err := os.Create(save.Name())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for _, ram := range c.batteryRams() {
+		if _, err := ram.WriteTo(file); err != nil {
+			return err
+		}
+	}
+	if c.flashable() {
+		if _, err := c.prgRom.WriteTo(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Nametables as seen by the PPU, unless the mapper takes them over
+func (c *Cartridge) ReadTable(addr uint16) uint8 {
+	if tables, ok := c.Mapper.(tableMapper); ok {
+		return tables.ReadTable(addr)
+	}
+	return c.Tables.Read8(addr)
+}
+func (c *Cartridge) WriteTable(addr uint16, val uint8) {
+	if tables, ok := c.Mapper.(tableMapper); ok {
+		tables.WriteTable(addr, val)
+		return
+	}
+	c.Tables.Write8(addr, val)
+}
+
+func (c *Cartridge) DecodesExpansion() bool {
+	if expansion, ok := c.Mapper.(expansionMapper); ok {
+		return expansion.decodesExpansion()
+	}
+	return false
+}
+
+// Reset reloads the cartridge from the file, as when powering it on
 func (c *Cartridge) Reset() {
 	c.Init(c.cart, c.nes)
 }
 
+// SoftReset is the console reset button, only seen by the mappers which
+// have the reset line wired
+func (c *Cartridge) SoftReset() {
+	if reset, ok := c.Mapper.(resetMapper); ok {
+		reset.Reset()
+	}
+}
+
 func (c *Cartridge) Serialise(s common.Serialiser) error {
 	return s.Serialise(c.prgRom, c.prgRam, c.chr, c.ram, &c.Tables, c.Mapper)
 }
@@ -141,31 +294,175 @@ func (c *Cartridge) DeSerialise(s common.Serialiser) error {
 	return s.DeSerialise(c.prgRom, c.prgRam, c.chr, c.ram, &c.Tables, c.Mapper)
 }
 
-func (c *Cartridge) newCartMapper(mapper byte) Mapper {
+func (c *Cartridge) newCartMapper(mapper uint16) Mapper {
 	switch mapper {
 	case 0:
 		return &MapperNROM{cart: c}
 	case 1:
 		return &MapperMMC1{cart: c}
-	case 2, 9:
+	case 2:
+		return &MapperUxROM{cart: c}
+	case 9:
 		return &MapperMMC2{cart: c}
-	case 4:
-		return &MapperMMC3{cart: c}
+	case 4, 118, 119:
+		return &MapperMMC3{cart: c, mapper: mapper}
+	case 10:
+		return &MapperMMC2{cart: c, mmc4: true}
+	case 15, 225, 226, 227, 228, 233:
+		return &MapperMulticart{cart: c, mapper: mapper}
+	case 16, 153, 157, 159:
+		return &MapperBandaiFCG{cart: c, mapper: mapper}
+	case 18:
+		return &MapperJaleco{cart: c}
+	case 19:
+		return &MapperN163{cart: c}
+	case 28:
+		return &MapperAction53{cart: c}
+	case 30:
+		return &MapperUNROM512{cart: c}
+	case 32, 65:
+		return &MapperIrem{cart: c, mapper: mapper}
+	case 33, 48:
+		return &MapperTaito{cart: c, mapper: mapper}
+	case 64:
+		return &MapperRAMBO1{MapperMMC3: MapperMMC3{cart: c, mapper: mapper}}
+	case 67, 68:
+		return &MapperSunsoft{cart: c, mapper: mapper}
+	case 71:
+		return &MapperCodemasters{cart: c}
+	case 88, 95, 154, 206:
+		return &MapperNamco108{MapperMMC3: MapperMMC3{cart: c}, mapper: mapper}
+	case 111:
+		return &MapperGTROM{cart: c}
 	default:
 		panic(fmt.Sprintf("mapper %v not supported!", mapper))
 	}
 }
 
+// reads of cpu addresses which the board doesn't decode
+func (c *Cartridge) openBus() uint8 {
+	return c.nes.OpenBus()
+}
+
 func (c *Cartridge) SetMirroring(mirroring common.NameTableMirroring) {
 	c.Tables.Mirroring = mirroring
 }
 
+// Peek8 reads the cpu or the ppu space of the cartridge without side effects
+func (c *Cartridge) Peek8(addr uint16) uint8 {
+	if peek, ok := c.Mapper.(peekMapper); ok {
+		return peek.Peek8(addr)
+	}
+	return c.Mapper.Read8(addr)
+}
+
+// PrgOffset returns the PRG-ROM offset mapped at a cpu address, or -1. The
+// mapper is asked through a peek
+func (c *Cartridge) PrgOffset(addr uint16) int {
+	if addr < 0x6000 {
+		return -1
+	}
+	c.prgRom.ClearLastRead()
+	c.Peek8(addr)
+	return c.prgRom.LastRead()
+}
+
+// PrgRamOffset returns the PRG-RAM offset mapped at a cpu address, or -1 if
+// no RAM is mapped there
+func (c *Cartridge) PrgRamOffset(addr uint16) int {
+	if addr < 0x6000 || addr >= 0x8000 {
+		return -1
+	}
+	c.prgRam.ClearLastRead()
+	c.Peek8(addr)
+	return c.prgRam.LastRead()
+}
+
+// ChrOffset returns the CHR offset mapped at a ppu address, or -1, eg: for
+// the CHR-RAM which some mappers keep apart
+func (c *Cartridge) ChrOffset(addr uint16) int {
+	if addr >= 0x2000 {
+		return -1
+	}
+	c.chr.ClearLastRead()
+	c.Peek8(addr)
+	return c.chr.LastRead()
+}
+
+// ClearLastReads starts tracking which PRG-ROM and CHR-ROM offsets the next
+// accesses read, eg: for the code/data logger
+func (c *Cartridge) ClearLastReads() {
+	c.prgRom.ClearLastRead()
+	c.chr.ClearLastRead()
+}
+
+// LastPrgRead returns the PRG-ROM offset read since ClearLastReads, or -1
+func (c *Cartridge) LastPrgRead() int {
+	return c.prgRom.LastRead()
+}
+
+// LastChrRead returns the CHR-ROM offset read since ClearLastReads, or -1,
+// which is also the case for CHR-RAM
+func (c *Cartridge) LastChrRead() int {
+	if c.config.chrRomSize == 0 {
+		return -1
+	}
+	return c.chr.LastRead()
+}
+
+func (c *Cartridge) PrgRomSize() int {
+	return c.prgRom.Size()
+}
+
+// ChrRomSize is 0 for the boards with CHR-RAM
+func (c *Cartridge) ChrRomSize() int {
+	return c.config.chrRomSize
+}
+
+// the physical memories, by offset, without side effects
+
+// ChrSize is the size of the CHR-ROM, or of the CHR-RAM
+func (c *Cartridge) ChrSize() int {
+	return c.chr.Size()
+}
+func (c *Cartridge) PrgRamSize() int {
+	return c.prgRam.Size()
+}
+func (c *Cartridge) PeekPrgRom(offset int) uint8 {
+	return c.prgRom.Peek8w(uint32(offset))
+}
+func (c *Cartridge) PokePrgRom(offset int, val uint8) {
+	c.prgRom.Poke8w(uint32(offset), val)
+}
+func (c *Cartridge) PeekChr(offset int) uint8 {
+	return c.chr.Peek8w(uint32(offset))
+}
+func (c *Cartridge) PokeChr(offset int, val uint8) {
+	c.chr.Poke8w(uint32(offset), val)
+}
+func (c *Cartridge) PeekPrgRam(offset int) uint8 {
+	return c.prgRam.Read8w(uint32(offset))
+}
+func (c *Cartridge) PokePrgRam(offset int, val uint8) {
+	c.prgRam.Write8w(uint32(offset), val)
+}
+
+// PrgFileOffset returns where the PRG-ROM byte mapped at a cpu address is in
+// the rom file, or -1
+func (c *Cartridge) PrgFileOffset(addr uint16) int {
+	offset := c.PrgOffset(addr)
+	if offset < 0 || c.cart == "" {
+		return -1
+	}
+	return c.prgStart + offset
+}
+
 func (c *Cartridge) WriteRom16(addr uint16, val uint16) {
 	c.prgRom.Write16(addr, val)
 }
 
 // must be called after the prgRom is loaded
-func (c *Cartridge) getRamSaveFile() *os.File {
+func (c *Cartridge) ramSavePath() string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		log.Panicf("Failed to get user homedir: %v", err)
@@ -173,7 +470,12 @@ func (c *Cartridge) getRamSaveFile() *os.File {
 	_, romName := filepath.Split(c.cart)
 	// adding a a hash of the prgRom to help since I tend to use tmp images ("a.nes") for debugging ease
 	saveFolder := fmt.Sprintf("%s/.config/gones", homeDir)
-	save := fmt.Sprintf("%s/%s_%x", saveFolder, romName, c.prgRom.Hash())
+	return fmt.Sprintf("%s/%s_%x", saveFolder, romName, c.prgRom.Hash())
+}
+
+func (c *Cartridge) getRamSaveFile() *os.File {
+	save := c.ramSave
+	saveFolder := filepath.Dir(save)
 	if _, err := os.Stat(save); os.IsNotExist(err) {
 		if err := os.MkdirAll(saveFolder, 0700); err != nil {
 			log.Panicf("Failed to create save folder: %v", err)
@@ -223,6 +525,10 @@ type Cartridge struct {
 	config  iNESConfig
 	version iNESFormat
 	cart    string
+	// where the PRG-ROM starts in the file, after the header and the trainer
+	prgStart int
+	// the battery save, see ramSavePath
+	ramSave string
 
 	prgRom *common.Rom
 	prgRam *common.Ram
@@ -0,0 +1,59 @@
+package mappers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// the PRG flashed by the game is saved under the name of the PRG as dumped,
+// so it's found again on the next start
+func TestCartridgeFlashSave(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// a flashable UNROM 512 with 32 KB of PRG-ROM and CHR-RAM
+	header := []byte{'N', 'E', 'S', 0x1A, 2, 0, 0xE2, 0x10, 0, 0, 0, 0, 0, 0, 0, 0}
+	prg := make([]byte, 0x8000)
+	for i := range prg {
+		prg[i] = 0xFF
+	}
+	rom := filepath.Join(t.TempDir(), "flash.nes")
+	if err := ioutil.WriteFile(rom, append(header, prg...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Cartridge{}
+	if err := c.Init(rom, nil); err != nil {
+		t.Fatal(err)
+	}
+	// the flash commands at $5555 and $2AAA are in the PRG banks 1 and 0
+	for _, w := range []struct {
+		bank uint8
+		addr uint16
+		val  uint8
+	}{{1, 0x9555, 0xAA}, {0, 0xAAAA, 0x55}, {1, 0x9555, 0xA0}, {0, 0x8123, 0x5A}} {
+		c.Mapper.Write8(0xC000, w.bank)
+		c.Mapper.Write8(w.addr, w.val)
+	}
+	if val := c.Mapper.Read8(0x8123); val != 0x5A {
+		t.Fatalf("flashed $%02X", val)
+	}
+	c.Stop()
+
+	c = &Cartridge{}
+	if err := c.Init(rom, nil); err != nil {
+		t.Fatal(err)
+	}
+	if val := c.Mapper.Read8(0x8123); val != 0x5A {
+		t.Errorf("read $%02X back after a restart", val)
+	}
+	saves, err := os.ReadDir(filepath.Join(home, ".config", "gones"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saves) != 1 {
+		t.Errorf("%d save files, expected 1", len(saves))
+	}
+}
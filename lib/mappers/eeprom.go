@@ -0,0 +1,217 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// Serial EEPROMs used for saves by some boards, driven bit by bit by the cpu
+// through the SCL (clock) and SDA (data) lines
+// http://wiki.nesdev.com/w/index.php/Bandai_FCG_board#Serial_EEPROM
+const (
+	eeprom24C01Size = 128
+	eeprom24C02Size = 256
+)
+
+const (
+	eepromIdle = iota
+	// 24C02 device select byte: 1010 AAA R
+	eepromDevice
+	// word address, for the X24C01 it is 7 address bits followed by R
+	eepromAddress
+	eepromWrite
+	eepromRead
+)
+
+type i2cEeprom struct {
+	data common.Ram
+	// the X24C01 uses a simplified protocol without the device select byte
+	// and shifts the bits LSB first
+	x24c01 bool
+
+	scl, sda bool
+	mode     uint8
+	nextMode uint8
+	// bits shifted in the current byte, the 9th clock is the ack
+	bits  uint8
+	shift uint8
+	addr  uint8
+	ack   bool
+	// SDA as driven by the eeprom, it's open drain so true means released
+	out bool
+}
+
+func (e *i2cEeprom) Init(x24c01 bool) {
+	e.x24c01 = x24c01
+	if x24c01 {
+		e.data.Init(eeprom24C01Size)
+	} else {
+		e.data.Init(eeprom24C02Size)
+	}
+	e.scl, e.sda = false, false
+	e.mode = eepromIdle
+	e.nextMode = eepromIdle
+	e.bits, e.shift, e.addr = 0, 0, 0
+	e.ack = false
+	e.out = true
+}
+
+// Read returns the level of the SDA line as driven by the eeprom
+func (e *i2cEeprom) Read() bool {
+	return e.out
+}
+
+// Write sets the level of both lines as driven by the cpu
+func (e *i2cEeprom) Write(scl bool, sda bool) {
+	switch {
+	case e.scl && scl && e.sda && !sda:
+		e.start()
+	case e.scl && scl && !e.sda && sda:
+		e.stop()
+	case !e.scl && scl:
+		e.rise(sda)
+	case e.scl && !scl:
+		e.fall()
+	}
+	e.scl, e.sda = scl, sda
+}
+
+func (e *i2cEeprom) start() {
+	if e.x24c01 {
+		e.mode = eepromAddress
+	} else {
+		e.mode = eepromDevice
+	}
+	e.bits, e.shift = 0, 0
+	e.ack = false
+	e.out = true
+}
+
+func (e *i2cEeprom) stop() {
+	e.mode = eepromIdle
+	e.ack = false
+	e.out = true
+}
+
+func (e *i2cEeprom) bit(val uint8, n uint8) bool {
+	if e.x24c01 {
+		return (val>>n)&1 != 0
+	}
+	return (val>>(7-n))&1 != 0
+}
+
+// data is sampled on the rising edge of SCL
+func (e *i2cEeprom) rise(sda bool) {
+	switch e.mode {
+	case eepromDevice, eepromAddress, eepromWrite:
+		if e.bits >= 8 {
+			return
+		}
+		bit := uint8(0)
+		if sda {
+			bit = 1
+		}
+		if e.x24c01 {
+			e.shift |= bit << e.bits
+		} else {
+			e.shift = e.shift<<1 | bit
+		}
+		e.bits++
+	case eepromRead:
+		if e.bits < 8 {
+			e.bits++
+		} else if sda {
+			// no ack from the cpu, stop sending until the next start
+			e.mode = eepromIdle
+		} else {
+			e.ack = true
+		}
+	}
+}
+
+// and may only change while SCL is low
+func (e *i2cEeprom) fall() {
+	switch e.mode {
+	case eepromDevice, eepromAddress, eepromWrite:
+		if e.ack {
+			e.ack = false
+			e.out = true
+			e.bits, e.shift = 0, 0
+			e.mode = e.nextMode
+			if e.mode == eepromRead {
+				e.loadByte()
+			}
+		} else if e.bits == 8 {
+			e.received()
+		}
+	case eepromRead:
+		switch {
+		case e.ack:
+			e.ack = false
+			e.addr = uint8(int(e.addr+1) % e.data.Size())
+			e.loadByte()
+		case e.bits < 8:
+			e.out = e.bit(e.shift, e.bits)
+		default:
+			// release SDA for the cpu to ack
+			e.out = true
+		}
+	}
+}
+
+func (e *i2cEeprom) loadByte() {
+	e.bits = 0
+	e.shift = e.data.Read8(uint16(e.addr))
+	e.out = e.bit(e.shift, 0)
+}
+
+// a full byte was shifted in, ack it (pull SDA low) on the 9th clock
+func (e *i2cEeprom) received() {
+	switch e.mode {
+	case eepromDevice:
+		if e.shift&0xF0 != 0xA0 {
+			e.mode = eepromIdle
+			return
+		}
+		if e.shift&1 != 0 {
+			e.nextMode = eepromRead
+		} else {
+			e.nextMode = eepromAddress
+		}
+	case eepromAddress:
+		if e.x24c01 {
+			e.addr = e.shift & 0x7F
+			if e.shift&0x80 != 0 {
+				e.nextMode = eepromRead
+			} else {
+				e.nextMode = eepromWrite
+			}
+		} else {
+			e.addr = e.shift
+			e.nextMode = eepromWrite
+		}
+	case eepromWrite:
+		e.data.Write8(uint16(e.addr), e.shift)
+		// page writes wrap around within the page: 4 bytes on the 24C01, 8 on the 24C02
+		page := uint8(7)
+		if e.x24c01 {
+			page = 3
+		}
+		e.addr = (e.addr &^ page) | ((e.addr + 1) & page)
+		e.nextMode = eepromWrite
+	}
+	e.ack = true
+	e.out = false
+}
+
+func (e *i2cEeprom) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		&e.data, e.x24c01, e.scl, e.sda, e.mode, e.nextMode,
+		e.bits, e.shift, e.addr, e.ack, e.out,
+	)
+}
+func (e *i2cEeprom) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&e.data, &e.x24c01, &e.scl, &e.sda, &e.mode, &e.nextMode,
+		&e.bits, &e.shift, &e.addr, &e.ack, &e.out,
+	)
+}
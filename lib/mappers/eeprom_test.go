@@ -0,0 +1,153 @@
+package mappers
+
+import (
+	"bytes"
+	"testing"
+)
+
+// drives the eeprom lines as the cpu does, one line change at a time
+type i2cMaster struct {
+	t *testing.T
+	e *i2cEeprom
+}
+
+func (m *i2cMaster) start() {
+	m.e.Write(false, true)
+	m.e.Write(true, true)
+	m.e.Write(true, false)
+	m.e.Write(false, false)
+}
+
+func (m *i2cMaster) stop() {
+	m.e.Write(false, false)
+	m.e.Write(true, false)
+	m.e.Write(true, true)
+}
+
+func (m *i2cMaster) clock(sda bool) {
+	m.e.Write(false, sda)
+	m.e.Write(true, sda)
+	m.e.Write(false, sda)
+}
+
+// shifts a byte out, returns whether the eeprom acked it
+func (m *i2cMaster) write(val uint8) bool {
+	for i := uint8(0); i < 8; i++ {
+		m.clock(m.e.bit(val, i))
+	}
+	m.e.Write(false, true)
+	m.e.Write(true, true)
+	ack := !m.e.Read()
+	m.e.Write(false, true)
+	return ack
+}
+
+// shifts a byte in, acking it unless it's the last one
+func (m *i2cMaster) read(last bool) uint8 {
+	val := uint8(0)
+	for i := uint8(0); i < 8; i++ {
+		m.e.Write(true, true)
+		if m.e.Read() {
+			if m.e.x24c01 {
+				val |= 1 << i
+			} else {
+				val |= 1 << (7 - i)
+			}
+		}
+		m.e.Write(false, true)
+	}
+	m.clock(last)
+	return val
+}
+
+func (m *i2cMaster) writeBytes(data ...uint8) {
+	m.t.Helper()
+	for i, val := range data {
+		if !m.write(val) {
+			m.t.Fatalf("byte %d ($%02X) not acked", i, val)
+		}
+	}
+}
+
+func (m *i2cMaster) readBytes(n int) []uint8 {
+	data := make([]uint8, n)
+	for i := range data {
+		data[i] = m.read(i == n-1)
+	}
+	return data
+}
+
+// a write transaction then a read one from another address, for the 24C02
+// the word address is set by a dummy write followed by a repeated start
+func TestI2cEeprom(t *testing.T) {
+	tests := []struct {
+		name     string
+		x24c01   bool
+		addr     uint8
+		data     []uint8
+		readAddr uint8
+		expected []uint8
+	}{
+		{name: "24C01 sequential", x24c01: true, addr: 0x10, data: []uint8{1, 2, 3}, readAddr: 0x10, expected: []uint8{1, 2, 3}},
+		// the writes wrap around within the 4 bytes page
+		{name: "24C01 page", x24c01: true, addr: 0x12, data: []uint8{1, 2, 3}, readAddr: 0x10, expected: []uint8{3, 0, 1, 2}},
+		// the reads wrap around the whole eeprom
+		{name: "24C01 last byte", x24c01: true, addr: 0x7F, data: []uint8{9}, readAddr: 0x7F, expected: []uint8{9, 0}},
+		{name: "24C02 sequential", addr: 0x80, data: []uint8{0xA5, 0x5A, 0xFF}, readAddr: 0x80, expected: []uint8{0xA5, 0x5A, 0xFF}},
+		// the writes wrap around within the 8 bytes page
+		{name: "24C02 page", addr: 0x0E, data: []uint8{1, 2, 3}, readAddr: 0x08, expected: []uint8{3, 0, 0, 0, 0, 0, 1, 2}},
+		{name: "24C02 last byte", addr: 0xFF, data: []uint8{9}, readAddr: 0xFF, expected: []uint8{9, 0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := &i2cEeprom{}
+			e.Init(test.x24c01)
+			m := &i2cMaster{t: t, e: e}
+
+			m.start()
+			if test.x24c01 {
+				m.writeBytes(test.addr)
+			} else {
+				m.writeBytes(0xA0, test.addr)
+			}
+			m.writeBytes(test.data...)
+			m.stop()
+
+			m.start()
+			if test.x24c01 {
+				m.writeBytes(test.readAddr | 0x80)
+			} else {
+				m.writeBytes(0xA0, test.readAddr)
+				m.start()
+				m.writeBytes(0xA1)
+			}
+			if data := m.readBytes(len(test.expected)); !bytes.Equal(data, test.expected) {
+				t.Errorf("read % X, expected % X", data, test.expected)
+			}
+			m.stop()
+			if !e.Read() {
+				t.Error("SDA held after the stop")
+			}
+		})
+	}
+}
+
+// the 24C02 only answers to its device select byte
+func TestI2cEepromDevice(t *testing.T) {
+	e := &i2cEeprom{}
+	e.Init(false)
+	m := &i2cMaster{t: t, e: e}
+
+	m.start()
+	if m.write(0xB0) {
+		t.Error("acked another device")
+	}
+	if m.write(0x00) || m.write(0x55) {
+		t.Error("acked the bytes sent to another device")
+	}
+	m.stop()
+	if val := e.data.Read8(0); val != 0 {
+		t.Errorf("wrote $%02X", val)
+	}
+}
@@ -0,0 +1,117 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// SST39SF040 flash used as self-programmable PRG by homebrew boards
+// http://wiki.nesdev.com/w/index.php/UNROM_512#Flash_Commands
+//
+// Commands are written as a sequence of (flash address, value) pairs:
+// Byte program: $5555 <- $AA, $2AAA <- $55, $5555 <- $A0, addr <- data
+// Sector erase: $5555 <- $AA, $2AAA <- $55, $5555 <- $80,
+//               $5555 <- $AA, $2AAA <- $55, sector <- $30
+// Chip erase:   $5555 <- $AA, $2AAA <- $55, $5555 <- $80,
+//               $5555 <- $AA, $2AAA <- $55, $5555 <- $10
+// Software ID:  $5555 <- $AA, $2AAA <- $55, $5555 <- $90, exit with $F0
+const (
+	flashSectorSize   = 0x1000
+	flashManufacturer = 0xBF
+	flashDevice       = 0xB7
+)
+
+const (
+	flashReady = iota
+	flashUnlock1
+	flashUnlock2
+	flashProgram
+	flashErase
+	flashEraseUnlock1
+	flashEraseUnlock2
+)
+
+// the flash is the PRG-ROM itself, which the game programs
+type sstFlash struct {
+	rom *common.Rom
+
+	state      uint8
+	softwareId bool
+}
+
+func (f *sstFlash) Init(rom *common.Rom) {
+	f.rom = rom
+	f.rom.SetWritable(true)
+	f.state = flashReady
+	f.softwareId = false
+}
+
+func (f *sstFlash) Read8(addr uint32) uint8 {
+	if f.softwareId {
+		if addr&1 == 0 {
+			return flashManufacturer
+		}
+		return flashDevice
+	}
+	return f.rom.Read8w(addr % uint32(f.rom.Size()))
+}
+
+func (f *sstFlash) Write8(addr uint32, val uint8) {
+	addr %= uint32(f.rom.Size())
+	command := addr & 0x7FFF
+
+	switch {
+	case f.state == flashProgram:
+		// programming can only clear bits, erasing sets them back
+		f.rom.Write8w(addr, f.rom.Peek8w(addr)&val)
+		f.state = flashReady
+	case val == 0xF0:
+		f.state = flashReady
+		f.softwareId = false
+	case f.state == flashReady && command == 0x5555 && val == 0xAA:
+		f.state = flashUnlock1
+	case f.state == flashUnlock1 && command == 0x2AAA && val == 0x55:
+		f.state = flashUnlock2
+	case f.state == flashUnlock2 && command == 0x5555:
+		f.unlocked(val)
+	case f.state == flashErase && command == 0x5555 && val == 0xAA:
+		f.state = flashEraseUnlock1
+	case f.state == flashEraseUnlock1 && command == 0x2AAA && val == 0x55:
+		f.state = flashEraseUnlock2
+	case f.state == flashEraseUnlock2 && val == 0x30:
+		f.erase(addr&^(flashSectorSize-1), flashSectorSize)
+		f.state = flashReady
+	case f.state == flashEraseUnlock2 && command == 0x5555 && val == 0x10:
+		f.erase(0, uint32(f.rom.Size()))
+		f.state = flashReady
+	default:
+		f.state = flashReady
+	}
+}
+
+func (f *sstFlash) unlocked(val uint8) {
+	switch val {
+	case 0xA0:
+		f.state = flashProgram
+	case 0x80:
+		f.state = flashErase
+	case 0x90:
+		f.softwareId = true
+		f.state = flashReady
+	default:
+		f.state = flashReady
+	}
+}
+
+func (f *sstFlash) erase(start uint32, size uint32) {
+	for addr := start; addr < start+size; addr++ {
+		f.rom.Write8w(addr, 0xFF)
+	}
+}
+
+// the PRG-ROM is saved by the cartridge
+func (f *sstFlash) Serialise(s common.Serialiser) error {
+	return s.Serialise(f.state, f.softwareId)
+}
+func (f *sstFlash) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(&f.state, &f.softwareId)
+}
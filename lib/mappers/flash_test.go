@@ -0,0 +1,124 @@
+package mappers
+
+import (
+	"testing"
+
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+type flashWrite struct {
+	addr uint32
+	val  uint8
+}
+
+var (
+	flashUnlock      = []flashWrite{{0x5555, 0xAA}, {0x2AAA, 0x55}}
+	flashEraseUnlock = []flashWrite{{0x5555, 0xAA}, {0x2AAA, 0x55}, {0x5555, 0x80}, {0x5555, 0xAA}, {0x2AAA, 0x55}}
+)
+
+func flashCommand(writes ...[]flashWrite) []flashWrite {
+	var command []flashWrite
+	for _, w := range writes {
+		command = append(command, w...)
+	}
+	return command
+}
+
+func TestSstFlash(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []flashWrite
+		// the expected reads after the writes
+		reads map[uint32]uint8
+	}{
+		{
+			name:   "byte program",
+			writes: flashCommand(flashUnlock, []flashWrite{{0x5555, 0xA0}, {0x1234, 0x5A}}),
+			reads:  map[uint32]uint8{0x1234: 0x5A, 0x1235: 0x7F},
+		},
+		{
+			// the bits cleared by a program are only set back by an erase
+			name: "program twice",
+			writes: flashCommand(
+				flashUnlock, []flashWrite{{0x5555, 0xA0}, {0x1234, 0x5A}},
+				flashUnlock, []flashWrite{{0x5555, 0xA0}, {0x1234, 0xF0}},
+			),
+			reads: map[uint32]uint8{0x1234: 0x50},
+		},
+		{
+			name:   "sector erase",
+			writes: flashCommand(flashEraseUnlock, []flashWrite{{0x3456, 0x30}}),
+			reads:  map[uint32]uint8{0x2FFF: 0x7F, 0x3000: 0xFF, 0x3FFF: 0xFF, 0x4000: 0x7F},
+		},
+		{
+			name:   "chip erase",
+			writes: flashCommand(flashEraseUnlock, []flashWrite{{0x5555, 0x10}}),
+			reads:  map[uint32]uint8{0x0000: 0xFF, 0x3000: 0xFF, 0x7FFFF: 0xFF},
+		},
+		{
+			name:   "software id",
+			writes: flashCommand(flashUnlock, []flashWrite{{0x5555, 0x90}}),
+			reads:  map[uint32]uint8{0x0000: flashManufacturer, 0x0001: flashDevice},
+		},
+		{
+			name:   "software id exit",
+			writes: flashCommand(flashUnlock, []flashWrite{{0x5555, 0x90}, {0x0000, 0xF0}}),
+			reads:  map[uint32]uint8{0x0000: 0x7F, 0x0001: 0x7F},
+		},
+		{
+			// $F0 drops the unlock, the program command is then ignored
+			name:   "reset",
+			writes: flashCommand(flashUnlock, []flashWrite{{0x0000, 0xF0}, {0x5555, 0xA0}, {0x1234, 0x00}}),
+			reads:  map[uint32]uint8{0x1234: 0x7F},
+		},
+		{
+			name:   "bad sequence",
+			writes: []flashWrite{{0x5555, 0xAA}, {0x2AAA, 0x00}, {0x5555, 0xA0}, {0x1234, 0x00}},
+			reads:  map[uint32]uint8{0x1234: 0x7F},
+		},
+		{
+			// the commands only decode the low 15 bits of the address
+			name:   "mirrored command",
+			writes: []flashWrite{{0x45555, 0xAA}, {0x12AAA, 0x55}, {0x7D555, 0xA0}, {0x1234, 0x5A}},
+			reads:  map[uint32]uint8{0x1234: 0x5A},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rom := &common.Rom{}
+			rom.Init(0x80000, false)
+			for i := 0; i < rom.Size(); i++ {
+				rom.Poke8w(uint32(i), 0x7F)
+			}
+			flash := &sstFlash{}
+			flash.Init(rom)
+
+			for _, w := range test.writes {
+				flash.Write8(w.addr, w.val)
+			}
+			if flash.state != flashReady {
+				t.Errorf("state %d after the command", flash.state)
+			}
+			for addr, expected := range test.reads {
+				if val := flash.Read8(addr); val != expected {
+					t.Errorf("read $%02X at $%05X, expected $%02X", val, addr, expected)
+				}
+			}
+		})
+	}
+}
+
+// the flash is the PRG-ROM, so the reads tell where the banks are mapped
+func TestSstFlashLastRead(t *testing.T) {
+	rom := &common.Rom{}
+	rom.Init(0x80000, false)
+	flash := &sstFlash{}
+	flash.Init(rom)
+
+	rom.ClearLastRead()
+	flash.Read8(0x12345)
+	if offset := rom.LastRead(); offset != 0x12345 {
+		t.Errorf("last read at $%05X", offset)
+	}
+}
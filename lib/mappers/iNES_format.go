@@ -2,6 +2,7 @@ package mappers
 
 import (
 	"fmt"
+	"io/ioutil"
 	"unsafe"
 )
 
@@ -19,10 +20,12 @@ const (
 
 // Archaic version of the iNES format
 type iNES0Header struct {
-	NESMagic    int32 // NESMagicConstant
-	PRG_ROMSize byte  // in 16kB units
-	CHR_ROMSize byte  // in 8kB units (0 means the board uses CHR RAM)
-	Flags6      byte  // Mapper, mirroring, battery, trainer
+	// byte array rather than an int32 so that the embedding header structs
+	// are not padded, keeping them a byte for byte overlay of the header
+	NESMagic    [4]byte // NESMagicConstant
+	PRG_ROMSize byte    // in 16kB units
+	CHR_ROMSize byte    // in 8kB units (0 means the board uses CHR RAM)
+	Flags6      byte    // Mapper, mirroring, battery, trainer
 }
 
 type iNES interface {
@@ -57,7 +60,8 @@ type iNESHeader struct {
 }
 
 type iNESConfig struct {
-	mapper       byte
+	mapper       uint16
+	submapper    byte
 	mirror       byte
 	battery      bool
 	trainer      bool
@@ -79,7 +83,7 @@ func (h *iNESHeader) MagicNumber() int32 {
 
 func (h *iNESHeader) Version() (iNESFormat, error) {
 	if h.MagicNumber() != NESMagicConstant {
-		return iNESInvalid, fmt.Errorf(fmt.Sprintf("muggle iNES file, wrong magic number: %v", h.MagicNumber()))
+		return iNESInvalid, fmt.Errorf("muggle iNES file, wrong magic number: %v", h.MagicNumber())
 	}
 
 	version := iNESFormat(iNES0)
@@ -138,7 +142,7 @@ func (h *iNES0Header) Config() iNESConfig {
 	mirror2 := (h.Flags6 >> 3) & 1
 
 	return iNESConfig{
-		mapper:       h.Flags6 >> 4,
+		mapper:       uint16(h.Flags6 >> 4),
 		mirror:       mirror1 | mirror2<<1,
 		battery:      ((h.Flags6 >> 1) & 1) == 1,
 		trainer:      h.Flags6&4 == 4,
@@ -164,7 +168,7 @@ func (h *iNES1Header) Config() iNESConfig {
 	}
 
 	return iNESConfig{
-		mapper:       mapper1 | mapper2<<4,
+		mapper:       uint16(mapper1 | mapper2<<4),
 		mirror:       mirror1 | mirror2<<1,
 		battery:      ((h.Flags6 >> 1) & 1) == 1,
 		trainer:      h.Flags6&4 == 4,
@@ -179,20 +183,22 @@ func (h *iNES1Header) Config() iNESConfig {
 }
 
 func (h *iNES2Header) Config() iNESConfig {
-	mapper1 := h.Flags6 >> 4
-	mapper2 := h.Flags7 >> 4
+	mapper1 := uint16(h.Flags6 >> 4)
+	mapper2 := uint16(h.Flags7 >> 4)
+	mapper3 := uint16(h.Flags8 & 0xF)
 	mirror1 := h.Flags6 & 1
 	mirror2 := (h.Flags6 >> 3) & 1
 
 	prgRomSize := int(h.PRG_ROMSize) | int(h.Flags9&0xF)<<8
-	prgRamSize := 64 << int(h.Flags10&0xF)
-	prgNVRamSize := 64 << (int(h.Flags10&0xF0) >> 4)
+	prgRamSize := iNES2RamSize(h.Flags10 & 0xF)
+	prgNVRamSize := iNES2RamSize(h.Flags10 >> 4)
 	chrSize := int(h.CHR_ROMSize) | int(h.Flags9&0xF0)<<4
-	ramSize := 64 << int(h.Flags11&0xF)
-	nvRamSize := 64 << (int(h.Flags11&0xF0) >> 4)
+	ramSize := iNES2RamSize(h.Flags11 & 0xF)
+	nvRamSize := iNES2RamSize(h.Flags11 >> 4)
 
 	return iNESConfig{
-		mapper:       mapper1 | mapper2<<4,
+		mapper:       mapper1 | mapper2<<4 | mapper3<<8,
+		submapper:    h.Flags8 >> 4,
 		mirror:       mirror1 | mirror2<<1,
 		battery:      ((h.Flags6 >> 1) & 1) == 1,
 		trainer:      h.Flags6&4 == 4,
@@ -206,6 +212,14 @@ func (h *iNES2Header) Config() iNESConfig {
 	}
 }
 
+// RAM sizes are 64 << shift bytes, a shift of 0 means there's no RAM
+func iNES2RamSize(shift byte) int {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << int(shift)
+}
+
 // Vs. PPU types (Header byte 13 D0..D3)
 //$D-F: reserved
 const (
@@ -232,3 +246,31 @@ const (
 	consolePlayChoice10
 	consoleExtended
 )
+
+// ReadPrgRom reads the PRG ROM of an iNES file, without setting up the
+// cartridge, eg: for a disassembler. The offset is where it is in the file
+func ReadPrgRom(path string) (prg []byte, offset int, err error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	header := iNESHeader{}
+	if len(data) < len(header.Flags) {
+		return nil, 0, fmt.Errorf("%s is too short for an iNES file", path)
+	}
+	copy(header.Flags[:], data)
+	config, err := header.Config()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	offset = len(header.Flags)
+	if config.trainer {
+		offset += 512
+	}
+	if offset+config.prgRomSize > len(data) {
+		return nil, 0, fmt.Errorf("%s is truncated, the PRG ROM is %d bytes", path, config.prgRomSize)
+	}
+	return data[offset : offset+config.prgRomSize], offset, nil
+}
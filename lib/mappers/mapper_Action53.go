@@ -0,0 +1,198 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// Action 53 (mapper 28), a multicart mapper which can emulate most discrete
+// boards through an outer bank and an inner bank of configurable sizes
+// https://wiki.nesdev.com/w/index.php/Action_53
+const action53ChrRamSize = 0x8000
+
+const (
+	action53RegChr   = 0x00
+	action53RegInner = 0x01
+	action53RegMode  = 0x80
+	action53RegOuter = 0x81
+)
+
+type MapperAction53 struct {
+	cart *Cartridge
+
+	register uint8
+	chrBank  uint8
+	inner    uint8
+	mode     uint8
+	outer    uint8
+}
+
+func (m *MapperAction53) Init() {
+	m.register = 0
+	m.chrBank = 0
+	m.inner = 0
+	m.mode = 0
+	// power on in the last bank, where the menu lives
+	m.outer = 0xFF
+	if m.cart.config.chrRomSize == 0 {
+		m.cart.chr.Init(action53ChrRamSize, true)
+	}
+}
+
+func (m *MapperAction53) Tick() {}
+
+func (m *MapperAction53) decodesExpansion() bool {
+	return true
+}
+
+// Register select ($5000-$5FFF)
+//
+// 7  bit  0
+// ---- ----
+// Sxxx xxxR
+// |       |
+// +-------+- Select register to update on writes to $8000-$FFFF:
+//            $00: CHR bank, $01: inner PRG bank, $80: mode, $81: outer PRG bank
+func (m *MapperAction53) writeRegisterSelect(val uint8) {
+	m.register = val & 0x81
+}
+
+// Register data ($8000-$FFFF), to the register selected through $5000
+//
+// CHR bank ($00)
+// 7  bit  0
+// ---- ----
+// xxxM xxCC
+//    |   ||
+//    |   ++- Select 8 KB CHR RAM bank at PPU $0000
+//    +------ One-screen page, in the one-screen mirroring modes only
+//
+// Inner bank ($01)
+// 7  bit  0
+// ---- ----
+// xxxM PPPP
+//    | ||||
+//    | ++++- Select 16 KB (or 32 KB) inner PRG bank
+//    +------ One-screen page, in the one-screen mirroring modes only
+//
+// Mode ($80)
+// 7  bit  0
+// ---- ----
+// xxSS PPMM
+//   || ||||
+//   || ||++- Mirroring (0: one-screen lower; 1: one-screen upper; 2: vertical; 3: horizontal)
+//   || ++--- PRG bank mode (0, 1: 32 KB; 2: fix first 16 KB at $8000; 3: fix last 16 KB at $C000)
+//   ++------ Game size (0: 32 KB; 1: 64 KB; 2: 128 KB; 3: 256 KB)
+//
+// Outer bank ($81)
+// 7  bit  0
+// ---- ----
+// BBBB BBBB
+// |||| ||||
+// ++++-++++- Select 32 KB outer PRG bank
+func (m *MapperAction53) writeRegisterData(val uint8) {
+	switch m.register {
+	case action53RegChr:
+		m.chrBank = val & 0x3
+		m.writeOneScreen(val)
+	case action53RegInner:
+		m.inner = val & 0xF
+		m.writeOneScreen(val)
+	case action53RegMode:
+		m.mode = val & 0x3F
+	case action53RegOuter:
+		m.outer = val
+	}
+	m.updateMirroring()
+}
+
+func (m *MapperAction53) writeOneScreen(val uint8) {
+	if (m.mode & 0x2) == 0 {
+		m.mode = (m.mode &^ 0x1) | (val>>4)&0x1
+	}
+}
+
+func (m *MapperAction53) updateMirroring() {
+	switch m.mode & 0x3 {
+	case 0:
+		m.cart.SetMirroring(common.SingleScreenMirroring)
+	case 1:
+		m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+	case 2:
+		m.cart.SetMirroring(common.VerticalMirroring)
+	case 3:
+		m.cart.SetMirroring(common.HorizontalMirroring)
+	}
+}
+
+// The game size sets how many of the low 16 KB bank bits come from the inner
+// bank, the remaining ones come from the outer bank. Fixed 16 KB banks are
+// the halves of the current outer 32 KB bank
+func (m *MapperAction53) prgBank(addr uint16) uint32 {
+	a14 := uint32(addr>>14) & 1
+	outer := uint32(m.outer) << 1
+	mask := uint32(2)<<((m.mode>>4)&0x3) - 1
+	prgMode := (m.mode >> 2) & 0x3
+
+	switch {
+	case prgMode < 2:
+		return (outer &^ mask) | ((uint32(m.inner)<<1 | a14) & mask)
+	case prgMode == 2 && a14 == 0, prgMode == 3 && a14 == 1:
+		return outer | a14
+	default:
+		return (outer &^ mask) | (uint32(m.inner) & mask)
+	}
+}
+
+func (m *MapperAction53) prgAddr(addr uint16) uint32 {
+	return (m.prgBank(addr)*0x4000 + uint32(addr%0x4000)) % uint32(m.cart.prgRom.Size())
+}
+func (m *MapperAction53) chrAddr(addr uint16) uint32 {
+	return (uint32(m.chrBank)*0x2000 + uint32(addr)) % uint32(m.cart.chr.Size())
+}
+
+// PPU $0000-$1FFF: 8 KB switchable CHR RAM bank
+// CPU $5000-$5FFF: Register select
+// CPU $8000-$BFFF: 16 KB PRG ROM bank, switchable or fixed
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, switchable or fixed
+func (m *MapperAction53) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() == 0 {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+	case addr >= 0x8000:
+		return m.cart.prgRom.Read8w(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperAction53) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+	case addr >= 0x5000 && addr < 0x6000:
+		m.writeRegisterSelect(val)
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
+	case addr >= 0x8000:
+		m.writeRegisterData(val)
+	}
+}
+
+func (m *MapperAction53) mapperRegisters() []MapperRegister {
+	return []MapperRegister{
+		{"register", int(m.register)}, {"chrBank", int(m.chrBank)}, {"inner", int(m.inner)},
+		{"mode", int(m.mode)}, {"outer", int(m.outer)},
+	}
+}
+
+func (m *MapperAction53) Serialise(s common.Serialiser) error {
+	return s.Serialise(m.register, m.chrBank, m.inner, m.mode, m.outer)
+}
+func (m *MapperAction53) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(&m.register, &m.chrBank, &m.inner, &m.mode, &m.outer)
+}
@@ -0,0 +1,310 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// https://wiki.nesdev.com/w/index.php/Bandai_FCG_board
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_016
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_153
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_157
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_159
+//
+// 16:  FCG-1/2 (submapper 4) or LZ93D50 with a 24C02 (submapper 5),
+//      unknown boards (submapper 0) decode both register ranges
+// 153: LZ93D50 with 8KB of battery backed PRG-RAM and a 512KB PRG-ROM
+// 157: LZ93D50 Datach Joint ROM System, 24C02 plus the 24C01 of the game
+// 159: LZ93D50 with a 24C01
+type MapperBandaiFCG struct {
+	cart   *Cartridge
+	mapper uint16
+
+	// the FCG-1/2 decode the registers at $6000-$7FFF, the LZ93D50 at $8000-$FFFF
+	fcgRegs bool
+	lzRegs  bool
+
+	chrRegs [8]uint8
+	prgReg  uint8
+	// mapper 153 selects the 256KB outer PRG bank through the CHR registers
+	prgOuter  uint8
+	ramEnable bool
+
+	irqCounter uint16
+	irqLatch   uint16
+	irqEnable  bool
+	irqPending bool
+
+	eeprom         i2cEeprom
+	eepromExternal i2cEeprom
+	eepromRead     bool
+}
+
+func (m *MapperBandaiFCG) Init() {
+	submapper := m.cart.config.submapper
+	m.fcgRegs = m.mapper == 16 && submapper != 5
+	m.lzRegs = m.mapper != 16 || submapper != 4
+
+	m.chrRegs = [8]uint8{}
+	m.prgReg = 0
+	m.prgOuter = 0
+	m.ramEnable = false
+
+	m.irqCounter = 0
+	m.irqLatch = 0
+	m.irqEnable = false
+	m.irqPending = false
+
+	m.eeprom.Init(m.mapper == 159)
+	m.eepromExternal.Init(true)
+	m.eepromRead = false
+}
+
+func (m *MapperBandaiFCG) Tick() {}
+
+// The IRQ counter is decremented every cpu cycle and fires when it wraps
+// from 0, the IRQ line is held until acknowledged through $xA
+func (m *MapperBandaiFCG) CpuTick() {
+	if m.irqEnable {
+		if m.irqCounter == 0 {
+			m.irqPending = true
+		}
+		m.irqCounter--
+	}
+	if m.irqPending {
+		m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
+	}
+}
+
+func (m *MapperBandaiFCG) hasEeprom() bool {
+	return m.lzRegs && m.mapper != 153
+}
+
+func (m *MapperBandaiFCG) BatteryRams() []*common.Ram {
+	switch {
+	case m.mapper == 157:
+		return []*common.Ram{&m.eeprom.data, &m.eepromExternal.data}
+	case m.hasEeprom():
+		return []*common.Ram{&m.eeprom.data}
+	}
+	return nil
+}
+
+func (m *MapperBandaiFCG) writeRegister(addr uint16, val uint8, lz93d50 bool) {
+	switch register := addr & 0xF; {
+	case register < 0x8:
+		m.writeChrBank(register, val)
+	case register == 0x8:
+		m.prgReg = val & 0xF
+	case register == 0x9:
+		m.writeMirroring(val)
+	case register == 0xA:
+		m.writeIrqControl(val, lz93d50)
+	case register == 0xB:
+		m.writeIrqValue(uint16(val), 0xFF00, lz93d50)
+	case register == 0xC:
+		m.writeIrqValue(uint16(val)<<8, 0x00FF, lz93d50)
+	case register == 0xD:
+		m.writeEepromControl(val)
+	}
+}
+
+// CHR-ROM Bank Select ($x0-$x7)
+// 7  bit  0
+// ---- ----
+// CCCC CCCC
+// |||| ||||
+// ++++-++++- 1 KB CHR-ROM bank at PPU $0000 + $400*x
+//
+// Mapper 153 has CHR-RAM instead and uses bit 0 as PRG A18
+func (m *MapperBandaiFCG) writeChrBank(register uint16, val uint8) {
+	m.chrRegs[register] = val
+	if m.mapper == 153 && register < 4 {
+		m.prgOuter = val & 1
+	}
+}
+
+// Mirroring Control ($x9)
+// 7  bit  0
+// ---- ----
+// xxxx xxMM
+//        ||
+//        ++- Mirroring (0: vertical; 1: horizontal; 2: one-screen A; 3: one-screen B)
+func (m *MapperBandaiFCG) writeMirroring(val uint8) {
+	switch val & 0x3 {
+	case 0:
+		m.cart.SetMirroring(common.VerticalMirroring)
+	case 1:
+		m.cart.SetMirroring(common.HorizontalMirroring)
+	case 2:
+		m.cart.SetMirroring(common.SingleScreenMirroring)
+	case 3:
+		m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+	}
+}
+
+// IRQ Control ($xA)
+// 7  bit  0
+// ---- ----
+// xxxx xxxC
+//         |
+//         +- IRQ counter enable, writing also acknowledges a pending IRQ
+//
+// On the LZ93D50 writing here also copies the latch into the counter
+func (m *MapperBandaiFCG) writeIrqControl(val uint8, lz93d50 bool) {
+	m.irqEnable = (val & 1) != 0
+	if lz93d50 {
+		m.irqCounter = m.irqLatch
+	}
+	m.irqPending = false
+	m.cart.nes.CPU().Clear(cpu.CpuIntIRQ)
+}
+
+// IRQ Latch/Counter ($xB-$xC)
+// $xB: low 8 bits, $xC: high 8 bits
+// The FCG-1/2 write the counter directly, the LZ93D50 write a latch
+func (m *MapperBandaiFCG) writeIrqValue(val uint16, keep uint16, lz93d50 bool) {
+	if lz93d50 {
+		m.irqLatch = (m.irqLatch & keep) | val
+	} else {
+		m.irqCounter = (m.irqCounter & keep) | val
+	}
+}
+
+// EEPROM Control ($800D)
+// 7  bit  0
+// ---- ----
+// RDCx Sxxx
+// |||  |
+// |||  +---- SCL of the external 24C01 (Datach only)
+// ||+------- SCL, or PRG-RAM enable on mapper 153
+// |+-------- SDA
+// +--------- Direction of SDA, 1 to read the EEPROM at $6000-$7FFF
+func (m *MapperBandaiFCG) writeEepromControl(val uint8) {
+	if m.mapper == 153 {
+		m.ramEnable = (val & 0x20) != 0
+		return
+	}
+	if !m.hasEeprom() {
+		return
+	}
+	sda := (val & 0x40) != 0
+	m.eepromRead = (val & 0x80) != 0
+	m.eeprom.Write((val&0x20) != 0, sda)
+	if m.mapper == 157 {
+		m.eepromExternal.Write((val&0x08) != 0, sda)
+	}
+}
+
+// EEPROM data out ($6000-$7FFF) R
+// 7  bit  0
+// ---- ----
+// xxxE Bxxx
+//    | |
+//    | +---- Barcode reader data (Datach only, not connected)
+//    +------ SDA as driven by the EEPROM
+func (m *MapperBandaiFCG) readEeprom() uint8 {
+	sda := m.eeprom.Read()
+	if m.mapper == 157 {
+		sda = sda && m.eepromExternal.Read()
+	}
+	// only SDA is driven, the other bits are open bus
+	val := m.cart.openBus() &^ 0x10
+	if m.eepromRead && sda {
+		val |= 0x10
+	}
+	return val
+}
+
+func (m *MapperBandaiFCG) prgBankAddr(bank uint8, addr uint16) uint32 {
+	bank |= m.prgOuter << 4
+	banks := uint32(m.cart.prgRom.Size() / 0x4000)
+	return (uint32(bank)%banks)*0x4000 + uint32(addr%0x4000)
+}
+
+func (m *MapperBandaiFCG) chrAddr(addr uint16) uint32 {
+	if m.cart.config.chrRomSize == 0 {
+		return uint32(addr)
+	}
+	return (uint32(m.chrRegs[addr/0x400])*0x400 + uint32(addr%0x400)) % uint32(m.cart.chr.Size())
+}
+
+// PPU $0000-$1FFF: Eight 1 KB switchable CHR-ROM banks, or 8 KB of CHR-RAM
+// CPU $6000-$7FFF: EEPROM data out, PRG-RAM (153) or the FCG-1/2 registers
+// CPU $8000-$BFFF: 16 KB switchable PRG-ROM bank
+// CPU $C000-$FFFF: 16 KB PRG-ROM bank, fixed to the last bank
+func (m *MapperBandaiFCG) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.mapper == 153 {
+			if !m.ramEnable || m.cart.prgRam.Size() == 0 {
+				return m.cart.openBus()
+			}
+			return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+		}
+		if m.hasEeprom() {
+			return m.readEeprom()
+		}
+		return m.cart.openBus()
+
+	case addr >= 0x8000 && addr < 0xC000:
+		return m.cart.prgRom.Read8w(m.prgBankAddr(m.prgReg, addr))
+	case addr >= 0xC000:
+		return m.cart.prgRom.Read8w(m.prgBankAddr(0xF, addr))
+	}
+	return m.cart.openBus()
+}
+
+func (m *MapperBandaiFCG) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.fcgRegs {
+			m.writeRegister(addr, val, false)
+		} else if m.mapper == 153 && m.ramEnable && m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
+
+	case addr >= 0x8000:
+		if m.lzRegs {
+			m.writeRegister(addr, val, true)
+		}
+	}
+}
+
+func (m *MapperBandaiFCG) mapperRegisters() []MapperRegister {
+	regs := append(registerFile("chrRegs", m.chrRegs[:]),
+		MapperRegister{"prgReg", int(m.prgReg)},
+		MapperRegister{"irqCounter", int(m.irqCounter)},
+		MapperRegister{"irqLatch", int(m.irqLatch)},
+		boolRegister("irqEnable", m.irqEnable),
+		boolRegister("irqPending", m.irqPending),
+	)
+	if m.mapper == 153 {
+		regs = append(regs, MapperRegister{"prgOuter", int(m.prgOuter)}, boolRegister("ramEnable", m.ramEnable))
+	}
+	if m.hasEeprom() {
+		regs = append(regs, boolRegister("eepromRead", m.eepromRead))
+	}
+	return regs
+}
+
+func (m *MapperBandaiFCG) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		m.chrRegs, m.prgReg, m.prgOuter, m.ramEnable,
+		m.irqCounter, m.irqLatch, m.irqEnable, m.irqPending,
+		&m.eeprom, &m.eepromExternal, m.eepromRead,
+	)
+}
+func (m *MapperBandaiFCG) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.chrRegs, &m.prgReg, &m.prgOuter, &m.ramEnable,
+		&m.irqCounter, &m.irqLatch, &m.irqEnable, &m.irqPending,
+		&m.eeprom, &m.eepromExternal, &m.eepromRead,
+	)
+}
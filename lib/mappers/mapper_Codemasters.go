@@ -0,0 +1,96 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// Camerica/Codemasters BF909x (mapper 71)
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_071
+// The BF9097 (submapper 1, Fire Hawk) adds a one-screen mirroring register
+// at $8000-$9FFF. Old dumps don't set the submapper so the register is also
+// decoded at $9000-$9FFF on every board, but not at $8000-$8FFF, which other
+// BF909x games write to
+type MapperCodemasters struct {
+	cart *Cartridge
+
+	prgBank uint8
+}
+
+func (m *MapperCodemasters) Init() {
+	m.prgBank = 0
+	if m.cart.config.submapper == 1 {
+		m.cart.SetMirroring(common.SingleScreenMirroring)
+	}
+}
+
+func (m *MapperCodemasters) Tick() {}
+
+// Mirroring ($9000-$9FFF, or $8000-$9FFF on the BF9097)
+//
+// 7  bit  0
+// ---- ----
+// xxxM xxxx
+//    |
+//    +----- Select the one-screen CIRAM page
+//
+// Bank select ($C000-$FFFF)
+//
+// 7  bit  0
+// ---- ----
+// xxxx PPPP
+//      ||||
+//      ++++- Select 16 KB PRG ROM bank at $8000
+func (m *MapperCodemasters) writeRegister(addr uint16, val uint8) {
+	switch {
+	case addr >= 0x9000 && addr < 0xA000,
+		addr >= 0x8000 && addr < 0xA000 && m.cart.config.submapper == 1:
+		if (val & 0x10) == 0 {
+			m.cart.SetMirroring(common.SingleScreenMirroring)
+		} else {
+			m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+		}
+	case addr >= 0xC000:
+		m.prgBank = val & 0xF
+	}
+}
+
+func (m *MapperCodemasters) prgAddr(addr uint16) uint32 {
+	banks := uint32(m.cart.prgRom.Size() / 0x4000)
+	bank := banks - 1
+	if addr < 0xC000 {
+		bank = uint32(m.prgBank) % banks
+	}
+	return bank*0x4000 + uint32(addr%0x4000)
+}
+
+// PPU $0000-$1FFF: 8 KB CHR RAM
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+func (m *MapperCodemasters) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8(addr)
+	case addr >= 0x8000:
+		return m.cart.prgRom.Read8w(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperCodemasters) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8(addr, val)
+	case addr >= 0x8000:
+		m.writeRegister(addr, val)
+	}
+}
+
+func (m *MapperCodemasters) mapperRegisters() []MapperRegister {
+	return []MapperRegister{{"prgBank", int(m.prgBank)}}
+}
+
+func (m *MapperCodemasters) Serialise(s common.Serialiser) error {
+	return s.Serialise(m.prgBank)
+}
+func (m *MapperCodemasters) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(&m.prgBank)
+}
@@ -0,0 +1,136 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// GTROM / Cheapocabra (mapper 111)
+// https://wiki.nesdev.com/w/index.php/GTROM
+// 512 KB of self-flashable PRG, 16 KB of CHR RAM and 16 KB of nametable RAM
+// used as two four-screen 8 KB pages
+const (
+	gtromChrRamSize   = 0x4000
+	gtromTableRamSize = 0x4000
+	gtromTablePage    = 0x2000
+)
+
+type MapperGTROM struct {
+	cart *Cartridge
+
+	prgBank   uint8
+	chrBank   uint8
+	tableBank uint8
+	// the LEDs are lit when their bit is clear
+	redLed   bool
+	greenLed bool
+
+	tableRam common.Ram
+	flash    sstFlash
+}
+
+func (m *MapperGTROM) Init() {
+	m.prgBank = 0
+	m.chrBank = 0
+	m.tableBank = 0
+	m.redLed = false
+	m.greenLed = false
+	if m.cart.config.chrRomSize == 0 {
+		m.cart.chr.Init(gtromChrRamSize, true)
+	}
+	m.tableRam.Init(gtromTableRamSize)
+	m.flash.Init(m.cart.prgRom)
+}
+
+func (m *MapperGTROM) Tick() {}
+
+func (m *MapperGTROM) decodesExpansion() bool {
+	return true
+}
+
+func (m *MapperGTROM) flashesPrg() bool {
+	return true
+}
+
+// LEDs returns whether the red and green LEDs of the board are lit
+func (m *MapperGTROM) LEDs() (red bool, green bool) {
+	return m.redLed, m.greenLed
+}
+
+// Bank select ($5000-$5FFF, $7000-$7FFF)
+//
+// 7  bit  0
+// ---- ----
+// GRNC PPPP
+// |||| ++++- Select 32 KB PRG ROM bank for CPU $8000-$FFFF
+// |||+------ Select 8 KB CHR RAM bank for PPU $0000-$1FFF
+// ||+------- Select 8 KB nametable RAM page for PPU $2000-$3EFF
+// |+-------- Red LED (0: on; 1: off)
+// +--------- Green LED (0: on; 1: off)
+func (m *MapperGTROM) writeBankSelect(val uint8) {
+	m.prgBank = val & 0xF
+	m.chrBank = (val >> 4) & 1
+	m.tableBank = (val >> 5) & 1
+	m.redLed = (val & 0x40) == 0
+	m.greenLed = (val & 0x80) == 0
+}
+
+func (m *MapperGTROM) prgAddr(addr uint16) uint32 {
+	return uint32(m.prgBank)*0x8000 + uint32(addr-0x8000)
+}
+func (m *MapperGTROM) chrAddr(addr uint16) uint32 {
+	return (uint32(m.chrBank)*0x2000 + uint32(addr)) % uint32(m.cart.chr.Size())
+}
+func (m *MapperGTROM) tableAddr(addr uint16) uint16 {
+	return uint16(m.tableBank)*gtromTablePage + (addr-0x2000)%gtromTablePage
+}
+
+// PPU $2000-$3EFF: 8 KB switchable nametable RAM page, four-screen
+func (m *MapperGTROM) ReadTable(addr uint16) uint8 {
+	return m.tableRam.Read8(m.tableAddr(addr))
+}
+func (m *MapperGTROM) WriteTable(addr uint16, val uint8) {
+	m.tableRam.Write8(m.tableAddr(addr), val)
+}
+
+// PPU $0000-$1FFF: 8 KB switchable CHR RAM bank
+// CPU $5000-$5FFF: Bank select register, mirrored at $7000-$7FFF
+// CPU $8000-$FFFF: 32 KB switchable PRG flash bank, writes go to the flash
+func (m *MapperGTROM) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr >= 0x8000:
+		return m.flash.Read8(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperGTROM) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+	case addr >= 0x5000 && addr < 0x6000, addr >= 0x7000 && addr < 0x8000:
+		m.writeBankSelect(val)
+	case addr >= 0x8000:
+		m.flash.Write8(m.prgAddr(addr), val)
+	}
+}
+
+func (m *MapperGTROM) mapperRegisters() []MapperRegister {
+	return []MapperRegister{
+		{"prgBank", int(m.prgBank)}, {"chrBank", int(m.chrBank)}, {"tableBank", int(m.tableBank)},
+		boolRegister("redLed", m.redLed), boolRegister("greenLed", m.greenLed),
+	}
+}
+
+func (m *MapperGTROM) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		m.prgBank, m.chrBank, m.tableBank, m.redLed, m.greenLed,
+		&m.tableRam, &m.flash,
+	)
+}
+func (m *MapperGTROM) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.prgBank, &m.chrBank, &m.tableBank, &m.redLed, &m.greenLed,
+		&m.tableRam, &m.flash,
+	)
+}
@@ -0,0 +1,228 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// Irem G-101 (mapper 32) and H3001 (mapper 65)
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_032
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_065
+//
+// 32: G-101, submapper 1 (Major League) has CIRAM A10 tied low and no
+//     PRG mode
+// 65: H3001, with a cpu cycle IRQ counter
+type MapperIrem struct {
+	cart   *Cartridge
+	mapper uint16
+
+	prgRegs [3]uint8
+	chrRegs [8]uint8
+	// swaps the $8000 and $C000 PRG banks
+	prgMode bool
+
+	irqCounter uint16
+	irqLatch   uint16
+	irqEnable  bool
+	irqPending bool
+}
+
+func (m *MapperIrem) Init() {
+	m.prgRegs = [3]uint8{0, 1, 0xFE}
+	m.chrRegs = [8]uint8{}
+	m.prgMode = false
+
+	m.irqCounter = 0
+	m.irqLatch = 0
+	m.irqEnable = false
+	m.irqPending = false
+
+	if m.majorLeague() {
+		m.cart.SetMirroring(common.SingleScreenMirroring)
+	}
+}
+
+func (m *MapperIrem) Tick() {}
+
+// The H3001 counter is decremented every cpu cycle and stops when it
+// reaches 0, holding the IRQ line until acknowledged through $9003/$9004
+func (m *MapperIrem) CpuTick() {
+	if m.mapper != 65 {
+		return
+	}
+	if m.irqEnable && m.irqCounter > 0 {
+		m.irqCounter--
+		if m.irqCounter == 0 {
+			m.irqPending = true
+		}
+	}
+	if m.irqPending {
+		m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
+	}
+}
+
+func (m *MapperIrem) majorLeague() bool {
+	return m.mapper == 32 && m.cart.config.submapper == 1
+}
+
+func (m *MapperIrem) acknowledgeIrq() {
+	m.irqPending = false
+	m.cart.nes.CPU().Clear(cpu.CpuIntIRQ)
+}
+
+// G-101 registers
+//
+// $8000-$8FFF: PRG reg 0, 8 KB at $8000 (or $C000)
+// $9000-$9FFF: [.... ..PM]
+//                      |+- Mirroring (0: vertical; 1: horizontal)
+//                      +-- PRG mode (0: reg 0 at $8000, second last bank at $C000;
+//                                    1: reg 0 at $C000, second last bank at $8000)
+// $A000-$AFFF: PRG reg 1, 8 KB at $A000
+// $B000-$B007: CHR regs, 1 KB at PPU $0000-$1FFF
+func (m *MapperIrem) writeG101(addr uint16, val uint8) {
+	switch addr & 0xF000 {
+	case 0x8000:
+		m.prgRegs[0] = val & 0x1F
+	case 0x9000:
+		if m.majorLeague() {
+			return
+		}
+		m.prgMode = (val & 0x2) != 0
+		if (val & 0x1) == 0 {
+			m.cart.SetMirroring(common.VerticalMirroring)
+		} else {
+			m.cart.SetMirroring(common.HorizontalMirroring)
+		}
+	case 0xA000:
+		m.prgRegs[1] = val & 0x1F
+	case 0xB000:
+		m.chrRegs[addr&0x7] = val
+	}
+}
+
+// H3001 registers
+//
+// $8000: PRG reg 0, 8 KB at $8000 (or $C000)
+// $9000: [P... ....] PRG mode, swaps reg 0 and reg 2 like the G-101
+// $9001: [MM.. ....] Mirroring (0: vertical; 2: horizontal; 1, 3: one-screen)
+// $9003: [E... ....] IRQ enable, acknowledges the IRQ
+// $9004: IRQ reload, copies the latch into the counter and acknowledges
+// $9005: IRQ latch high byte
+// $9006: IRQ latch low byte
+// $A000: PRG reg 1, 8 KB at $A000
+// $B000-$B007: CHR regs, 1 KB at PPU $0000-$1FFF
+// $C000: PRG reg 2, 8 KB at $C000 (or $8000)
+func (m *MapperIrem) writeH3001(addr uint16, val uint8) {
+	switch {
+	case addr == 0x8000:
+		m.prgRegs[0] = val
+	case addr == 0x9000:
+		m.prgMode = (val & 0x80) != 0
+	case addr == 0x9001:
+		switch val >> 6 {
+		case 0:
+			m.cart.SetMirroring(common.VerticalMirroring)
+		case 2:
+			m.cart.SetMirroring(common.HorizontalMirroring)
+		default:
+			m.cart.SetMirroring(common.SingleScreenMirroring)
+		}
+	case addr == 0x9003:
+		m.irqEnable = (val & 0x80) != 0
+		m.acknowledgeIrq()
+	case addr == 0x9004:
+		m.irqCounter = m.irqLatch
+		m.acknowledgeIrq()
+	case addr == 0x9005:
+		m.irqLatch = (m.irqLatch & 0x00FF) | uint16(val)<<8
+	case addr == 0x9006:
+		m.irqLatch = (m.irqLatch & 0xFF00) | uint16(val)
+	case addr == 0xA000:
+		m.prgRegs[1] = val
+	case addr >= 0xB000 && addr <= 0xB007:
+		m.chrRegs[addr&0x7] = val
+	case addr == 0xC000:
+		m.prgRegs[2] = val
+	}
+}
+
+// the G-101 has no PRG reg 2, its $C000 bank is always the second last
+func (m *MapperIrem) prgBank(addr uint16) uint32 {
+	banks := uint32(m.cart.prgRom.Size() / 0x2000)
+	slot := (addr - 0x8000) / 0x2000
+	if m.prgMode && slot != 1 && slot != 3 {
+		slot ^= 2
+	}
+
+	switch {
+	case slot == 3:
+		return banks - 1
+	case slot == 2 && m.mapper == 32:
+		return banks - 2
+	}
+	return uint32(m.prgRegs[slot]) % banks
+}
+
+func (m *MapperIrem) chrAddr(addr uint16) uint32 {
+	bank := uint32(m.chrRegs[addr/0x400])
+	return (bank*0x400 + uint32(addr%0x400)) % uint32(m.cart.chr.Size())
+}
+
+// PPU $0000-$1FFF: Eight 1 KB switchable CHR banks
+// CPU $6000-$7FFF: 8 KB PRG RAM (G-101)
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank, or fixed with $C000
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+// CPU $C000-$DFFF: 8 KB PRG ROM bank, switchable on the H3001 or second last
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+func (m *MapperIrem) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() == 0 {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+	case addr >= 0x8000:
+		return m.cart.prgRom.Read8w(m.prgBank(addr)*0x2000 + uint32(addr%0x2000))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperIrem) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
+	case addr >= 0x8000 && m.mapper == 32:
+		m.writeG101(addr, val)
+	case addr >= 0x8000:
+		m.writeH3001(addr, val)
+	}
+}
+
+func (m *MapperIrem) mapperRegisters() []MapperRegister {
+	regs := append(registerFile("prgRegs", m.prgRegs[:]), registerFile("chrRegs", m.chrRegs[:])...)
+	return append(regs,
+		boolRegister("prgMode", m.prgMode),
+		MapperRegister{"irqCounter", int(m.irqCounter)},
+		MapperRegister{"irqLatch", int(m.irqLatch)},
+		boolRegister("irqEnable", m.irqEnable),
+		boolRegister("irqPending", m.irqPending),
+	)
+}
+
+func (m *MapperIrem) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		m.prgRegs, m.chrRegs, m.prgMode,
+		m.irqCounter, m.irqLatch, m.irqEnable, m.irqPending,
+	)
+}
+func (m *MapperIrem) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.prgRegs, &m.chrRegs, &m.prgMode,
+		&m.irqCounter, &m.irqLatch, &m.irqEnable, &m.irqPending,
+	)
+}
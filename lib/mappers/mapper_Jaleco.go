@@ -0,0 +1,204 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// Jaleco SS88006 (mapper 18)
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_018
+// Every register is written a nibble at a time, the even address takes the
+// low nibble and the odd one the high nibble. The uPD7756 ADPCM samples of
+// some boards are not emulated
+type MapperJaleco struct {
+	cart *Cartridge
+
+	prgRegs   [3]uint8
+	chrRegs   [8]uint8
+	ramEnable bool
+	ramWrite  bool
+
+	irqReload  [4]uint8
+	irqCounter uint16
+	irqMask    uint16
+	irqEnable  bool
+	irqPending bool
+}
+
+func (m *MapperJaleco) Init() {
+	m.prgRegs = [3]uint8{}
+	m.chrRegs = [8]uint8{}
+	m.ramEnable = false
+	m.ramWrite = false
+
+	m.irqReload = [4]uint8{}
+	m.irqCounter = 0
+	m.irqMask = 0xFFFF
+	m.irqEnable = false
+	m.irqPending = false
+}
+
+func (m *MapperJaleco) Tick() {}
+
+// Only the low 4, 8, 12 or 16 bits of the counter are decremented, the IRQ
+// fires when those wrap from 0 and is held until acknowledged
+func (m *MapperJaleco) CpuTick() {
+	if m.irqEnable {
+		low := m.irqCounter & m.irqMask
+		if low == 0 {
+			m.irqPending = true
+		}
+		m.irqCounter = (m.irqCounter &^ m.irqMask) | ((low - 1) & m.irqMask)
+	}
+	if m.irqPending {
+		m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
+	}
+}
+
+func (m *MapperJaleco) acknowledgeIrq() {
+	m.irqPending = false
+	m.cart.nes.CPU().Clear(cpu.CpuIntIRQ)
+}
+
+func writeNibble(reg uint8, addr uint16, val uint8) uint8 {
+	if (addr & 1) == 0 {
+		return (reg & 0xF0) | (val & 0xF)
+	}
+	return (reg & 0x0F) | (val&0xF)<<4
+}
+
+// $8000-$8001: PRG reg 0, 8 KB at $8000
+// $8002-$8003: PRG reg 1, 8 KB at $A000
+// $9000-$9001: PRG reg 2, 8 KB at $C000
+// $9002:       [.... ..WE] PRG RAM write enable and enable
+// $A000-$D003: CHR regs 0-7, 1 KB at PPU $0000-$1FFF, two per $1000
+// $E000-$E003: IRQ reload value, lowest nibble first
+// $F000:       IRQ reset, copies the reload value and acknowledges
+// $F001:       [.... SSSE] IRQ enable and counter size
+//                    |||+- Enable
+//                    +++-- Size (1xx: 4 bits; 01x: 8 bits; 001: 12 bits; 000: 16 bits),
+//                          acknowledges the IRQ
+// $F002:       [.... ..MM] Mirroring (0: horizontal; 1: vertical;
+//                                     2: one-screen lower; 3: one-screen upper)
+func (m *MapperJaleco) writeRegister(addr uint16, val uint8) {
+	reg := addr & 0x3
+	switch addr & 0xF000 {
+	case 0x8000:
+		m.prgRegs[reg>>1] = writeNibble(m.prgRegs[reg>>1], reg, val)
+	case 0x9000:
+		if reg < 2 {
+			m.prgRegs[2] = writeNibble(m.prgRegs[2], reg, val)
+		} else if reg == 2 {
+			m.ramEnable = (val & 0x1) != 0
+			m.ramWrite = (val & 0x2) != 0
+		}
+	case 0xA000, 0xB000, 0xC000, 0xD000:
+		chr := (addr-0xA000)>>12<<1 | reg>>1
+		m.chrRegs[chr] = writeNibble(m.chrRegs[chr], reg, val)
+	case 0xE000:
+		m.irqReload[reg] = val & 0xF
+	case 0xF000:
+		m.writeIrqControl(reg, val)
+	}
+}
+
+func (m *MapperJaleco) writeIrqControl(reg uint16, val uint8) {
+	switch reg {
+	case 0:
+		m.irqCounter = uint16(m.irqReload[0]) | uint16(m.irqReload[1])<<4 |
+			uint16(m.irqReload[2])<<8 | uint16(m.irqReload[3])<<12
+		m.acknowledgeIrq()
+	case 1:
+		m.irqEnable = (val & 0x1) != 0
+		switch {
+		case (val & 0x8) != 0:
+			m.irqMask = 0x000F
+		case (val & 0x4) != 0:
+			m.irqMask = 0x00FF
+		case (val & 0x2) != 0:
+			m.irqMask = 0x0FFF
+		default:
+			m.irqMask = 0xFFFF
+		}
+		m.acknowledgeIrq()
+	case 2:
+		switch val & 0x3 {
+		case 0:
+			m.cart.SetMirroring(common.HorizontalMirroring)
+		case 1:
+			m.cart.SetMirroring(common.VerticalMirroring)
+		case 2:
+			m.cart.SetMirroring(common.SingleScreenMirroring)
+		case 3:
+			m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+		}
+	}
+}
+
+func (m *MapperJaleco) prgAddr(addr uint16) uint32 {
+	banks := uint32(m.cart.prgRom.Size() / 0x2000)
+	bank := banks - 1
+	if slot := (addr - 0x8000) / 0x2000; slot < 3 {
+		bank = uint32(m.prgRegs[slot]) % banks
+	}
+	return bank*0x2000 + uint32(addr%0x2000)
+}
+func (m *MapperJaleco) chrAddr(addr uint16) uint32 {
+	bank := uint32(m.chrRegs[addr/0x400])
+	return (bank*0x400 + uint32(addr%0x400)) % uint32(m.cart.chr.Size())
+}
+
+// PPU $0000-$1FFF: Eight 1 KB switchable CHR banks
+// CPU $6000-$7FFF: 8 KB PRG RAM, optionally battery backed
+// CPU $8000-$DFFF: Three 8 KB switchable PRG ROM banks
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+func (m *MapperJaleco) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() == 0 || !m.ramEnable {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+	case addr >= 0x8000:
+		return m.cart.prgRom.Read8w(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperJaleco) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 && m.ramEnable && m.ramWrite {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
+	case addr >= 0x8000:
+		m.writeRegister(addr, val)
+	}
+}
+
+func (m *MapperJaleco) mapperRegisters() []MapperRegister {
+	regs := append(registerFile("prgRegs", m.prgRegs[:]), registerFile("chrRegs", m.chrRegs[:])...)
+	regs = append(regs, boolRegister("ramEnable", m.ramEnable), boolRegister("ramWrite", m.ramWrite))
+	return append(append(regs, registerFile("irqReload", m.irqReload[:])...),
+		MapperRegister{"irqCounter", int(m.irqCounter)},
+		MapperRegister{"irqMask", int(m.irqMask)},
+		boolRegister("irqEnable", m.irqEnable),
+		boolRegister("irqPending", m.irqPending),
+	)
+}
+
+func (m *MapperJaleco) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		m.prgRegs, m.chrRegs, m.ramEnable, m.ramWrite,
+		m.irqReload, m.irqCounter, m.irqMask, m.irqEnable, m.irqPending,
+	)
+}
+func (m *MapperJaleco) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.prgRegs, &m.chrRegs, &m.ramEnable, &m.ramWrite,
+		&m.irqReload, &m.irqCounter, &m.irqMask, &m.irqEnable, &m.irqPending,
+	)
+}
@@ -1,11 +1,21 @@
 package mappers
 
 import (
-	"log"
-
 	"github.com/tiagolobocastro/gones/lib/common"
 )
 
+// Board variants using the CHR bank registers as extra address lines, found
+// through the NES 2.0 submapper or the ROM/RAM sizes
+// https://wiki.nesdev.com/w/index.php/SxROM
+// SEROM/SHROM (submapper 5, 32 KB PRG): no PRG banking
+// SUROM (512 KB PRG): CHR bank 0 bit 4 selects the 256 KB PRG half
+// SOROM (16 KB PRG RAM): CHR bank 0 bit 3 selects the 8 KB PRG RAM bank
+// SXROM (32 KB PRG RAM): CHR bank 0 bits 2-3 select the 8 KB PRG RAM bank
+const (
+	mmc1OuterPrgSize = 0x40000
+	mmc1PrgRamBank   = 0x2000
+)
+
 type MapperMMC1 struct {
 	cart *Cartridge
 
@@ -22,14 +32,31 @@ type MapperMMC1 struct {
 	prgBankMode uint8
 	chrBankMode uint8
 
-	prgBanks [2]uint32
-	chrBanks [2]uint16
+	prgBanks   [2]uint32
+	chrBanks   [2]uint32
+	prgRamBank uint16
+
+	// cpu cycles, writes to the serial port on consecutive cycles are
+	// ignored but for the first one, eg: the double write of RMW instructions
+	cycles     uint64
+	lastWrite  uint64
+	hasWritten bool
 }
 
 func (m *MapperMMC1) Tick() {}
 
+func (m *MapperMMC1) CpuTick() {
+	m.cycles++
+}
+
 func (m *MapperMMC1) Init() {
 	m.writeInner(0x8000, 0x1F)
+	// keep the header mirroring until the game writes the control register
+	m.cart.SetMirroring(common.NameTableMirroring(m.cart.config.mirror))
+}
+
+func (m *MapperMMC1) fixedPrg() bool {
+	return m.cart.config.submapper == 5 || m.cart.prgRom.Size() <= 0x8000
 }
 
 // 7  bit  0
@@ -40,9 +67,18 @@ func (m *MapperMMC1) Init() {
 // +--------- 1: Reset shift register and write Control with (Control OR $0C),
 //              locking PRG ROM at $C000-$FFFF to the last bank.
 func (m *MapperMMC1) writeLoad(addr uint16, val uint8) {
+	consecutive := m.hasWritten && m.cycles-m.lastWrite <= 1
+	m.lastWrite = m.cycles
+	m.hasWritten = true
+	if consecutive {
+		return
+	}
+
 	if (val & 0x80) != 0 {
 		m.shift = 0x0
 		m.counter = 0
+		m.writeControl(m.control | 0x0C)
+		m.updateAllBanks()
 	} else {
 		m.shift = m.shift | (val&0x1)<<m.counter
 		m.counter++
@@ -83,12 +119,13 @@ func (m *MapperMMC1) writeInner(addr uint16, val uint8) {
 // |                         3: fix last bank at $C000 and switch 16 KB bank at $8000)
 // +----- CHR ROM bank mode (0: switch 8 KB at a time; 1: switch two separate 4 KB banks)
 func (m *MapperMMC1) writeControl(val uint8) {
+	m.control = val
 	m.mirror = val & 0x3
 	switch m.mirror {
 	case 0:
 		m.cart.SetMirroring(common.SingleScreenMirroring)
 	case 1:
-		m.cart.SetMirroring(common.SingleScreenMirroring)
+		m.cart.SetMirroring(common.SingleScreenMirroringUpper)
 	case 2:
 		m.cart.SetMirroring(common.VerticalMirroring)
 	case 3:
@@ -101,6 +138,7 @@ func (m *MapperMMC1) updateAllBanks() {
 	m.updateCHRBank0()
 	m.updateCHRBank1()
 	m.updatePRGBank()
+	m.updatePRGRamBank()
 }
 
 // CHR bank 0 (internal, $A000-$BFFF)
@@ -110,6 +148,15 @@ func (m *MapperMMC1) updateAllBanks() {
 // CCCCC
 // |||||
 // +++++- Select 4 KB or 8 KB CHR bank at PPU $0000 (low bit ignored in 8 KB mode)
+//
+// SxROM boards with 8 KB of CHR RAM use the upper bits instead:
+// 4bit0
+// -----
+// PSSxC
+// |||||
+// ||| +- Select 4 KB CHR RAM bank at PPU $0000 (ignored in 8 KB mode)
+// |++--- Select 8 KB PRG RAM bank (SXROM, only bit 3 on SOROM)
+// +----- Select 256 KB PRG ROM bank (SUROM and SXROM)
 func (m *MapperMMC1) writeCHRBank0(val uint8) {
 	m.chrBank0 = val & 0x1f
 }
@@ -117,12 +164,12 @@ func (m *MapperMMC1) updateCHRBank0() {
 	switch m.chrBankMode {
 	case 0:
 		// 8 KB
-		bank := (uint16(m.chrBank0) >> 1) * 0x2000
+		bank := (uint32(m.chrBank0) >> 1) * 0x2000
 		m.chrBanks[0] = bank
 		m.chrBanks[1] = bank + 0x1000
 	case 1:
 		// 4 KB
-		bank := uint16(m.chrBank0) * 0x1000
+		bank := uint32(m.chrBank0) * 0x1000
 		m.chrBanks[0] = bank
 	}
 }
@@ -144,7 +191,7 @@ func (m *MapperMMC1) updateCHRBank1() {
 		// noop
 	case 1:
 		// 4 KB
-		bank := uint16(m.chrBank1) * 0x1000
+		bank := uint32(m.chrBank1) * 0x1000
 		m.chrBanks[1] = bank
 	}
 }
@@ -162,23 +209,58 @@ func (m *MapperMMC1) writePRGBank(val uint8) {
 	m.updatePRGBank()
 }
 func (m *MapperMMC1) updatePRGBank() {
+	if m.fixedPrg() {
+		m.prgBanks[0] = 0x0000
+		m.prgBanks[1] = 0x4000
+		return
+	}
+
+	// the fixed banks are the first and last of the selected 256 KB
+	outer := uint32(0)
+	last := uint32(m.cart.prgRom.Size()) - 0x4000
+	if m.cart.prgRom.Size() > mmc1OuterPrgSize {
+		outer = uint32((m.chrBank0>>4)&1) * mmc1OuterPrgSize
+		last = outer + mmc1OuterPrgSize - 0x4000
+	}
+	prgBank := uint32(m.prgBank & 0xF)
+
 	switch m.prgBankMode {
 	case 0, 1:
 		// 32KB mode
-		bankV := uint32(m.prgBank) >> 1
-		bank := 0x8000 * bankV
+		bankV := prgBank >> 1
+		bank := outer + 0x8000*bankV
 
 		m.prgBanks[0] = bank
 		m.prgBanks[1] = bank + 0x4000
 	case 2:
 		//  2: fix first bank at $8000 and switch 16 KB bank at $C000;
-		m.prgBanks[0] = 0x0000
-		m.prgBanks[1] = 0x4000 * uint32(m.prgBank)
+		m.prgBanks[0] = outer
+		m.prgBanks[1] = outer + 0x4000*prgBank
 	case 3:
 		// 3: fix last bank at $C000 and switch 16 KB bank at $8000)
-		m.prgBanks[0] = 0x4000 * uint32(m.prgBank)
-		m.prgBanks[1] = uint32(m.cart.prgRom.Size()) - 0x4000
+		m.prgBanks[0] = outer + 0x4000*prgBank
+		m.prgBanks[1] = last
 	}
+	m.prgBanks[0] %= uint32(m.cart.prgRom.Size())
+	m.prgBanks[1] %= uint32(m.cart.prgRom.Size())
+}
+
+func (m *MapperMMC1) updatePRGRamBank() {
+	switch m.cart.prgRam.Size() {
+	case 0x4000:
+		m.prgRamBank = uint16((m.chrBank0>>3)&1) * mmc1PrgRamBank
+	case 0x8000:
+		m.prgRamBank = uint16((m.chrBank0>>2)&3) * mmc1PrgRamBank
+	default:
+		m.prgRamBank = 0
+	}
+}
+
+func (m *MapperMMC1) prgRamAddr(addr uint16) uint16 {
+	return (m.prgRamBank + addr - 0x6000) % uint16(m.cart.prgRam.Size())
+}
+func (m *MapperMMC1) chrAddr(addr uint16, bank uint32) uint32 {
+	return (uint32(addr) + bank) % uint32(m.cart.chr.Size())
 }
 
 // CPU $6000-$7FFF: 8 KB PRG RAM bank, (optional)
@@ -191,11 +273,14 @@ func (m *MapperMMC1) Read8(addr uint16) uint8 {
 	// PPU - normally mapped by the cartridge to a CHR-ROM or CHR-RAM,
 	// often with a bank switching mechanism.
 	case addr < 0x1000:
-		return m.cart.chr.Read8(addr + m.chrBanks[0])
+		return m.cart.chr.Read8w(m.chrAddr(addr, m.chrBanks[0]))
 	case addr < 0x2000:
-		return m.cart.chr.Read8(addr - 0x1000 + m.chrBanks[1])
+		return m.cart.chr.Read8w(m.chrAddr(addr-0x1000, m.chrBanks[1]))
 	case addr >= 0x6000 && addr < 0x8000:
-		return m.cart.prgRam.Read8(addr - 0x6000)
+		if m.cart.prgRam.Size() == 0 {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8(m.prgRamAddr(addr))
 	case addr >= 0x8000 && addr < 0xC000:
 		offset := uint32(addr - 0x8000)
 		return m.cart.prgRom.Read8w(m.prgBanks[0] + offset)
@@ -203,22 +288,28 @@ func (m *MapperMMC1) Read8(addr uint16) uint8 {
 		offset := uint32(addr - 0xC000)
 		return m.cart.prgRom.Read8w(m.prgBanks[1] + offset)
 	default:
-		log.Panicf("read not implemented for 0x%04x!", addr)
-		return 0
+		return m.cart.openBus()
 	}
 }
 func (m *MapperMMC1) Write8(addr uint16, val uint8) {
 	switch {
 	case addr < 0x1000:
-		m.cart.chr.Write8(addr+m.chrBanks[0], val)
+		m.cart.chr.Write8w(m.chrAddr(addr, m.chrBanks[0]), val)
 	case addr < 0x2000:
-		m.cart.chr.Write8(addr-0x1000+m.chrBanks[1], val)
+		m.cart.chr.Write8w(m.chrAddr(addr-0x1000, m.chrBanks[1]), val)
 	case addr >= 0x6000 && addr < 0x8000:
-		m.cart.prgRam.Write8(addr-0x6000, val)
+		if m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8(m.prgRamAddr(addr), val)
+		}
 	case addr >= 0x8000:
 		m.writeLoad(addr, val)
-	default:
-		log.Panicf("write not implemented for 0x%04x!", addr)
+	}
+}
+
+func (m *MapperMMC1) mapperRegisters() []MapperRegister {
+	return []MapperRegister{
+		{"shift", int(m.shift)}, {"counter", int(m.counter)}, {"control", int(m.control)},
+		{"chrBank0", int(m.chrBank0)}, {"chrBank1", int(m.chrBank1)}, {"prgBank", int(m.prgBank)},
 	}
 }
 
@@ -226,11 +317,13 @@ func (m *MapperMMC1) Serialise(s common.Serialiser) error {
 	return s.Serialise(
 		m.shift, m.control, m.chrBank0, m.chrBank1, m.prgBank, m.mirror,
 		m.counter, m.prgBankMode, m.chrBankMode, m.prgBanks, m.chrBanks,
+		m.prgRamBank, m.cycles, m.lastWrite, m.hasWritten,
 	)
 }
 func (m *MapperMMC1) DeSerialise(s common.Serialiser) error {
 	return s.DeSerialise(
 		&m.shift, &m.control, &m.chrBank0, &m.chrBank1, &m.prgBank, &m.mirror,
 		&m.counter, &m.prgBankMode, &m.chrBankMode, &m.prgBanks, &m.chrBanks,
+		&m.prgRamBank, &m.cycles, &m.lastWrite, &m.hasWritten,
 	)
 }
@@ -0,0 +1,90 @@
+package mappers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// an SNROM like board, 128 KB of PRG-ROM, 8 KB of CHR and 8 KB of PRG-RAM
+func newMMC1() *MapperMMC1 {
+	c := &Cartridge{prgRom: new(common.Rom), prgRam: new(common.Ram), chr: new(common.Rom)}
+	c.prgRom.Init(0x20000, false)
+	c.prgRam.Init(0x2000)
+	c.chr.Init(0x2000, true)
+	m := &MapperMMC1{cart: c}
+	m.Init()
+	return m
+}
+
+// the writes to the serial port on consecutive cpu cycles are ignored but for
+// the first one, eg: Bill & Ted's reset through an INC on $8000 whose second
+// write must not shift a bit in
+func TestMMC1ConsecutiveWrites(t *testing.T) {
+	tests := []struct {
+		name string
+		// the cpu cycles of the writes, and the values written
+		cycles []uint64
+		vals   []uint8
+		// the bits shifted in
+		counter uint8
+		shift   uint8
+	}{
+		{name: "spaced", cycles: []uint64{10, 12, 14}, vals: []uint8{1, 0, 1}, counter: 3, shift: 0x5},
+		{name: "rmw", cycles: []uint64{10, 11}, vals: []uint8{1, 0}, counter: 1, shift: 0x1},
+		{name: "rmw then spaced", cycles: []uint64{10, 11, 13}, vals: []uint8{0, 1, 1}, counter: 2, shift: 0x2},
+		// each write restarts the window
+		{name: "three in a row", cycles: []uint64{10, 11, 12}, vals: []uint8{1, 1, 1}, counter: 1, shift: 0x1},
+		// a reset on consecutive cycles is ignored too
+		{name: "rmw reset", cycles: []uint64{10, 12, 13}, vals: []uint8{1, 1, 0x80}, counter: 2, shift: 0x3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newMMC1()
+			for i, cycle := range test.cycles {
+				m.cycles = cycle
+				m.Write8(0xE000, test.vals[i])
+			}
+			if m.counter != test.counter || m.shift != test.shift {
+				t.Errorf("shifted %d bits, $%02X, expected %d bits, $%02X", m.counter, m.shift, test.counter, test.shift)
+			}
+		})
+	}
+
+	// the fifth bit which counts loads the PRG bank
+	m := newMMC1()
+	for i, val := range []uint8{1, 1, 0, 0, 0} {
+		m.cycles = uint64(20 + 3*i)
+		m.Write8(0xE000, val)
+		// the RMW double write of the same instruction
+		m.cycles++
+		m.Write8(0xE000, 0x80)
+	}
+	if m.prgBank != 3 || m.counter != 0 {
+		t.Errorf("PRG bank %d with %d bits shifted, expected bank 3", m.prgBank, m.counter)
+	}
+}
+
+// the registers leave out the banks worked out from them and the cycles of
+// the writes
+func TestMMC1Registers(t *testing.T) {
+	m := newMMC1()
+	m.cart.Mapper = m
+	for i, val := range []uint8{1, 1, 0, 0, 0} {
+		m.cycles = uint64(20 + 2*i)
+		m.Write8(0xE000, val)
+	}
+
+	var names []string
+	for _, r := range m.cart.MapperRegisters() {
+		names = append(names, r.Name)
+		if r.Name == "prgBank" && r.Value != 3 {
+			t.Errorf("PRG bank %d, expected 3", r.Value)
+		}
+	}
+	if got := strings.Join(names, " "); got != "shift counter control chrBank0 chrBank1 prgBank" {
+		t.Errorf("registers %s", got)
+	}
+}
@@ -1,12 +1,16 @@
 package mappers
 
 import (
-	"fmt"
 	"github.com/tiagolobocastro/gones/lib/common"
 )
 
+// MMC2 (mapper 9) and MMC4 (mapper 10), the MMC4 has the same CHR latches
+// but 16 KB PRG banks and PRG RAM
+// https://wiki.nesdev.com/w/index.php/MMC2
+// https://wiki.nesdev.com/w/index.php/MMC4
 type MapperMMC2 struct {
 	cart *Cartridge
+	mmc4 bool
 
 	chrBankD0 uint8
 	chrBankE0 uint8
@@ -76,11 +80,19 @@ func (m *MapperMMC2) updateCHRBanks() {
 // xxxx PPPP
 //      ||||
 //      ++++- Select 8 KB PRG ROM bank for CPU $8000-$9FFF
+//            or a 16 KB bank for CPU $8000-$BFFF on the MMC4
 func (m *MapperMMC2) writePRGBank(val uint8) {
 	m.prgBank = val & 0xF
 }
 func (m *MapperMMC2) updatePRGBank() {
-	m.prgBanks[0] = 0x2000 * uint32(m.prgBank)
+	size := m.prgBankSize()
+	m.prgBanks[0] = (size * uint32(m.prgBank)) % uint32(m.cart.prgRom.Size())
+}
+func (m *MapperMMC2) prgBankSize() uint32 {
+	if m.mmc4 {
+		return 0x4000
+	}
+	return 0x2000
 }
 
 // CHR ROM $FD/0000 bank select ($B000-$BFFF)
@@ -93,7 +105,6 @@ func (m *MapperMMC2) updatePRGBank() {
 //            used when latch 0 = $FD
 func (m *MapperMMC2) writeCHRBankD0(val uint8) {
 	m.chrBankD0 = val & 0x1f
-	m.latch[0] = 0
 }
 
 // CHR ROM $FE/0000 bank select ($C000-$CFFF)
@@ -106,7 +117,6 @@ func (m *MapperMMC2) writeCHRBankD0(val uint8) {
 //            used when latch 0 = $FE
 func (m *MapperMMC2) writeCHRBankE0(val uint8) {
 	m.chrBankE0 = val & 0x1f
-	m.latch[0] = 0
 }
 
 // CHR ROM $FD/1000 bank select ($D000-$DFFF)
@@ -154,49 +164,70 @@ func (m *MapperMMC2) writeMirroring(val uint8) {
 	}
 }
 
+// The MMC2 latch 0 is only triggered by $0FD8 and $0FE8, the MMC4 one
+// (like latch 1) by the whole $0FD8-$0FDF and $0FE8-$0FEF ranges
+func (m *MapperMMC2) updateLatch0(addr uint16) {
+	if m.mmc4 {
+		addr &= 0xFF8
+	}
+	if addr == 0xFD8 {
+		m.latch[0] = 0xFD
+	} else if addr == 0xFE8 {
+		m.latch[0] = 0xFE
+	}
+}
+
 // PPU $0000-$0FFF: Two 4 KB switchable CHR ROM banks
 // PPU $1000-$1FFF: Two 4 KB switchable CHR ROM banks
 // CPU $6000-$7FFF: 8 KB PRG RAM bank (PlayChoice version only; contains a 6264 and 74139)
 // CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
 // CPU $A000-$FFFF: Three 8 KB PRG ROM banks, fixed to the last three banks
+//
+// MMC4:
+// CPU $6000-$7FFF: 8 KB PRG RAM bank
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
 func (m *MapperMMC2) Read8(addr uint16) uint8 {
+	v := m.Peek8(addr)
+	switch {
+	case addr < 0x1000:
+		m.updateLatch0(addr)
+	case addr >= 0x1FD8 && addr <= 0x1FDF:
+		m.latch[1] = 0xFD
+	case addr >= 0x1FE8 && addr <= 0x1FEF:
+		m.latch[1] = 0xFE
+	}
+	return v
+}
+
+// Peek8 reads without flipping the CHR latches
+func (m *MapperMMC2) Peek8(addr uint16) uint8 {
 	switch {
 	case addr < 0x1000:
-		v := uint8(0)
 		if m.latch[0] == 0xFD {
-			v = m.cart.chr.Read8w(uint32(addr) + m.chrBanks[0])
-		} else {
-			v = m.cart.chr.Read8w(uint32(addr) + m.chrBanks[1])
-		}
-		if addr == 0xFD8 {
-			m.latch[0] = 0xFD
-		} else if addr == 0xFE8 {
-			m.latch[0] = 0xFE
+			return m.cart.chr.Read8w(uint32(addr) + m.chrBanks[0])
 		}
-		return v
+		return m.cart.chr.Read8w(uint32(addr) + m.chrBanks[1])
 	case addr < 0x2000:
-		v := uint8(0)
 		if m.latch[1] == 0xFD {
-			v = m.cart.chr.Read8w(uint32(addr-0x1000) + m.chrBanks[2])
-		} else {
-			v = m.cart.chr.Read8w(uint32(addr-0x1000) + m.chrBanks[3])
-		}
-		if addr >= 0x1FD8 && addr <= 0x1FDF {
-			m.latch[1] = 0xFD
-		} else if addr >= 0x1FE8 && addr <= 0x1FEF {
-			m.latch[1] = 0xFE
+			return m.cart.chr.Read8w(uint32(addr-0x1000) + m.chrBanks[2])
 		}
-		return v
+		return m.cart.chr.Read8w(uint32(addr-0x1000) + m.chrBanks[3])
 
 	case addr >= 0x6000 && addr < 0x8000:
-		return m.cart.prgRam.Read8(addr - 0x6000)
-	case addr >= 0x8000 && addr < 0xA000:
+		if m.cart.prgRam.Size() == 0 {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+	case addr >= 0x8000 && uint32(addr-0x8000) < m.prgBankSize():
 		return m.cart.prgRom.Read8w(uint32(addr-0x8000) + m.prgBanks[0])
 	case addr >= 0xA000:
-		offset := uint32(addr - 0xA000)
-		return m.cart.prgRom.Read8w(offset + uint32(m.cart.prgRom.Size()) - 0x2000*3)
+		// the fixed banks fill the rest of the address space up to $FFFF
+		offset := uint32(addr) - 0x8000 - m.prgBankSize()
+		fixed := 0x8000 - m.prgBankSize()
+		return m.cart.prgRom.Read8w(offset + uint32(m.cart.prgRom.Size()) - fixed)
 	default:
-		panic(fmt.Sprintf("read not implemented for 0x%04x!", addr))
+		return m.cart.openBus()
 	}
 }
 func (m *MapperMMC2) Write8(addr uint16, val uint8) {
@@ -215,14 +246,24 @@ func (m *MapperMMC2) Write8(addr uint16, val uint8) {
 		}
 
 	case addr >= 0x6000 && addr < 0x8000:
-		m.cart.prgRam.Write8(addr-0x6000, val)
+		if m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
 	case addr >= 0xA000:
 		m.writeInner(addr, val)
-	default:
-		panic(fmt.Sprintf("write not implemented for 0x%04x!", addr))
 	}
 }
 
+func (m *MapperMMC2) mapperRegisters() []MapperRegister {
+	regs := []MapperRegister{
+		{"prgBank", int(m.prgBank)},
+		{"chrBankD0", int(m.chrBankD0)}, {"chrBankE0", int(m.chrBankE0)},
+		{"chrBankD1", int(m.chrBankD1)}, {"chrBankE1", int(m.chrBankE1)},
+		{"mirror", int(m.mirror)},
+	}
+	return append(regs, registerFile("latch", m.latch[:])...)
+}
+
 func (m *MapperMMC2) Serialise(s common.Serialiser) error {
 	return s.Serialise(
 		m.chrBankD0, m.chrBankE0, m.chrBankD1, m.chrBankE1, m.prgBank, m.mirror,
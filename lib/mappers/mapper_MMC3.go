@@ -1,14 +1,27 @@
 package mappers
 
 import (
-	"fmt"
-
 	"github.com/tiagolobocastro/gones/lib/common"
 	"github.com/tiagolobocastro/gones/lib/cpu"
 )
 
+// MMC3 (mapper 4) and its variants, selected by the NES 2.0 submapper
+// https://wiki.nesdev.com/w/index.php/MMC3
+// submapper 0: MMC3C (Sharp), the IRQ fires whenever the counter is 0
+// submapper 1: MMC6, 1 KB of internal PRG RAM with per half protection
+// submapper 4: MMC3A, the IRQ only fires when the counter decrements to 0
+//              or is explicitly reloaded with 0
+//
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_118
+// 118: TxSROM, CHR bank bit 7 drives CIRAM A10 instead of the mirroring register
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_119
+// 119: TQROM, CHR bank bit 6 selects 8 KB of CHR RAM instead of the CHR ROM
 type MapperMMC3 struct {
-	cart *Cartridge
+	cart   *Cartridge
+	mapper uint16
+
+	// TQROM only
+	chrRam common.Ram
 
 	bankMode      uint8
 	prgRamProtect uint8
@@ -26,6 +39,8 @@ type MapperMMC3 struct {
 
 func (m *MapperMMC3) Tick() {
 	if m.cart.nes.PPU().A12OutputHigh() {
+		// the old MMC3A does not fire when a 0 counter is reloaded with 0
+		reloaded := m.irqReload || m.irqCounter != 0
 		if m.irqCounter == 0 || m.irqReload {
 			m.irqCounter = m.irqLatch
 			m.irqReload = false
@@ -33,7 +48,7 @@ func (m *MapperMMC3) Tick() {
 			m.irqCounter--
 		}
 
-		if m.irqCounter == 0 && !m.irqDisable {
+		if m.irqCounter == 0 && !m.irqDisable && (reloaded || !m.oldIrq()) {
 			m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
 		}
 	}
@@ -41,9 +56,19 @@ func (m *MapperMMC3) Tick() {
 
 func (m *MapperMMC3) Init() {
 	m.mirror = m.cart.config.mirror
+	if m.mapper == 119 {
+		m.chrRam.Init(0x2000)
+	}
 	m.updateAllBanks()
 }
 
+func (m *MapperMMC3) oldIrq() bool {
+	return m.mapper == 4 && m.cart.config.submapper == 4
+}
+func (m *MapperMMC3) mmc6() bool {
+	return m.mapper == 4 && m.cart.config.submapper == 1
+}
+
 // The MMC3 has 4 pairs of registers at $8000-$9FFF, $A000-$BFFF, $C000-$DFFF, and $E000-$FFFF
 // even addresses ($8000, $8002, etc.) select the low register and
 // odd addresses ($8001, $8003, etc.) select the high register in each pair.
@@ -92,7 +117,7 @@ func (m *MapperMMC3) updateAllBanks() {
 // |||          101: R5: Select 1 KB CHR bank at PPU $1C00-$1FFF (or $0C00-$0FFF)
 // |||          110: R6: Select 8 KB PRG ROM bank at $8000-$9FFF (or $C000-$DFFF)
 // |||          111: R7: Select 8 KB PRG ROM bank at $A000-$BFFF
-// ||+------- Nothing on the MMC3, see MMC6
+// ||+------- Nothing on the MMC3, MMC6 PRG RAM enable
 // |+-------- PRG ROM bank mode (0: $8000-$9FFF swappable,
 // |                                $C000-$DFFF fixed to second-last bank;
 // |                             1: $C000-$DFFF swappable,
@@ -188,8 +213,8 @@ func (m *MapperMMC3) updatePgrBank(register int) {
 //         |
 //         +- Select nametable mirroring (0: vertical; 1: horizontal)
 func (m *MapperMMC3) writeMirroring(val uint8) {
-	// QuadScreen only
-	if (m.cart.config.mirror & 0x2) != 0 {
+	// QuadScreen only, and TxSROM ignores it
+	if (m.cart.config.mirror&0x2) != 0 || m.mapper == 118 {
 		return
 	}
 
@@ -211,10 +236,53 @@ func (m *MapperMMC3) writeMirroring(val uint8) {
 // ||++------ Nothing on the MMC3, see MMC6
 // |+-------- Write protection (0: allow writes; 1: deny writes)
 // +--------- PRG RAM chip enable (0: disable; 1: enable)
+//
+// MMC6:
+// 7  bit  0
+// ---- ----
+// HhLl xxxx
+// ||||
+// |||+------ Enable writing to RAM at $7000-$71FF
+// ||+------- Enable reading RAM at $7000-$71FF
+// |+-------- Enable writing to RAM at $7200-$73FF
+// +--------- Enable reading RAM at $7200-$73FF
+// Writes are ignored unless the RAM is enabled through $8000 bit 5
 func (m *MapperMMC3) writePrgRamProtect(val uint8) {
+	if m.mmc6() && !m.mmc6RamEnabled() {
+		return
+	}
 	m.prgRamProtect = val
 }
 
+func (m *MapperMMC3) mmc6RamEnabled() bool {
+	return (m.bankMode & 0x20) != 0
+}
+
+// MMC6 CPU $7000-$7FFF: 1 KB PRG RAM, mirrored
+// CPU $6000-$6FFF is open bus
+func (m *MapperMMC3) readMmc6Ram(addr uint16) uint8 {
+	readLow := (m.prgRamProtect & 0x20) != 0
+	readHigh := (m.prgRamProtect & 0x80) != 0
+	if addr < 0x7000 || !m.mmc6RamEnabled() || (!readLow && !readHigh) || m.cart.prgRam.Size() == 0 {
+		return m.cart.openBus()
+	}
+	addr &= 0x3FF
+	// with only one half readable the other half reads as 0
+	if (addr < 0x200 && !readLow) || (addr >= 0x200 && !readHigh) {
+		return 0
+	}
+	return m.cart.prgRam.Read8(addr % uint16(m.cart.prgRam.Size()))
+}
+func (m *MapperMMC3) writeMmc6Ram(addr uint16, val uint8) {
+	if addr < 0x7000 || !m.mmc6RamEnabled() || m.cart.prgRam.Size() == 0 {
+		return
+	}
+	addr &= 0x3FF
+	if (addr < 0x200 && (m.prgRamProtect&0x10) != 0) || (addr >= 0x200 && (m.prgRamProtect&0x40) != 0) {
+		m.cart.prgRam.Write8(addr%uint16(m.cart.prgRam.Size()), val)
+	}
+}
+
 // IRQ latch ($C000-$DFFE, even)
 //
 //7  bit  0
@@ -248,6 +316,37 @@ func (m *MapperMMC3) writeIrqEnable(val uint8) {
 	m.irqDisable = false
 }
 
+// TQROM CHR RAM is selected by bit 6 of the 1 KB bank
+func (m *MapperMMC3) chrRamBank(bank uint16) bool {
+	return m.mapper == 119 && (m.chrBanks[bank]/0x400)&0x40 != 0
+}
+func (m *MapperMMC3) chrAddr(bank uint16, offset uint32) uint32 {
+	if m.chrRamBank(bank) {
+		return ((m.chrBanks[bank]/0x400)&0x7)*0x400 + offset
+	}
+	return (m.chrBanks[bank] + offset) % uint32(m.cart.chr.Size())
+}
+
+// TxSROM nametables follow the CHR bank mapped at the same 1 KB slot of the
+// pattern tables, bit 7 of the bank selects the CIRAM page
+func (m *MapperMMC3) tablePage(addr uint16) uint16 {
+	slot := (addr - 0x2000) % 0x1000 / 0x400
+	return uint16(m.chrBanks[slot]/0x400>>7) & 1
+}
+func (m *MapperMMC3) ReadTable(addr uint16) uint8 {
+	if m.mapper != 118 {
+		return m.cart.Tables.Read8(addr)
+	}
+	return m.cart.Tables.ReadPage(m.tablePage(addr), addr)
+}
+func (m *MapperMMC3) WriteTable(addr uint16, val uint8) {
+	if m.mapper != 118 {
+		m.cart.Tables.Write8(addr, val)
+		return
+	}
+	m.cart.Tables.WritePage(m.tablePage(addr), addr, val)
+}
+
 // CPU $6000-$7FFF: 8 KB PRG RAM bank (optional)
 // CPU $8000-$9FFF (or $C000-$DFFF): 8 KB switchable PRG ROM bank
 // CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
@@ -264,18 +363,27 @@ func (m *MapperMMC3) Read8(addr uint16) uint8 {
 	case addr < 0x2000:
 		bank := addr / 0x400
 		offset := uint32(addr) % 0x400
-		return m.cart.chr.Read8w(m.chrBanks[bank] + offset)
+		if m.chrRamBank(bank) {
+			return m.chrRam.Read8(uint16(m.chrAddr(bank, offset)))
+		}
+		return m.cart.chr.Read8w(m.chrAddr(bank, offset))
 
 	case addr >= 0x6000 && addr < 0x8000:
-		return m.cart.prgRam.Read8(addr - 0x6000)
+		if m.mmc6() {
+			return m.readMmc6Ram(addr)
+		}
+		if m.cart.prgRam.Size() == 0 {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
 
 	case addr >= 0x8000:
 		bank := (addr - 0x8000) / 0x2000
 		offset := uint32(addr-0x8000) % 0x2000
-		return m.cart.prgRom.Read8w(m.prgBanks[bank] + offset)
+		return m.cart.prgRom.Read8w((m.prgBanks[bank] + offset) % uint32(m.cart.prgRom.Size()))
 
 	default:
-		panic(fmt.Sprintf("read not implemented for 0x%04x!", addr))
+		return m.cart.openBus()
 	}
 }
 func (m *MapperMMC3) Write8(addr uint16, val uint8) {
@@ -283,27 +391,45 @@ func (m *MapperMMC3) Write8(addr uint16, val uint8) {
 	case addr < 0x2000:
 		bank := addr / 0x400
 		offset := uint32(addr) % 0x400
-		m.cart.chr.Write8w(m.chrBanks[bank]+offset, val)
+		if m.chrRamBank(bank) {
+			m.chrRam.Write8(uint16(m.chrAddr(bank, offset)), val)
+		} else {
+			m.cart.chr.Write8w(m.chrAddr(bank, offset), val)
+		}
 
 	case addr >= 0x6000 && addr < 0x8000:
-		m.cart.prgRam.Write8(addr-0x6000, val)
+		if m.mmc6() {
+			m.writeMmc6Ram(addr, val)
+		} else if m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
 
 	case addr >= 0x8000:
 		m.writeInner(addr, val)
-	default:
-		panic(fmt.Sprintf("write not implemented for 0x%04x!", addr))
 	}
 }
 
+func (m *MapperMMC3) mapperRegisters() []MapperRegister {
+	return append(registerFile("registers", m.registers[:]),
+		MapperRegister{"bankMode", int(m.bankMode)},
+		MapperRegister{"mirror", int(m.mirror)},
+		MapperRegister{"prgRamProtect", int(m.prgRamProtect)},
+		MapperRegister{"irqLatch", int(m.irqLatch)},
+		MapperRegister{"irqCounter", int(m.irqCounter)},
+		boolRegister("irqReload", m.irqReload),
+		boolRegister("irqDisable", m.irqDisable),
+	)
+}
+
 func (m *MapperMMC3) Serialise(s common.Serialiser) error {
 	return s.Serialise(
 		m.bankMode, m.prgRamProtect, m.irqLatch, m.irqReload, m.irqDisable,
-		m.irqCounter, m.mirror, m.registers, m.prgBanks, m.chrBanks,
+		m.irqCounter, m.mirror, m.registers, m.prgBanks, m.chrBanks, &m.chrRam,
 	)
 }
 func (m *MapperMMC3) DeSerialise(s common.Serialiser) error {
 	return s.DeSerialise(
 		&m.bankMode, &m.prgRamProtect, &m.irqLatch, &m.irqReload, &m.irqDisable,
-		&m.irqCounter, &m.mirror, &m.registers, &m.prgBanks, &m.chrBanks,
+		&m.irqCounter, &m.mirror, &m.registers, &m.prgBanks, &m.chrBanks, &m.chrRam,
 	)
 }
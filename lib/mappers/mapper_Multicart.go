@@ -0,0 +1,364 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// Discrete logic multicart and pirate boards, mostly latching the bank
+// selection from the address of the write rather than from its value
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_015
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_225
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_226
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_227
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_228
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_233
+//
+// 15:  K-1029/K-1030P, 100-in-1 Contra Function 16
+// 225: 52/64/72-in-1
+// 226: 76-in-1
+// 227: 1200-in-1
+// 228: Active Enterprises, Action 52 and Cheetahmen II
+// 233: 42-in-1, the reset line toggles the outer PRG bank
+type MapperMulticart struct {
+	cart   *Cartridge
+	mapper uint16
+
+	// 16 KB PRG banks at $8000 and $C000, or four 8 KB banks (15 only)
+	prgBanks [4]uint32
+	chrBank  uint32
+	// the CHR RAM of some boards is write protected in their NROM modes
+	chrProtect bool
+
+	// 226 has two registers, the others only one
+	regs [2]uint8
+	// 4 bit RAM at $5800-$5FFF (225) or $4020-$5FFF (228)
+	nibbleRam [4]uint8
+
+	// 233 only
+	outerReset  uint8
+	threeScreen bool
+}
+
+func (m *MapperMulticart) Init() {
+	m.outerReset = 0
+	m.nibbleRam = [4]uint8{}
+	m.clearLatch()
+}
+
+func (m *MapperMulticart) Tick() {}
+
+// The reset line clears the bank latch, returning to the menu, and on 233
+// it advances the outer bank to the next menu
+func (m *MapperMulticart) Reset() {
+	if m.mapper == 233 {
+		m.outerReset ^= 0x20
+	}
+	m.clearLatch()
+}
+
+func (m *MapperMulticart) clearLatch() {
+	m.regs = [2]uint8{}
+	m.chrProtect = false
+	m.threeScreen = false
+	m.write(0x8000, 0)
+}
+
+func (m *MapperMulticart) decodesExpansion() bool {
+	return m.mapper == 225 || m.mapper == 228
+}
+
+func (m *MapperMulticart) setPrg16(low uint32, high uint32) {
+	m.prgBanks = [4]uint32{low * 2, low*2 + 1, high * 2, high*2 + 1}
+}
+func (m *MapperMulticart) setPrg32(bank uint32) {
+	m.setPrg16(bank&^1, bank|1)
+}
+
+func (m *MapperMulticart) setMirroring(horizontal bool) {
+	if horizontal {
+		m.cart.SetMirroring(common.HorizontalMirroring)
+	} else {
+		m.cart.SetMirroring(common.VerticalMirroring)
+	}
+}
+
+func (m *MapperMulticart) write(addr uint16, val uint8) {
+	switch m.mapper {
+	case 15:
+		m.write15(addr, val)
+	case 225:
+		m.write225(addr)
+	case 226:
+		m.write226(addr, val)
+	case 227:
+		m.write227(addr)
+	case 228:
+		m.write228(addr, val)
+	case 233:
+		m.write233(val)
+	}
+}
+
+// Mapper 15 ($8000-$FFFF)
+//
+// A~[1... .... .... ..MM]  D~[pHBB BBBB]
+//                     ||      |||| ||||
+//                     ||      ||++-++++- 16 KB PRG bank B
+//                     ||      |+-------- Mirroring (0: vertical; 1: horizontal)
+//                     ||      +--------- 8 KB half of B in mode 2
+//                     ++---------------- Mode (0: NROM-256, $8000 B and $C000 B|1;
+//                                              1: UNROM, $8000 B and $C000 B|7;
+//                                              2: NROM-64, 8 KB bank B*2+p everywhere;
+//                                              3: NROM-128, B at $8000 and $C000)
+func (m *MapperMulticart) write15(addr uint16, val uint8) {
+	bank := uint32(val & 0x3F)
+	switch addr & 0x3 {
+	case 0:
+		m.setPrg16(bank, bank|1)
+	case 1:
+		m.setPrg16(bank, bank|7)
+	case 2:
+		half := bank*2 + uint32(val>>7)
+		m.prgBanks = [4]uint32{half, half, half, half}
+	case 3:
+		m.setPrg16(bank, bank)
+	}
+	m.chrProtect = addr&0x3 == 0 || addr&0x3 == 3
+	m.setMirroring((val & 0x40) != 0)
+}
+
+// Mapper 225 ($8000-$FFFF)
+//
+// A~[1HMO PPPP PPCC CCCC]
+//     ||| |||| ||++-++++- 8 KB CHR bank
+//     ||| ++++-++-------- 16 KB PRG bank
+//     ||+---------------- PRG mode (0: 32 KB, ignoring the low bit; 1: 16 KB mirrored)
+//     |+----------------- Mirroring (0: vertical; 1: horizontal)
+//     +------------------ High bit of both the PRG and CHR banks
+func (m *MapperMulticart) write225(addr uint16) {
+	high := uint32(addr>>14) & 1
+	bank := uint32(addr>>6)&0x3F | high<<6
+	if (addr & 0x1000) != 0 {
+		m.setPrg16(bank, bank)
+	} else {
+		m.setPrg32(bank)
+	}
+	m.chrBank = uint32(addr)&0x3F | high<<6
+	m.setMirroring((addr & 0x2000) != 0)
+}
+
+// Mapper 226 ($8000-$FFFF, even and odd)
+//
+// $8000: D~[PMOB BBBB]        $8001: D~[.... ..WQ]
+//           |||+-++++- PRG bank bits 0-4      ||
+//           ||+------- PRG mode (0: 32 KB;    |+- PRG bank bit 6
+//           ||                   1: 16 KB)    +-- CHR RAM write protect
+//           |+-------- Mirroring (0: horizontal; 1: vertical)
+//           +--------- PRG bank bit 5
+func (m *MapperMulticart) write226(addr uint16, val uint8) {
+	m.regs[addr&1] = val
+
+	bank := uint32(m.regs[0]&0x1F) | uint32(m.regs[0]>>7)<<5 | uint32(m.regs[1]&1)<<6
+	if (m.regs[0] & 0x20) != 0 {
+		m.setPrg16(bank, bank)
+	} else {
+		m.setPrg32(bank)
+	}
+	m.chrProtect = (m.regs[1] & 0x2) != 0
+	m.setMirroring((m.regs[0] & 0x40) == 0)
+}
+
+// Mapper 227 ($8000-$FFFF)
+//
+// A~[1... ..LP OPPP PPMS]
+//           || |||| ||||
+//           || |||| |||+- PRG size (0: 16 KB; 1: 32 KB)
+//           || |||| ||+-- Mirroring (0: vertical; 1: horizontal)
+//           || |+++-++--- PRG bank bits 0-4
+//           || +--------- PRG mode (0: UNROM like; 1: NROM)
+//           |+----------- PRG bank bit 5
+//           +------------ UNROM mode $C000 bank (0: first of the 128 KB; 1: last)
+func (m *MapperMulticart) write227(addr uint16) {
+	bank := uint32(addr>>2)&0x1F | uint32(addr>>8&1)<<5
+	size32 := (addr & 0x1) != 0
+	last := (addr & 0x200) != 0
+
+	switch {
+	case (addr & 0x80) != 0:
+		if size32 {
+			m.setPrg32(bank)
+		} else {
+			m.setPrg16(bank, bank)
+		}
+	default:
+		low := bank
+		if size32 {
+			low &= 0x3E
+		}
+		if last {
+			m.setPrg16(low, bank|0x7)
+		} else {
+			m.setPrg16(low, bank&0x38)
+		}
+	}
+	m.chrProtect = (addr & 0x80) != 0
+	m.setMirroring((addr & 0x2) != 0)
+}
+
+// Mapper 228 ($8000-$FFFF)
+//
+// A~[1.MH HPPP PPO. CCCC]  D~[.... ..cc]
+//      || |||| ||  ||||              ||
+//      || |||| ||  ++++--------------++- 8 KB CHR bank, address bits are the high ones
+//      || |||| |+----------------------- PRG mode (0: 32 KB; 1: 16 KB mirrored)
+//      || |+++-+------------------------ 16 KB PRG bank within the chip
+//      |+-+----------------------------- PRG chip, chip 3 is the third in the file
+//      +-------------------------------- Mirroring (0: vertical; 1: horizontal)
+func (m *MapperMulticart) write228(addr uint16, val uint8) {
+	chip := uint32(addr>>11) & 0x3
+	if chip == 3 {
+		chip = 2
+	}
+	bank := chip<<5 | uint32(addr>>6)&0x1F
+	if (addr & 0x20) != 0 {
+		m.setPrg16(bank, bank)
+	} else {
+		m.setPrg32(bank)
+	}
+	m.chrBank = uint32(addr&0xF)<<2 | uint32(val&0x3)
+	m.setMirroring((addr & 0x2000) != 0)
+}
+
+// Mapper 233 ($8000-$FFFF)
+//
+// D~[MMOB BBBB]
+//    |||+-++++- PRG bank, bit 5 is toggled by every reset
+//    ||+------- PRG mode (0: 32 KB; 1: 16 KB mirrored)
+//    ++-------- Mirroring (0: three-screen, only $2C00 on the upper page;
+//                          1: vertical; 2: horizontal; 3: one-screen upper)
+func (m *MapperMulticart) write233(val uint8) {
+	bank := uint32(val&0x1F) | uint32(m.outerReset)
+	if (val & 0x20) != 0 {
+		m.setPrg16(bank, bank)
+	} else {
+		m.setPrg32(bank)
+	}
+
+	m.threeScreen = false
+	switch val >> 6 {
+	case 0:
+		m.threeScreen = true
+	case 1:
+		m.cart.SetMirroring(common.VerticalMirroring)
+	case 2:
+		m.cart.SetMirroring(common.HorizontalMirroring)
+	case 3:
+		m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+	}
+}
+
+func (m *MapperMulticart) tablePage(addr uint16) uint16 {
+	if (addr-0x2000)%0x1000/0x400 == 3 {
+		return 1
+	}
+	return 0
+}
+func (m *MapperMulticart) ReadTable(addr uint16) uint8 {
+	if !m.threeScreen {
+		return m.cart.Tables.Read8(addr)
+	}
+	return m.cart.Tables.ReadPage(m.tablePage(addr), addr)
+}
+func (m *MapperMulticart) WriteTable(addr uint16, val uint8) {
+	if !m.threeScreen {
+		m.cart.Tables.Write8(addr, val)
+		return
+	}
+	m.cart.Tables.WritePage(m.tablePage(addr), addr, val)
+}
+
+func (m *MapperMulticart) prgAddr(addr uint16) uint32 {
+	bank := m.prgBanks[(addr-0x8000)/0x2000]
+	return (bank*0x2000 + uint32(addr%0x2000)) % uint32(m.cart.prgRom.Size())
+}
+func (m *MapperMulticart) chrAddr(addr uint16) uint32 {
+	return (m.chrBank*0x2000 + uint32(addr)) % uint32(m.cart.chr.Size())
+}
+
+func (m *MapperMulticart) nibbleRamAddr(addr uint16) (uint16, bool) {
+	switch {
+	case m.mapper == 225 && addr >= 0x5800 && addr < 0x6000:
+		return addr & 0x3, true
+	case m.mapper == 228 && addr >= 0x4020 && addr < 0x6000:
+		return addr & 0x3, true
+	}
+	return 0, false
+}
+
+// PPU $0000-$1FFF: 8 KB CHR bank, ROM or RAM
+// CPU $4020-$5FFF: 4 bit RAM (225 and 228)
+// CPU $8000-$FFFF: PRG ROM banks, 16 KB or 32 KB, latched by the writes
+func (m *MapperMulticart) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr < 0x6000:
+		if ram, ok := m.nibbleRamAddr(addr); ok {
+			return m.nibbleRam[ram] | m.cart.openBus()&0xF0
+		}
+	case addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 {
+			return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+		}
+	default:
+		return m.cart.prgRom.Read8w(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperMulticart) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		if m.cart.config.chrRomSize == 0 && !m.chrProtect {
+			m.cart.chr.Write8w(m.chrAddr(addr), val)
+		}
+	case addr < 0x6000:
+		if ram, ok := m.nibbleRamAddr(addr); ok {
+			m.nibbleRam[ram] = val & 0xF
+		}
+	case addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
+	default:
+		m.write(addr, val)
+	}
+}
+
+func (m *MapperMulticart) mapperRegisters() []MapperRegister {
+	// the bank latched from the write address is only held as the banks
+	regs := []MapperRegister{
+		{"prgBanks[0]", int(m.prgBanks[0])}, {"prgBanks[1]", int(m.prgBanks[1])},
+		{"prgBanks[2]", int(m.prgBanks[2])}, {"prgBanks[3]", int(m.prgBanks[3])},
+		{"chrBank", int(m.chrBank)}, boolRegister("chrProtect", m.chrProtect),
+	}
+	switch m.mapper {
+	case 226:
+		regs = append(regs, registerFile("regs", m.regs[:])...)
+	case 233:
+		regs = append(regs, MapperRegister{"outerReset", int(m.outerReset)}, boolRegister("threeScreen", m.threeScreen))
+	}
+	return regs
+}
+
+func (m *MapperMulticart) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		m.prgBanks, m.chrBank, m.chrProtect, m.regs, m.nibbleRam,
+		m.outerReset, m.threeScreen,
+	)
+}
+func (m *MapperMulticart) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.prgBanks, &m.chrBank, &m.chrProtect, &m.regs, &m.nibbleRam,
+		&m.outerReset, &m.threeScreen,
+	)
+}
@@ -0,0 +1,452 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_019
+// https://wiki.nesdev.com/w/index.php/Namco_163_audio
+const (
+	n163RamSize       = 0x80
+	n163ChannelCycles = 15
+	n163IrqMax        = 0x7FFF
+	// each channel outputs (-8..7)*15, keep it in line with the APU channels
+	n163VolumeGain = 0.00075
+)
+
+type MapperN163 struct {
+	cart *Cartridge
+
+	// 128 bytes of internal RAM shared by the sound channels and the cpu
+	ram     common.Ram
+	ramAddr uint8
+	autoInc bool
+
+	chrRegs   [8]uint8
+	tableRegs [4]uint8
+	prgRegs   [3]uint8
+
+	// disables the CIRAM mapping of $E0-$FF CHR values for $0000/$1000
+	chrRamDisable [2]bool
+	soundDisable  bool
+	writeProtect  uint8
+
+	irqCounter uint16
+	irqEnable  bool
+	irqPending bool
+
+	// the channels are time multiplexed, only one is updated every 15 cpu cycles
+	channelClock uint8
+	channel      uint8
+	outputs      [8]float64
+}
+
+func (m *MapperN163) Init() {
+	m.ram.Init(n163RamSize)
+	m.ramAddr = 0
+	m.autoInc = false
+
+	m.chrRegs = [8]uint8{}
+	m.tableRegs = [4]uint8{0xE0, 0xE0, 0xE0, 0xE0}
+	m.prgRegs = [3]uint8{0, 1, 2}
+	m.chrRamDisable = [2]bool{}
+	m.soundDisable = false
+	m.writeProtect = 0
+
+	m.irqCounter = 0
+	m.irqEnable = false
+	m.irqPending = false
+
+	m.channelClock = 0
+	m.channel = 7
+	m.outputs = [8]float64{}
+}
+
+func (m *MapperN163) Tick() {}
+
+func (m *MapperN163) CpuTick() {
+	m.irqTick()
+	m.soundTick()
+}
+
+func (m *MapperN163) decodesExpansion() bool {
+	return true
+}
+
+func (m *MapperN163) BatteryRams() []*common.Ram {
+	if !m.cart.config.battery {
+		return nil
+	}
+	return []*common.Ram{&m.ram}
+}
+
+// The IRQ counter is a 15 bit up counter clocked by the cpu, once it reaches
+// $7FFF it stops counting and holds the IRQ line until acknowledged
+func (m *MapperN163) irqTick() {
+	if !m.irqEnable {
+		return
+	}
+	if m.irqCounter < n163IrqMax {
+		m.irqCounter++
+		if m.irqCounter == n163IrqMax {
+			m.irqPending = true
+		}
+	}
+	if m.irqPending {
+		m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
+	}
+}
+
+func (m *MapperN163) acknowledgeIrq() {
+	m.irqPending = false
+	m.cart.nes.CPU().Clear(cpu.CpuIntIRQ)
+}
+
+// Sound RAM $7F
+// 7  bit  0
+// ---- ----
+// .CCC VVVV
+//  ||| ||||
+//  ||| ++++- Volume of channel 7
+//  +++------ Number of enabled channels minus one, from channel 7 downwards
+func (m *MapperN163) activeChannels() uint8 {
+	return ((m.ram.Read8(0x7F) >> 4) & 0x7) + 1
+}
+
+func (m *MapperN163) soundTick() {
+	m.channelClock++
+	if m.channelClock < n163ChannelCycles {
+		return
+	}
+	m.channelClock = 0
+
+	if m.channel < 8-m.activeChannels() {
+		m.channel = 7
+	}
+	m.updateChannel(m.channel)
+	if m.channel == 8-m.activeChannels() {
+		m.channel = 7
+	} else {
+		m.channel--
+	}
+}
+
+// Channel registers, 8 bytes per channel starting at $40 + 8*channel
+// $x0 - Low 8 bits of frequency
+// $x1 - Low 8 bits of phase
+// $x2 - Middle 8 bits of frequency
+// $x3 - Middle 8 bits of phase
+// $x4 - High 2 bits of frequency, bits 2-7 wave length (256 - %LLLLLL00 samples)
+// $x5 - High 8 bits of phase
+// $x6 - Wave address, in 4 bit samples
+// $x7 - Volume
+func (m *MapperN163) updateChannel(channel uint8) {
+	base := 0x40 + uint16(channel)*8
+
+	freq := uint32(m.ram.Read8(base)) |
+		uint32(m.ram.Read8(base+2))<<8 |
+		uint32(m.ram.Read8(base+4)&0x3)<<16
+	phase := uint32(m.ram.Read8(base+1)) |
+		uint32(m.ram.Read8(base+3))<<8 |
+		uint32(m.ram.Read8(base+5))<<16
+	length := 256 - uint32(m.ram.Read8(base+4)&0xFC)
+
+	phase = (phase + freq) % (length << 16)
+	m.ram.Write8(base+1, uint8(phase))
+	m.ram.Write8(base+3, uint8(phase>>8))
+	m.ram.Write8(base+5, uint8(phase>>16))
+
+	sampleAddr := uint8((phase >> 16) + uint32(m.ram.Read8(base+6)))
+	sample := m.ram.Read8(uint16(sampleAddr>>1)) >> ((sampleAddr & 1) * 4) & 0xF
+	volume := m.ram.Read8(base+7) & 0xF
+
+	m.outputs[channel] = float64(int(sample)-8) * float64(volume)
+}
+
+// The real chip outputs a single channel at a time which the analog side of
+// the console smooths out, so average the active channels instead
+func (m *MapperN163) Sample() float64 {
+	if m.soundDisable {
+		return 0
+	}
+	active := m.activeChannels()
+	mix := 0.0
+	for channel := 8 - active; channel < 8; channel++ {
+		mix += m.outputs[channel]
+	}
+	return n163VolumeGain * mix / float64(active)
+}
+
+// Chip RAM Data Port ($4800-$4FFF) R/W
+// Reads or writes the internal RAM at the address set by $F800
+func (m *MapperN163) readData() uint8 {
+	val := m.ram.Read8(uint16(m.ramAddr))
+	m.incRamAddr()
+	return val
+}
+func (m *MapperN163) writeData(val uint8) {
+	m.ram.Write8(uint16(m.ramAddr), val)
+	m.incRamAddr()
+}
+func (m *MapperN163) incRamAddr() {
+	if m.autoInc {
+		m.ramAddr = (m.ramAddr + 1) % n163RamSize
+	}
+}
+
+// IRQ Counter (low) ($5000-$57FF) R/W
+// 7  bit  0
+// ---- ----
+// IIII IIII
+// |||| ||||
+// ++++-++++- Low 8 bits of IRQ counter
+//
+// IRQ Counter (high) / IRQ Enable ($5800-$5FFF) R/W
+// 7  bit  0
+// ---- ----
+// EIII IIII
+// |||| ||||
+// |+++-++++- High 7 bits of IRQ counter
+// +--------- IRQ Enable: (0: disabled; 1: enabled)
+func (m *MapperN163) writeIrqLow(val uint8) {
+	m.irqCounter = (m.irqCounter & 0x7F00) | uint16(val)
+	m.acknowledgeIrq()
+}
+func (m *MapperN163) writeIrqHigh(val uint8) {
+	m.irqCounter = (m.irqCounter & 0x00FF) | uint16(val&0x7F)<<8
+	m.irqEnable = (val & 0x80) != 0
+	m.acknowledgeIrq()
+}
+func (m *MapperN163) readIrqHigh() uint8 {
+	val := uint8(m.irqCounter>>8) & 0x7F
+	if m.irqEnable {
+		val |= 0x80
+	}
+	return val
+}
+
+// CHR and NT Select ($8000-$DFFF) W
+// Value CPU writes  Behavior
+// $00-$DF           Selects 1KB page of CHR-ROM
+// $E0-$FF           If enabled by bit in $E800, use Nametable RAM (CIRAM) page,
+//                   low bit selects which one
+// The four nametable registers ($C000-$DFFF) always use CIRAM for $E0-$FF
+func (m *MapperN163) writeChrSelect(addr uint16, val uint8) {
+	register := (addr - 0x8000) / 0x800
+	if register < 8 {
+		m.chrRegs[register] = val
+	} else {
+		m.tableRegs[register-8] = val
+	}
+}
+
+// PRG Select 1 ($E000-$E7FF) W
+// 7  bit  0
+// ---- ----
+// .SPP PPPP
+//  ||| ||||
+//  |++-++++- Select 8KB page of PRG-ROM at $8000
+//  +-------- Disable sound if set
+//
+// PRG Select 2 / CHR-RAM Enable ($E800-$EFFF) W
+// 7  bit  0
+// ---- ----
+// HLPP PPPP
+// |||| ||||
+// ||++-++++- Select 8KB page of PRG-ROM at $A000
+// |+-------- Disable CHR-RAM at $0000-$0FFF
+// +--------- Disable CHR-RAM at $1000-$1FFF
+//
+// PRG Select 3 ($F000-$F7FF) W
+// 7  bit  0
+// ---- ----
+// ..PP PPPP
+//   || ||||
+//   ++-++++- Select 8KB page of PRG-ROM at $C000
+func (m *MapperN163) writePrgSelect(addr uint16, val uint8) {
+	switch {
+	case addr < 0xE800:
+		m.prgRegs[0] = val & 0x3F
+		m.soundDisable = (val & 0x40) != 0
+	case addr < 0xF000:
+		m.prgRegs[1] = val & 0x3F
+		m.chrRamDisable[0] = (val & 0x40) != 0
+		m.chrRamDisable[1] = (val & 0x80) != 0
+	default:
+		m.prgRegs[2] = val & 0x3F
+	}
+}
+
+// Write Protect for External RAM AND Chip RAM Address Port ($F800-$FFFF) W
+// 7  bit  0
+// ---- ----
+// KKKK DCBA
+// |||| ||||
+// |||| |||+- 1: Write-protect 2KB window of external RAM from $6000-$67FF (0: write enable)
+// |||| ||+-- 1: Write-protect 2KB window of external RAM from $6800-$6FFF (0: write enable)
+// |||| |+--- 1: Write-protect 2KB window of external RAM from $7000-$77FF (0: write enable)
+// |||| +---- 1: Write-protect 2KB window of external RAM from $7800-$7FFF (0: write enable)
+// ++++------ Additionally the upper nibble must be equal to b0100 to enable writes
+//
+// The same value also sets the internal RAM address: bits 0-6 address, bit 7 auto-increment
+func (m *MapperN163) writeProtectAddr(val uint8) {
+	m.writeProtect = val
+	m.ramAddr = val & 0x7F
+	m.autoInc = (val & 0x80) != 0
+}
+
+func (m *MapperN163) prgRamWritable(addr uint16) bool {
+	window := uint8((addr - 0x6000) / 0x800)
+	return (m.writeProtect&0xF0) == 0x40 && (m.writeProtect>>window)&1 == 0
+}
+
+func (m *MapperN163) prgBankAddr(bank uint8, addr uint16) uint32 {
+	banks := uint32(m.cart.prgRom.Size() / 0x2000)
+	return (uint32(bank)%banks)*0x2000 + uint32(addr%0x2000)
+}
+
+// a CHR register either selects a 1KB CHR-ROM page or a CIRAM page
+func (m *MapperN163) chrCiram(register uint8, val uint8) bool {
+	if val < 0xE0 {
+		return false
+	}
+	if register >= 8 {
+		return true
+	}
+	return !m.chrRamDisable[register/4]
+}
+
+func (m *MapperN163) chrAddr(val uint8, addr uint16) uint32 {
+	return (uint32(val)*0x400 + uint32(addr%0x400)) % uint32(m.cart.chr.Size())
+}
+
+// PPU $0000-$1FFF: Eight 1 KB switchable CHR banks (CHR-ROM or CIRAM)
+// PPU $2000-$2FFF: Four 1 KB switchable nametables (CHR-ROM or CIRAM)
+func (m *MapperN163) ReadTable(addr uint16) uint8 {
+	slot := (addr - 0x2000) / 0x400 % 4
+	val := m.tableRegs[slot]
+	if m.chrCiram(uint8(slot)+8, val) {
+		return m.cart.Tables.ReadPage(uint16(val&1), addr)
+	}
+	return m.cart.chr.Read8w(m.chrAddr(val, addr))
+}
+func (m *MapperN163) WriteTable(addr uint16, val uint8) {
+	slot := (addr - 0x2000) / 0x400 % 4
+	reg := m.tableRegs[slot]
+	if m.chrCiram(uint8(slot)+8, reg) {
+		m.cart.Tables.WritePage(uint16(reg&1), addr, val)
+	}
+	// CHR-ROM nametables are read only
+}
+
+// CPU $4800-$4FFF: Internal RAM data port
+// CPU $5000-$5FFF: IRQ counter
+// CPU $6000-$7FFF: 8 KB PRG RAM, optionally battery backed
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+// CPU $C000-$DFFF: 8 KB switchable PRG ROM bank
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+func (m *MapperN163) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		register := uint8(addr / 0x400)
+		val := m.chrRegs[register]
+		if m.chrCiram(register, val) {
+			return m.cart.Tables.ReadPage(uint16(val&1), addr)
+		}
+		return m.cart.chr.Read8w(m.chrAddr(val, addr))
+
+	case addr >= 0x4800 && addr < 0x5000:
+		return m.readData()
+	case addr >= 0x5000 && addr < 0x5800:
+		return uint8(m.irqCounter)
+	case addr >= 0x5800 && addr < 0x6000:
+		return m.readIrqHigh()
+
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() == 0 {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+
+	case addr >= 0x8000 && addr < 0xE000:
+		return m.cart.prgRom.Read8w(m.prgBankAddr(m.prgRegs[(addr-0x8000)/0x2000], addr))
+	case addr >= 0xE000:
+		return m.cart.prgRom.Read8w(uint32(m.cart.prgRom.Size()) - 0x2000 + uint32(addr-0xE000))
+	}
+	return m.cart.openBus()
+}
+
+// Peek8 reads the sound RAM without moving its address
+func (m *MapperN163) Peek8(addr uint16) uint8 {
+	if addr >= 0x4800 && addr < 0x5000 {
+		return m.ram.Read8(uint16(m.ramAddr))
+	}
+	return m.Read8(addr)
+}
+
+func (m *MapperN163) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		register := uint8(addr / 0x400)
+		reg := m.chrRegs[register]
+		if m.chrCiram(register, reg) {
+			m.cart.Tables.WritePage(uint16(reg&1), addr, val)
+		} else {
+			m.cart.chr.Write8w(m.chrAddr(reg, addr), val)
+		}
+
+	case addr >= 0x4800 && addr < 0x5000:
+		m.writeData(val)
+	case addr >= 0x5000 && addr < 0x5800:
+		m.writeIrqLow(val)
+	case addr >= 0x5800 && addr < 0x6000:
+		m.writeIrqHigh(val)
+
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 && m.prgRamWritable(addr) {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
+
+	case addr >= 0x8000 && addr < 0xE000:
+		m.writeChrSelect(addr, val)
+	case addr >= 0xE000 && addr < 0xF800:
+		m.writePrgSelect(addr, val)
+	case addr >= 0xF800:
+		m.writeProtectAddr(val)
+	}
+}
+
+func (m *MapperN163) mapperRegisters() []MapperRegister {
+	regs := append(registerFile("prgRegs", m.prgRegs[:]), registerFile("chrRegs", m.chrRegs[:])...)
+	regs = append(regs, registerFile("tableRegs", m.tableRegs[:])...)
+	return append(regs,
+		boolRegister("chrRamDisable[0]", m.chrRamDisable[0]),
+		boolRegister("chrRamDisable[1]", m.chrRamDisable[1]),
+		boolRegister("soundDisable", m.soundDisable),
+		MapperRegister{"writeProtect", int(m.writeProtect)},
+		MapperRegister{"ramAddr", int(m.ramAddr)},
+		boolRegister("autoInc", m.autoInc),
+		MapperRegister{"irqCounter", int(m.irqCounter)},
+		boolRegister("irqEnable", m.irqEnable),
+		boolRegister("irqPending", m.irqPending),
+	)
+}
+
+func (m *MapperN163) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		&m.ram, m.ramAddr, m.autoInc, m.chrRegs, m.tableRegs, m.prgRegs,
+		m.chrRamDisable, m.soundDisable, m.writeProtect,
+		m.irqCounter, m.irqEnable, m.irqPending,
+		m.channelClock, m.channel, m.outputs,
+	)
+}
+func (m *MapperN163) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.ram, &m.ramAddr, &m.autoInc, &m.chrRegs, &m.tableRegs, &m.prgRegs,
+		&m.chrRamDisable, &m.soundDisable, &m.writeProtect,
+		&m.irqCounter, &m.irqEnable, &m.irqPending,
+		&m.channelClock, &m.channel, &m.outputs,
+	)
+}
@@ -1,8 +1,6 @@
 package mappers
 
 import (
-	"log"
-
 	"github.com/tiagolobocastro/gones/lib/common"
 )
 
@@ -23,19 +21,28 @@ func (m *MapperNROM) Read8(addr uint16) uint8 {
 	// often with a bank switching mechanism.
 	case addr < 0x2000:
 		return m.cart.chr.Read8(addr)
-	case addr < 0x8000:
-		return m.cart.prgRam.Read8(addr)
-	default:
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() == 0 {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+	case addr >= 0x8000:
 		return m.cart.prgRom.Read8(uint16(int(addr) % m.cart.prgRom.Size()))
 	}
+	return m.cart.openBus()
 }
 func (m *MapperNROM) Write8(addr uint16, val uint8) {
 	switch {
-	case addr >= 0x6000 && addr <= 0x8000:
-		m.cart.prgRam.Write8(addr%0x6000, val)
-	default:
-		log.Panicf("write not implemented for 0x%04x!", addr)
+	case addr < 0x2000:
+		if m.cart.config.chrRomSize == 0 {
+			m.cart.chr.Write8(addr, val)
+		}
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
 	}
+	// there are no registers, writes to the ROM are ignored
 }
 
 func (m *MapperNROM) Serialise(s common.Serialiser) error {
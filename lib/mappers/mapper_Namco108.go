@@ -0,0 +1,155 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// Namco 108 family, the MMC3 predecessor with only the bank select and bank
+// data registers: no IRQ, no PRG/CHR inversion and mirroring set by the board
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_206
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_088
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_095
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_154
+//
+// 206: plain DxROM/Namco 108
+// 88:  CHR A16 is tied to PPU A12, so $0000-$0FFF sees the lower 64 KB of
+//      CHR and $1000-$1FFF the upper 64 KB
+// 95:  NAMCOT-3425, bit 5 of R0 and R1 drives CIRAM A10 for each half of the
+//      nametables
+// 154: NAMCOT-3453, like 88 with bit 6 of any write selecting one-screen mirroring
+type MapperNamco108 struct {
+	MapperMMC3
+	mapper uint16
+
+	tablePages [2]uint16
+}
+
+func (m *MapperNamco108) Tick() {}
+
+func (m *MapperNamco108) Init() {
+	m.MapperMMC3.Init()
+	m.tablePages = [2]uint16{}
+}
+
+// Bank select ($8000-$9FFE, even)
+//
+// 7  bit  0
+// ---- ----
+// xxxx xRRR
+//       |||
+//       +++- Specify which bank register to update on next write to Bank Data register
+//
+// Bank data ($8001-$9FFF, odd)
+//
+// 7  bit  0
+// ---- ----
+// xxDD DDDD
+//   || ||||
+//   ++-++++- New bank value, based on last value written to Bank select register
+func (m *MapperNamco108) writeInner(addr uint16, val uint8) {
+	if m.mapper == 154 {
+		m.writeMirroring(val)
+	}
+	if addr > 0x9FFF {
+		return
+	}
+	if (addr & 1) == 0 {
+		m.writeBankSelect(val & 0x7)
+		return
+	}
+	if register := m.bankMode & 0x7; m.mapper == 95 && register < 2 {
+		m.tablePages[register] = uint16(val>>5) & 1
+	}
+	m.writeBankData(m.bankValue(val))
+}
+
+// Mirroring (154, $8000-$FFFF)
+//
+// 7  bit  0
+// ---- ----
+// xMxx xxxx
+//  |
+//  +-------- Select one-screen mirroring (0: lower; 1: upper)
+func (m *MapperNamco108) writeMirroring(val uint8) {
+	if (val & 0x40) == 0 {
+		m.cart.SetMirroring(common.SingleScreenMirroring)
+	} else {
+		m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+	}
+}
+
+func (m *MapperNamco108) bankValue(val uint8) uint8 {
+	register := m.bankMode & 0x7
+	switch {
+	case register >= 6:
+		banks := uint8(m.cart.prgRom.Size() / 0x2000)
+		return val & 0xF & (banks - 1)
+	case m.mapper == 88 || m.mapper == 154:
+		val &= 0x3F
+		if register >= 2 {
+			val |= 0x40
+		}
+	default:
+		val &= 0x3F
+	}
+	banks := uint32(m.cart.chr.Size() / 0x400)
+	return uint8(uint32(val) % banks)
+}
+
+// PPU $2000-$23FF, $2400-$27FF: CIRAM page selected by bit 5 of R0 (95 only)
+// PPU $2800-$2BFF, $2C00-$2FFF: CIRAM page selected by bit 5 of R1 (95 only)
+func (m *MapperNamco108) tablePage(addr uint16) uint16 {
+	return m.tablePages[(addr-0x2000)%0x1000/0x800]
+}
+func (m *MapperNamco108) ReadTable(addr uint16) uint8 {
+	if m.mapper != 95 {
+		return m.cart.Tables.Read8(addr)
+	}
+	return m.cart.Tables.ReadPage(m.tablePage(addr), addr)
+}
+func (m *MapperNamco108) WriteTable(addr uint16, val uint8) {
+	if m.mapper != 95 {
+		m.cart.Tables.Write8(addr, val)
+		return
+	}
+	m.cart.Tables.WritePage(m.tablePage(addr), addr, val)
+}
+
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last two banks
+// PPU $0000-$07FF: 2 KB switchable CHR bank
+// PPU $0800-$0FFF: 2 KB switchable CHR bank
+// PPU $1000-$1FFF: Four 1 KB switchable CHR banks
+func (m *MapperNamco108) Write8(addr uint16, val uint8) {
+	switch {
+	case addr >= 0x8000:
+		m.writeInner(addr, val)
+	default:
+		m.MapperMMC3.Write8(addr, val)
+	}
+}
+
+func (m *MapperNamco108) mapperRegisters() []MapperRegister {
+	regs := append(registerFile("registers", m.registers[:]), MapperRegister{"bankMode", int(m.bankMode)})
+	if m.mapper == 95 {
+		regs = append(regs,
+			MapperRegister{"tablePages[0]", int(m.tablePages[0])},
+			MapperRegister{"tablePages[1]", int(m.tablePages[1])},
+		)
+	}
+	return regs
+}
+
+func (m *MapperNamco108) Serialise(s common.Serialiser) error {
+	if err := m.MapperMMC3.Serialise(s); err != nil {
+		return err
+	}
+	return s.Serialise(m.tablePages)
+}
+func (m *MapperNamco108) DeSerialise(s common.Serialiser) error {
+	if err := m.MapperMMC3.DeSerialise(s); err != nil {
+		return err
+	}
+	return s.DeSerialise(&m.tablePages)
+}
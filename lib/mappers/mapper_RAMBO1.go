@@ -0,0 +1,205 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// Tengen RAMBO-1 (mapper 64), an MMC3 clone with three extra bank registers
+// and an IRQ counter which can also be clocked by the cpu
+// https://wiki.nesdev.com/w/index.php/RAMBO-1
+const rambo1Prescaler = 4
+
+type MapperRAMBO1 struct {
+	MapperMMC3
+
+	// R0-R9 and RF, the MMC3 registers only hold R0-R7
+	extraRegs  [16]uint8
+	irqCycles  bool
+	prescaler  uint8
+	irqPending bool
+}
+
+func (m *MapperRAMBO1) Init() {
+	m.extraRegs = [16]uint8{}
+	m.irqCycles = false
+	m.prescaler = 0
+	m.irqPending = false
+	m.MapperMMC3.Init()
+	m.updateAllBanks()
+}
+
+func (m *MapperRAMBO1) Tick() {
+	if !m.irqCycles && m.cart.nes.PPU().A12OutputHigh() {
+		m.clockIrq()
+	}
+}
+
+func (m *MapperRAMBO1) CpuTick() {
+	if m.irqCycles {
+		m.prescaler++
+		if m.prescaler == rambo1Prescaler {
+			m.prescaler = 0
+			m.clockIrq()
+		}
+	}
+	if m.irqPending {
+		m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
+	}
+}
+
+// Unlike the MMC3 a reload loads the latch plus one (plus two when the latch
+// is above 1) before the counter decrements
+func (m *MapperRAMBO1) clockIrq() {
+	switch {
+	case m.irqReload:
+		m.irqReload = false
+		if m.irqLatch <= 1 {
+			m.irqCounter = m.irqLatch + 1
+		} else {
+			m.irqCounter = m.irqLatch + 2
+		}
+	case m.irqCounter == 0:
+		m.irqCounter = m.irqLatch + 1
+	}
+	m.irqCounter--
+	if m.irqCounter == 0 && !m.irqDisable {
+		m.irqPending = true
+	}
+}
+
+func (m *MapperRAMBO1) writeInner(addr uint16, val uint8) {
+	even := (addr & 1) == 0
+	odd := !even
+	switch {
+	case addr >= 0x8000 && addr <= 0x9FFF && even:
+		m.bankMode = val
+		m.updateAllBanks()
+	case addr >= 0x8000 && addr <= 0x9FFF && odd:
+		m.writeBankData(val)
+	case addr >= 0xA000 && addr <= 0xBFFF && even:
+		m.writeMirroring(val)
+	case addr >= 0xC000 && addr <= 0xDFFF && even:
+		m.writeIrqLatch(val)
+	case addr >= 0xC000 && addr <= 0xDFFF && odd:
+		m.writeIrqMode(val)
+	case addr >= 0xE000 && addr <= 0xFFFF && even:
+		m.writeIrqDisable(val)
+		m.irqPending = false
+	case addr >= 0xE000 && addr <= 0xFFFF && odd:
+		m.writeIrqEnable(val)
+	}
+}
+
+// Bank select ($8000-$9FFE, even)
+//
+// 7  bit  0
+// ---- ----
+// CPKx RRRR
+// |||  ||||
+// |||  ++++- Specify which bank register to update on next write to Bank Data register
+// |||          0000-0111: R0-R7 as on the MMC3
+// |||          1000: R8: Select 1 KB CHR bank at PPU $0400 (or $1400)
+// |||          1001: R9: Select 1 KB CHR bank at PPU $0C00 (or $1C00)
+// |||          1111: RF: Select 8 KB PRG ROM bank at $C000-$DFFF (or $8000-$9FFF)
+// ||+------- Full 1 KB CHR mode (0: R0/R1 select 2 KB banks; 1: R0/R1/R8/R9 select 1 KB banks)
+// |+-------- PRG ROM bank mode (0: $8000 R6, $A000 R7, $C000 RF;
+// |                             1: $8000 RF, $A000 R6, $C000 R7)
+// +--------- CHR A12 inversion
+//
+// Bank data ($8001-$9FFF, odd)
+func (m *MapperRAMBO1) writeBankData(val uint8) {
+	m.extraRegs[m.bankMode&0xF] = val
+	m.updateAllBanks()
+}
+
+func (m *MapperRAMBO1) updateAllBanks() {
+	m.updateChrBanks()
+	m.updatePrgBanks()
+}
+
+func (m *MapperRAMBO1) updateChrBanks() {
+	r := func(register int) uint32 {
+		return uint32(m.extraRegs[register]) * 0x400
+	}
+	var banks [8]uint32
+	if (m.bankMode & 0x20) != 0 {
+		banks = [8]uint32{r(0), r(8), r(1), r(9), r(2), r(3), r(4), r(5)}
+	} else {
+		banks = [8]uint32{r(0) &^ 0x400, r(0) | 0x400, r(1) &^ 0x400, r(1) | 0x400, r(2), r(3), r(4), r(5)}
+	}
+	inversion := 0
+	if (m.bankMode & 0x80) != 0 {
+		inversion = 4
+	}
+	for slot := range banks {
+		m.chrBanks[slot^inversion] = banks[slot]
+	}
+}
+
+func (m *MapperRAMBO1) updatePrgBanks() {
+	r := func(register int) uint32 {
+		return uint32(m.extraRegs[register]) * 0x2000
+	}
+	last := uint32(m.cart.prgRom.Size()) - 0x2000
+	if (m.bankMode & 0x40) != 0 {
+		m.prgBanks = [4]uint32{r(0xF), r(6), r(7), last}
+	} else {
+		m.prgBanks = [4]uint32{r(6), r(7), r(0xF), last}
+	}
+}
+
+// IRQ mode select / reload ($C001-$DFFF, odd)
+//
+// 7  bit  0
+// ---- ----
+// xxxx xxxM
+//         |
+//         +- IRQ counter clock (0: PPU A12 like the MMC3; 1: every 4 cpu cycles)
+func (m *MapperRAMBO1) writeIrqMode(val uint8) {
+	m.irqCycles = (val & 1) != 0
+	m.prescaler = 0
+	m.writeIrqReload(val)
+}
+
+// CPU $8000-$9FFF: 8 KB switchable PRG ROM bank (R6 or RF)
+// CPU $A000-$BFFF: 8 KB switchable PRG ROM bank (R7 or R6)
+// CPU $C000-$DFFF: 8 KB switchable PRG ROM bank (RF or R7)
+// CPU $E000-$FFFF: 8 KB PRG ROM bank, fixed to the last bank
+func (m *MapperRAMBO1) Write8(addr uint16, val uint8) {
+	switch {
+	case addr >= 0x8000:
+		m.writeInner(addr, val)
+	default:
+		m.MapperMMC3.Write8(addr, val)
+	}
+}
+
+func (m *MapperRAMBO1) mapperRegisters() []MapperRegister {
+	// R0-R9 and RF
+	regs := append(registerFile("extraRegs", m.extraRegs[:10]), MapperRegister{"extraRegs[15]", int(m.extraRegs[15])})
+	return append(regs,
+		MapperRegister{"bankMode", int(m.bankMode)},
+		MapperRegister{"mirror", int(m.mirror)},
+		MapperRegister{"irqLatch", int(m.irqLatch)},
+		MapperRegister{"irqCounter", int(m.irqCounter)},
+		boolRegister("irqReload", m.irqReload),
+		boolRegister("irqDisable", m.irqDisable),
+		boolRegister("irqCycles", m.irqCycles),
+		MapperRegister{"prescaler", int(m.prescaler)},
+		boolRegister("irqPending", m.irqPending),
+	)
+}
+
+func (m *MapperRAMBO1) Serialise(s common.Serialiser) error {
+	if err := m.MapperMMC3.Serialise(s); err != nil {
+		return err
+	}
+	return s.Serialise(m.extraRegs, m.irqCycles, m.prescaler, m.irqPending)
+}
+func (m *MapperRAMBO1) DeSerialise(s common.Serialiser) error {
+	if err := m.MapperMMC3.DeSerialise(s); err != nil {
+		return err
+	}
+	return s.DeSerialise(&m.extraRegs, &m.irqCycles, &m.prescaler, &m.irqPending)
+}
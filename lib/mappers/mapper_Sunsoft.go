@@ -0,0 +1,235 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// Sunsoft-3 (mapper 67) and Sunsoft-4 (mapper 68)
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_067
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_068
+//
+// 67: Sunsoft-3, four 2 KB CHR banks and a 16 bit cpu cycle IRQ counter
+// 68: Sunsoft-4, four 2 KB CHR banks and 1 KB CHR-ROM banks usable as
+//     nametables in place of the CIRAM
+type MapperSunsoft struct {
+	cart   *Cartridge
+	mapper uint16
+
+	prgReg  uint8
+	chrRegs [4]uint8
+
+	// Sunsoft-4 only
+	tableRegs [2]uint8
+	chrTables bool
+	ramEnable bool
+
+	// Sunsoft-3 only
+	irqCounter uint16
+	irqHigh    bool
+	irqEnable  bool
+	irqPending bool
+}
+
+func (m *MapperSunsoft) Init() {
+	m.prgReg = 0
+	m.chrRegs = [4]uint8{}
+
+	m.tableRegs = [2]uint8{}
+	m.chrTables = false
+	m.ramEnable = false
+
+	m.irqCounter = 0
+	m.irqHigh = true
+	m.irqEnable = false
+	m.irqPending = false
+}
+
+func (m *MapperSunsoft) Tick() {}
+
+// The Sunsoft-3 counter is decremented every cpu cycle, when it wraps from 0
+// the IRQ fires and the counter pauses until enabled again
+func (m *MapperSunsoft) CpuTick() {
+	if m.mapper != 67 {
+		return
+	}
+	if m.irqEnable {
+		if m.irqCounter == 0 {
+			m.irqPending = true
+			m.irqEnable = false
+		}
+		m.irqCounter--
+	}
+	if m.irqPending {
+		m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
+	}
+}
+
+func (m *MapperSunsoft) writeMirroring(val uint8) {
+	switch val & 0x3 {
+	case 0:
+		m.cart.SetMirroring(common.VerticalMirroring)
+	case 1:
+		m.cart.SetMirroring(common.HorizontalMirroring)
+	case 2:
+		m.cart.SetMirroring(common.SingleScreenMirroring)
+	case 3:
+		m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+	}
+}
+
+// Sunsoft-3 registers, decoded on A11-A15
+//
+// $8800, $9800, $A800, $B800: CHR regs, 2 KB at PPU $0000-$1800
+// $C800: IRQ counter, high byte first then the low byte
+// $D800: [...E ....] IRQ enable, acknowledges the IRQ and resets the $C800 toggle
+// $E800: [.... ..MM] Mirroring (0: vertical; 1: horizontal;
+//                               2: one-screen lower; 3: one-screen upper)
+// $F800: PRG reg, 16 KB at $8000
+func (m *MapperSunsoft) writeSunsoft3(addr uint16, val uint8) {
+	if (addr & 0x800) == 0 {
+		return
+	}
+	switch addr & 0xF000 {
+	case 0x8000, 0x9000, 0xA000, 0xB000:
+		m.chrRegs[(addr-0x8000)>>12] = val
+	case 0xC000:
+		if m.irqHigh {
+			m.irqCounter = (m.irqCounter & 0x00FF) | uint16(val)<<8
+		} else {
+			m.irqCounter = (m.irqCounter & 0xFF00) | uint16(val)
+		}
+		m.irqHigh = !m.irqHigh
+	case 0xD000:
+		m.irqEnable = (val & 0x10) != 0
+		m.irqHigh = true
+		m.irqPending = false
+		m.cart.nes.CPU().Clear(cpu.CpuIntIRQ)
+	case 0xE000:
+		m.writeMirroring(val)
+	case 0xF000:
+		m.prgReg = val
+	}
+}
+
+// Sunsoft-4 registers
+//
+// $8000-$BFFF: CHR regs, 2 KB at PPU $0000-$1800, one per $1000
+// $C000-$CFFF: CHR-ROM nametable reg 0, 1 KB bank (D7 is forced on)
+// $D000-$DFFF: CHR-ROM nametable reg 1, 1 KB bank (D7 is forced on)
+// $E000-$EFFF: [...C ..MM]
+//                  |   ++- Mirroring (0: vertical; 1: horizontal;
+//                  |                  2: one-screen lower; 3: one-screen upper)
+//                  +------ Nametables (0: CIRAM; 1: CHR-ROM, through the
+//                          nametable regs in place of CIRAM pages 0 and 1)
+// $F000-$FFFF: [...E PPPP] PRG reg, 16 KB at $8000, E enables the PRG RAM
+func (m *MapperSunsoft) writeSunsoft4(addr uint16, val uint8) {
+	switch addr & 0xF000 {
+	case 0x8000, 0x9000, 0xA000, 0xB000:
+		m.chrRegs[(addr-0x8000)>>12] = val
+	case 0xC000, 0xD000:
+		m.tableRegs[(addr-0xC000)>>12] = val | 0x80
+	case 0xE000:
+		m.writeMirroring(val)
+		m.chrTables = (val & 0x10) != 0
+	case 0xF000:
+		m.prgReg = val & 0xF
+		m.ramEnable = (val & 0x10) != 0
+	}
+}
+
+func (m *MapperSunsoft) prgAddr(addr uint16) uint32 {
+	banks := uint32(m.cart.prgRom.Size() / 0x4000)
+	bank := banks - 1
+	if addr < 0xC000 {
+		bank = uint32(m.prgReg) % banks
+	}
+	return bank*0x4000 + uint32(addr%0x4000)
+}
+func (m *MapperSunsoft) chrAddr(addr uint16) uint32 {
+	bank := uint32(m.chrRegs[addr/0x800])
+	return (bank*0x800 + uint32(addr%0x800)) % uint32(m.cart.chr.Size())
+}
+func (m *MapperSunsoft) tableAddr(addr uint16) uint32 {
+	bank := uint32(m.tableRegs[m.cart.Tables.Page(addr)%2])
+	return (bank*0x400 + uint32(addr%0x400)) % uint32(m.cart.chr.Size())
+}
+
+// PPU $2000-$2FFF: CIRAM, or the Sunsoft-4 CHR-ROM nametables
+func (m *MapperSunsoft) ReadTable(addr uint16) uint8 {
+	if m.chrTables {
+		return m.cart.chr.Read8w(m.tableAddr(addr))
+	}
+	return m.cart.Tables.Read8(addr)
+}
+func (m *MapperSunsoft) WriteTable(addr uint16, val uint8) {
+	// CHR-ROM nametables are read only
+	if !m.chrTables {
+		m.cart.Tables.Write8(addr, val)
+	}
+}
+
+// PPU $0000-$1FFF: Four 2 KB switchable CHR banks
+// CPU $6000-$7FFF: 8 KB PRG RAM, optionally battery backed
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+func (m *MapperSunsoft) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() == 0 || (m.mapper == 68 && !m.ramEnable) {
+			return m.cart.openBus()
+		}
+		return m.cart.prgRam.Read8((addr - 0x6000) % uint16(m.cart.prgRam.Size()))
+	case addr >= 0x8000:
+		return m.cart.prgRom.Read8w(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperSunsoft) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+	case addr >= 0x6000 && addr < 0x8000:
+		if m.cart.prgRam.Size() > 0 && (m.mapper != 68 || m.ramEnable) {
+			m.cart.prgRam.Write8((addr-0x6000)%uint16(m.cart.prgRam.Size()), val)
+		}
+	case addr >= 0x8000 && m.mapper == 67:
+		m.writeSunsoft3(addr, val)
+	case addr >= 0x8000:
+		m.writeSunsoft4(addr, val)
+	}
+}
+
+func (m *MapperSunsoft) mapperRegisters() []MapperRegister {
+	regs := append([]MapperRegister{{"prgReg", int(m.prgReg)}}, registerFile("chrRegs", m.chrRegs[:])...)
+	switch m.mapper {
+	case 67:
+		regs = append(regs,
+			MapperRegister{"irqCounter", int(m.irqCounter)},
+			boolRegister("irqHigh", m.irqHigh),
+			boolRegister("irqEnable", m.irqEnable),
+			boolRegister("irqPending", m.irqPending),
+		)
+	case 68:
+		regs = append(append(regs, registerFile("tableRegs", m.tableRegs[:])...),
+			boolRegister("chrTables", m.chrTables),
+			boolRegister("ramEnable", m.ramEnable),
+		)
+	}
+	return regs
+}
+
+func (m *MapperSunsoft) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		m.prgReg, m.chrRegs, m.tableRegs, m.chrTables, m.ramEnable,
+		m.irqCounter, m.irqHigh, m.irqEnable, m.irqPending,
+	)
+}
+func (m *MapperSunsoft) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.prgReg, &m.chrRegs, &m.tableRegs, &m.chrTables, &m.ramEnable,
+		&m.irqCounter, &m.irqHigh, &m.irqEnable, &m.irqPending,
+	)
+}
@@ -0,0 +1,187 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// Taito TC0190 (mapper 33) and TC0690 (mapper 48)
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_033
+// https://wiki.nesdev.com/w/index.php/INES_Mapper_048
+//
+// 33: TC0190, mirroring through bit 6 of $8000
+// 48: TC0690, mirroring moved to $E000 and an MMC3 like scanline IRQ
+type MapperTaito struct {
+	cart   *Cartridge
+	mapper uint16
+
+	prgRegs [2]uint8
+	// two 2 KB banks followed by four 1 KB banks
+	chrRegs [6]uint8
+
+	irqLatch   uint8
+	irqCounter uint8
+	irqReload  bool
+	irqEnable  bool
+	irqPending bool
+}
+
+func (m *MapperTaito) Init() {
+	m.prgRegs = [2]uint8{0, 1}
+	m.chrRegs = [6]uint8{}
+
+	m.irqLatch = 0
+	m.irqCounter = 0
+	m.irqReload = false
+	m.irqEnable = false
+	m.irqPending = false
+}
+
+// The TC0690 counter is clocked by the PPU A12 rising edges like the MMC3,
+// the real IRQ is a few cycles later than the MMC3 one
+func (m *MapperTaito) Tick() {
+	if m.mapper != 48 {
+		return
+	}
+	if m.cart.nes.PPU().A12OutputHigh() {
+		if m.irqCounter == 0 || m.irqReload {
+			m.irqCounter = m.irqLatch
+			m.irqReload = false
+		} else {
+			m.irqCounter--
+		}
+		if m.irqCounter == 0 && m.irqEnable {
+			m.irqPending = true
+		}
+	}
+	if m.irqPending {
+		m.cart.nes.CPU().Raise(cpu.CpuIntIRQ)
+	}
+}
+
+func (m *MapperTaito) setMirroring(horizontal bool) {
+	if horizontal {
+		m.cart.SetMirroring(common.HorizontalMirroring)
+	} else {
+		m.cart.SetMirroring(common.VerticalMirroring)
+	}
+}
+
+// $8000: [.MPP PPPP] PRG reg 0, 8 KB at $8000, M is the TC0190 mirroring
+//                    (0: vertical; 1: horizontal)
+// $8001: [..PP PPPP] PRG reg 1, 8 KB at $A000
+// $8002: CHR reg 0, 2 KB at PPU $0000
+// $8003: CHR reg 1, 2 KB at PPU $0800
+// $A000-$A003: CHR regs 2-5, 1 KB at PPU $1000-$1C00
+// $C000: IRQ latch, the value written is inverted (TC0690)
+// $C001: IRQ reload (TC0690)
+// $C002: IRQ enable (TC0690)
+// $C003: IRQ disable and acknowledge (TC0690)
+// $E000: [.M.. ....] Mirroring (TC0690, 0: vertical; 1: horizontal)
+func (m *MapperTaito) writeRegister(addr uint16, val uint8) {
+	reg := addr & 0x3
+	switch addr & 0xE000 {
+	case 0x8000:
+		switch reg {
+		case 0, 1:
+			m.prgRegs[reg] = val & 0x3F
+			if reg == 0 && m.mapper == 33 {
+				m.setMirroring((val & 0x40) != 0)
+			}
+		default:
+			m.chrRegs[reg-2] = val
+		}
+	case 0xA000:
+		m.chrRegs[reg+2] = val
+	case 0xC000:
+		if m.mapper != 48 {
+			return
+		}
+		switch reg {
+		case 0:
+			m.irqLatch = val ^ 0xFF
+		case 1:
+			m.irqReload = true
+			m.irqCounter = 0
+		case 2:
+			m.irqEnable = true
+		case 3:
+			m.irqEnable = false
+			m.irqPending = false
+			m.cart.nes.CPU().Clear(cpu.CpuIntIRQ)
+		}
+	case 0xE000:
+		if m.mapper == 48 && reg == 0 {
+			m.setMirroring((val & 0x40) != 0)
+		}
+	}
+}
+
+func (m *MapperTaito) prgAddr(addr uint16) uint32 {
+	banks := uint32(m.cart.prgRom.Size() / 0x2000)
+	var bank uint32
+	switch slot := (addr - 0x8000) / 0x2000; slot {
+	case 0, 1:
+		bank = uint32(m.prgRegs[slot]) % banks
+	case 2:
+		bank = banks - 2
+	case 3:
+		bank = banks - 1
+	}
+	return bank*0x2000 + uint32(addr%0x2000)
+}
+func (m *MapperTaito) chrAddr(addr uint16) uint32 {
+	var bank uint32
+	if addr < 0x1000 {
+		bank = uint32(m.chrRegs[addr/0x800])*0x800 + uint32(addr%0x800)
+	} else {
+		bank = uint32(m.chrRegs[2+(addr-0x1000)/0x400])*0x400 + uint32(addr%0x400)
+	}
+	return bank % uint32(m.cart.chr.Size())
+}
+
+// PPU $0000-$0FFF: Two 2 KB switchable CHR banks
+// PPU $1000-$1FFF: Four 1 KB switchable CHR banks
+// CPU $8000-$BFFF: Two 8 KB switchable PRG ROM banks
+// CPU $C000-$FFFF: 16 KB PRG ROM, fixed to the last two banks
+func (m *MapperTaito) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr >= 0x8000:
+		return m.cart.prgRom.Read8w(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperTaito) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+	case addr >= 0x8000:
+		m.writeRegister(addr, val)
+	}
+}
+
+func (m *MapperTaito) mapperRegisters() []MapperRegister {
+	regs := append(registerFile("prgRegs", m.prgRegs[:]), registerFile("chrRegs", m.chrRegs[:])...)
+	return append(regs,
+		MapperRegister{"irqLatch", int(m.irqLatch)},
+		MapperRegister{"irqCounter", int(m.irqCounter)},
+		boolRegister("irqReload", m.irqReload),
+		boolRegister("irqEnable", m.irqEnable),
+		boolRegister("irqPending", m.irqPending),
+	)
+}
+
+func (m *MapperTaito) Serialise(s common.Serialiser) error {
+	return s.Serialise(
+		m.prgRegs, m.chrRegs,
+		m.irqLatch, m.irqCounter, m.irqReload, m.irqEnable, m.irqPending,
+	)
+}
+func (m *MapperTaito) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(
+		&m.prgRegs, &m.chrRegs,
+		&m.irqLatch, &m.irqCounter, &m.irqReload, &m.irqEnable, &m.irqPending,
+	)
+}
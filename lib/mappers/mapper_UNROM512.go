@@ -0,0 +1,114 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// UNROM 512 (mapper 30)
+// https://wiki.nesdev.com/w/index.php/UNROM_512
+// The battery bit marks the self-flashable boards, their PRG is a SST39SF040
+// which is saved like battery backed RAM. The other boards have bus conflicts
+// on the bank register, like UxROM
+const unrom512ChrRamSize = 0x8000
+
+type MapperUNROM512 struct {
+	cart *Cartridge
+
+	prgBank uint8
+	chrBank uint8
+
+	flashable bool
+	flash     sstFlash
+}
+
+func (m *MapperUNROM512) Init() {
+	m.prgBank = 0
+	m.chrBank = 0
+	if m.cart.config.chrRomSize == 0 {
+		m.cart.chr.Init(unrom512ChrRamSize, true)
+	}
+	m.flashable = m.cart.config.battery
+	if m.flashable {
+		m.flash.Init(m.cart.prgRom)
+	}
+}
+
+func (m *MapperUNROM512) Tick() {}
+
+func (m *MapperUNROM512) flashesPrg() bool {
+	return m.flashable
+}
+
+// Bank select ($8000-$FFFF, or $C000-$FFFF on the flashable boards)
+//
+// 7  bit  0
+// ---- ----
+// MCCP PPPP
+// |||+-++++- Select 16 KB PRG ROM bank at $8000
+// |++------- Select 8 KB CHR RAM bank at PPU $0000
+// +--------- Select the one-screen CIRAM page, when the header asks for
+//            one-screen mirroring
+func (m *MapperUNROM512) writeBankSelect(val uint8) {
+	m.prgBank = val & 0x1F
+	m.chrBank = (val >> 5) & 0x3
+
+	if m.cart.config.mirror == uint8(common.SingleScreenMirroring) {
+		if (val & 0x80) == 0 {
+			m.cart.SetMirroring(common.SingleScreenMirroring)
+		} else {
+			m.cart.SetMirroring(common.SingleScreenMirroringUpper)
+		}
+	}
+}
+
+func (m *MapperUNROM512) prgAddr(bank uint8, addr uint16) uint32 {
+	return (uint32(bank)*0x4000 + uint32(addr%0x4000)) % uint32(m.cart.prgRom.Size())
+}
+func (m *MapperUNROM512) readPrg(bank uint8, addr uint16) uint8 {
+	if m.flashable {
+		return m.flash.Read8(m.prgAddr(bank, addr))
+	}
+	return m.cart.prgRom.Read8w(m.prgAddr(bank, addr))
+}
+func (m *MapperUNROM512) chrAddr(addr uint16) uint32 {
+	return (uint32(m.chrBank)*0x2000 + uint32(addr)) % uint32(m.cart.chr.Size())
+}
+
+// PPU $0000-$1FFF: 8 KB switchable CHR RAM bank
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+func (m *MapperUNROM512) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8w(m.chrAddr(addr))
+	case addr >= 0x8000 && addr < 0xC000:
+		return m.readPrg(m.prgBank, addr)
+	case addr >= 0xC000:
+		return m.readPrg(uint8(m.cart.prgRom.Size()/0x4000)-1, addr)
+	}
+	return m.cart.openBus()
+}
+func (m *MapperUNROM512) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.cart.chr.Write8w(m.chrAddr(addr), val)
+	case addr >= 0x8000 && addr < 0xC000 && m.flashable:
+		m.flash.Write8(m.prgAddr(m.prgBank, addr), val)
+	case addr >= 0x8000:
+		if !m.flashable {
+			val &= m.Read8(addr)
+		}
+		m.writeBankSelect(val)
+	}
+}
+
+func (m *MapperUNROM512) mapperRegisters() []MapperRegister {
+	return []MapperRegister{{"prgBank", int(m.prgBank)}, {"chrBank", int(m.chrBank)}}
+}
+
+func (m *MapperUNROM512) Serialise(s common.Serialiser) error {
+	return s.Serialise(m.prgBank, m.chrBank, m.flashable, &m.flash)
+}
+func (m *MapperUNROM512) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(&m.prgBank, &m.chrBank, &m.flashable, &m.flash)
+}
@@ -0,0 +1,84 @@
+package mappers
+
+import (
+	"github.com/tiagolobocastro/gones/lib/common"
+)
+
+// UxROM (mapper 2)
+// https://wiki.nesdev.com/w/index.php/UxROM
+// The bank register is written through the ROM which keeps driving the data
+// bus, the board sees the AND of both values (bus conflict). Submapper 1
+// marks the boards without bus conflicts
+type MapperUxROM struct {
+	cart *Cartridge
+
+	prgBank uint8
+}
+
+func (m *MapperUxROM) Init() {
+	m.prgBank = 0
+}
+
+func (m *MapperUxROM) Tick() {}
+
+func (m *MapperUxROM) busConflicts() bool {
+	return m.cart.config.submapper != 1
+}
+
+// Bank select ($8000-$FFFF)
+//
+// 7  bit  0
+// ---- ----
+// xxxx pPPP
+//      ||||
+//      ++++- Select 16 KB PRG ROM bank for CPU $8000-$BFFF
+//           (UNROM uses bits 2-0; UOROM uses bits 3-0)
+func (m *MapperUxROM) writeBankSelect(addr uint16, val uint8) {
+	if m.busConflicts() {
+		val &= m.Read8(addr)
+	}
+	m.prgBank = val
+}
+
+func (m *MapperUxROM) prgAddr(addr uint16) uint32 {
+	banks := uint32(m.cart.prgRom.Size() / 0x4000)
+	bank := banks - 1
+	if addr < 0xC000 {
+		bank = uint32(m.prgBank) % banks
+	}
+	return bank*0x4000 + uint32(addr%0x4000)
+}
+
+// PPU $0000-$1FFF: 8 KB CHR RAM
+// CPU $8000-$BFFF: 16 KB switchable PRG ROM bank
+// CPU $C000-$FFFF: 16 KB PRG ROM bank, fixed to the last bank
+func (m *MapperUxROM) Read8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.cart.chr.Read8(addr)
+	case addr >= 0x8000:
+		return m.cart.prgRom.Read8w(m.prgAddr(addr))
+	}
+	return m.cart.openBus()
+}
+func (m *MapperUxROM) Write8(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		if m.cart.config.chrRomSize == 0 {
+			m.cart.chr.Write8(addr, val)
+		}
+	case addr >= 0x8000:
+		m.writeBankSelect(addr, val)
+	}
+}
+
+func (m *MapperUxROM) mapperRegisters() []MapperRegister {
+	return []MapperRegister{{"prgBank", int(m.prgBank)}}
+}
+
+func (m *MapperUxROM) Serialise(s common.Serialiser) error {
+	return s.Serialise(m.prgBank)
+}
+func (m *MapperUxROM) DeSerialise(s common.Serialiser) error {
+	return s.DeSerialise(&m.prgBank)
+}
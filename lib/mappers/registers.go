@@ -0,0 +1,38 @@
+package mappers
+
+import (
+	"fmt"
+)
+
+// MapperRegister is the value of a mapper register, eg: a bank, a latch or
+// an irq counter
+type MapperRegister struct {
+	Name  string
+	Value int
+}
+
+// MapperRegisters lists the registers of the mapper, as the board holds them,
+// nil if it has none. They can only be peeked, as a poke would bypass the
+// banking worked out on the register writes
+func (c *Cartridge) MapperRegisters() []MapperRegister {
+	if m, ok := c.Mapper.(registerMapper); ok {
+		return m.mapperRegisters()
+	}
+	return nil
+}
+
+// the entries of a register file, eg: chrRegs[0] to chrRegs[7]
+func registerFile(name string, vals []uint8) []MapperRegister {
+	regs := make([]MapperRegister, len(vals))
+	for i, val := range vals {
+		regs[i] = MapperRegister{Name: fmt.Sprintf("%s[%d]", name, i), Value: int(val)}
+	}
+	return regs
+}
+
+func boolRegister(name string, b bool) MapperRegister {
+	if b {
+		return MapperRegister{Name: name, Value: 1}
+	}
+	return MapperRegister{Name: name, Value: 0}
+}
@@ -0,0 +1,125 @@
+package nesInternal
+
+import (
+	"log"
+	"os"
+
+	"github.com/tiagolobocastro/gones/lib/cdl"
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// cdlHooks feeds the code/data logger from the cpu and the buses, the
+// accesses are told apart by what the cpu is running
+type cdlHooks struct {
+	log *cdl.Logger
+	ins [256]cpu.Instruction
+
+	// the bytes of the running instruction, whose reads are not data
+	pc, end int
+	// the flags of its data reads, eg: indirect
+	dataFlags uint8
+	// the next instruction is the target of an indirect jump
+	indirect bool
+	// a DMC sample fetch or a $2007 read is in progress
+	sample  bool
+	chrRead bool
+}
+
+// the logger starts right away, merged with the file if it exists
+func (n *nes) initCdl() {
+	n.cdl = nil
+	if n.cdlPath == "" {
+		return
+	}
+	h := &cdlHooks{
+		log: cdl.New(n.cart.PrgRomSize(), n.cart.ChrRomSize()),
+		ins: cpu.Instructions(cpu.Variant2A03),
+	}
+	if f, err := os.Open(n.cdlPath); err == nil {
+		err = h.log.Load(f)
+		f.Close()
+		if err != nil {
+			log.Printf("Failed to load the code/data log, err=%v", err)
+		}
+	}
+	h.log.Start()
+	n.cdl = h
+}
+
+// CodeDataLogger is nil unless the CodeDataLog option is set
+func (n *nes) CodeDataLogger() *cdl.Logger {
+	if n.cdl == nil {
+		return nil
+	}
+	return n.cdl.log
+}
+
+func (n *nes) saveCdl() {
+	if n.cdl == nil {
+		return
+	}
+	f, err := os.Create(n.cdlPath)
+	if err == nil {
+		_, err = n.cdl.log.WriteTo(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save the code/data log, err=%v", err)
+	}
+}
+
+// marks the instruction bytes as code, before it runs
+func (n *nes) cdlExec(pc uint16) {
+	h := n.cdl
+	ins := &h.ins[(&cpuMapper{n}).Peek8(pc)]
+
+	flags := uint8(cdl.Code)
+	if h.indirect {
+		flags |= cdl.IndirectCode
+	}
+	for i := 0; i < ins.Length(); i++ {
+		addr := pc + uint16(i)
+		h.log.Prg(n.cart.PrgOffset(addr), addr, flags)
+	}
+
+	// the 1 byte instructions also do a dummy read of the next byte
+	h.pc, h.end = int(pc), int(pc)+ins.Length()
+	if ins.Length() < 2 {
+		h.end = int(pc) + 2
+	}
+	h.dataFlags = cdl.Data
+	switch ins.AddrMode() {
+	case cpu.ModeIndexedIndirectX, cpu.ModeIndirectIndexedY, cpu.ModeZeroPageIndirect:
+		h.dataFlags |= cdl.IndirectData
+	}
+	h.indirect = ins.AddrMode() == cpu.ModeIndirect || ins.AddrMode() == cpu.ModeIndexedAbsoluteIndirectX
+}
+
+// marks the PRG-ROM read by the cpu bus, the fetches of the running
+// instruction were already marked as code
+func (n *nes) cdlCpuRead(addr uint16) {
+	h := n.cdl
+	offset := n.cart.LastPrgRead()
+	switch {
+	case offset < 0:
+	case h.sample:
+		h.log.Prg(offset, addr, cdl.Sample)
+	case int(addr) < h.pc || int(addr) >= h.end:
+		h.log.Prg(offset, addr, h.dataFlags)
+	}
+}
+
+// marks the CHR-ROM read by the ppu bus, by the rendering unless it's for
+// $2007
+func (n *nes) cdlPpuRead() {
+	h := n.cdl
+	if offset := n.cart.LastChrRead(); offset >= 0 {
+		flags := uint8(cdl.Drawn)
+		if h.chrRead {
+			flags = cdl.ChrRead
+		}
+		h.log.Chr(offset, flags)
+	}
+}
@@ -0,0 +1,102 @@
+package nesInternal
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/tiagolobocastro/gones/lib/cdl"
+)
+
+const cdlTestCode = `
+        .org $C000
+reset:  sei
+        lda table
+        lda #<table
+        sta $10
+        lda #>table
+        sta $11
+        ldy #1
+        lda ($10),y
+        ; the CHR at $0010, through $2007
+        bit $2002
+        lda #$00
+        sta $2006
+        lda #$10
+        sta $2006
+        lda $2007
+        ; a DMC sample of 17 bytes at $F000
+        lda #(sample - $C000) / 64
+        sta $4012
+        lda #1
+        sta $4013
+        lda #$0F
+        sta $4010
+        lda #$10
+        sta $4015
+        ; the background
+        lda #$08
+        sta $2001
+        jmp (vector)
+target: jmp target
+table:  .byte 1, 2, 3
+vector: .word target
+
+        .org $F000
+sample: .byte $AA
+
+        .org $FFFA
+        .word reset, reset, reset
+`
+
+func Test_CodeDataLog(t *testing.T) {
+	// NROM with 16 KB of PRG-ROM and 8 KB of CHR-ROM
+	header := []byte{'N', 'E', 'S', 0x1A, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	rom, prog := writeTestRom(t, header, cdlTestCode, nil)
+	path := filepath.Join(filepath.Dir(rom), "cdl.cdl")
+	nes := newNES(CartPath(rom), Headless(true), CodeDataLog(path))
+	nes.Step(0.05)
+	nes.Stop()
+
+	log, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 0x6000 {
+		t.Fatalf("%d bytes, expected the PRG and the CHR", len(log))
+	}
+
+	prg := func(label string, offset int) int {
+		return prog.Labels[label] - 0xC000 + offset
+	}
+	// the DMC keeps fetching from power on, so the sample bit is checked on
+	// its own, and the code and the data are all mapped at $C000-$FFFF
+	const mask = cdl.Code | cdl.Data | cdl.IndirectCode | cdl.IndirectData | 0x0C
+	for _, test := range []struct {
+		offset   int
+		expected uint8
+	}{
+		{prg("reset", 0), cdl.Code | 0x08},
+		{prg("reset", 1), cdl.Code | 0x08},
+		{prg("target", 0), cdl.Code | cdl.IndirectCode | 0x08},
+		{prg("table", 0), cdl.Data | 0x08},
+		{prg("table", 1), cdl.Data | cdl.IndirectData | 0x08},
+		{prg("vector", 0), cdl.Data | 0x08},
+	} {
+		if log[test.offset]&mask != test.expected {
+			t.Errorf("$%04X is $%02X, expected $%02X", test.offset, log[test.offset], test.expected)
+		}
+	}
+	if log[prg("table", 2)]&(cdl.Code|cdl.Data) != 0 {
+		t.Errorf("$%04X is $%02X, it was never read", prg("table", 2), log[prg("table", 2)])
+	}
+	for i := 0; i < 17; i++ {
+		if log[prg("sample", i)]&cdl.Sample == 0 {
+			t.Errorf("$%04X is $%02X, expected a sample", prg("sample", i), log[prg("sample", i)])
+		}
+	}
+	// tile 0 of the background, and the byte read through $2007
+	if log[0x4000] != cdl.Drawn || log[0x4010] != cdl.ChrRead {
+		t.Errorf("the CHR is $%02X and $%02X", log[0x4000], log[0x4010])
+	}
+}
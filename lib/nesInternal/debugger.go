@@ -0,0 +1,49 @@
+package nesInternal
+
+import (
+	"github.com/tiagolobocastro/gones/lib/cpu"
+	"github.com/tiagolobocastro/gones/lib/debugger"
+)
+
+// debugTarget exposes the nes to the debugger
+type debugTarget struct {
+	*nes
+}
+
+func (t *debugTarget) CPU() *cpu.Cpu {
+	return &t.nes.cpu
+}
+
+func (t *debugTarget) Peek(space debugger.Space, addr uint16) uint8 {
+	if space == debugger.PPU {
+		return (&ppuMapper{t.nes}).Peek8(addr % 0x4000)
+	}
+	return (&cpuMapper{t.nes}).Peek8(addr)
+}
+
+func (t *debugTarget) PpuPosition() (scanline, dot int) {
+	return t.nes.ppu.Position()
+}
+
+func (t *debugTarget) Frame() int {
+	return t.nes.ppu.Frame()
+}
+
+func (t *debugTarget) PrgFileOffset(addr uint16) int {
+	return t.nes.cart.PrgFileOffset(addr)
+}
+
+// hooks the debugger into the cpu and the buses, only when enabled as the
+// hooks slow down the emulation
+func (n *nes) initDebugger() {
+	if !n.debug {
+		return
+	}
+	n.debugger = debugger.New(&debugTarget{n})
+	n.debugger.SetSymbols(n.symbols)
+}
+
+// Debugger is nil unless the Debug option is set
+func (n *nes) Debugger() *debugger.Debugger {
+	return n.debugger
+}
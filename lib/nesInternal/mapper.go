@@ -1,8 +1,6 @@
 package nesInternal
 
-import (
-	"log"
-)
+import "github.com/tiagolobocastro/gones/lib/debugger"
 
 // CPU Mapping Table
 // Address range 	Size 	Device
@@ -15,11 +13,38 @@ import (
 // $4000-$4017 		$0018 	NES APU and I/O registers
 // $4018-$401F 		$0008 	APU and I/O functionality that is normally disabled. See CPU Test Mode.
 // $4020-$FFFF 		$BFE0 	Cartridge space: PRG ROM, PRG RAM, and mapper registers (See Note)
+//
+// Every access leaves its value on the data bus, reads from addresses which
+// nothing drives return that value (open bus), eg: the upper bits of $4016
 type cpuMapper struct {
 	*nes
 }
 
 func (m *cpuMapper) Read8(addr uint16) uint8 {
+	if m.nes.cdl != nil {
+		return m.cdlRead8(addr)
+	}
+	m.nes.openBus = m.read8(addr)
+	if m.nes.debugger != nil {
+		m.nes.debugger.Access(debugger.CPU, addr, m.nes.openBus, false)
+	}
+	return m.nes.openBus
+}
+
+// Read8 with the code/data logger, which needs to know what the mappers read
+func (m *cpuMapper) cdlRead8(addr uint16) uint8 {
+	m.nes.cdl.chrRead = addr >= 0x2000 && addr < 0x4000 && addr&7 == 7
+	m.nes.cart.ClearLastReads()
+	m.nes.openBus = m.read8(addr)
+	m.nes.cdl.chrRead = false
+	m.nes.cdlCpuRead(addr)
+	if m.nes.debugger != nil {
+		m.nes.debugger.Access(debugger.CPU, addr, m.nes.openBus, false)
+	}
+	return m.nes.openBus
+}
+
+func (m *cpuMapper) read8(addr uint16) uint8 {
 	switch {
 	case addr < 0x2000:
 		return m.nes.ram.Read8(addr % 2048)
@@ -28,25 +53,50 @@ func (m *cpuMapper) Read8(addr uint16) uint8 {
 		return m.nes.ppu.Read8(addr)
 
 	case addr == 0x4015:
-		return m.nes.apu.Read8(addr)
+		// bit 5 is not driven by the APU
+		return m.nes.apu.Read8(addr)&^0x20 | m.nes.openBus&0x20
 	case addr < 0x4016:
-		// read from APU and I-O
-		log.Panicf("read to address 0x%04x not implemented", addr)
+		// write only APU registers
+		return m.nes.openBus
 	case addr < 0x4018:
-		// Controller
-		return m.nes.ctrl.Read8(addr)
+		// Controller, only the lower bits are driven
+		return m.nes.ctrl.Read8(addr) | m.nes.openBus&0xE0
 	case addr < 0x4020:
-		log.Panicf("read to address 0x%04x not implemented", addr)
+		// CPU test mode registers, disabled on the NES
+		return m.nes.openBus
 	case addr < 0x6000:
-		// todo: not sure what these are
-		log.Panicf("read to address 0x%04x not implemented", addr)
+		// expansion area, only decoded by a few mappers
+		if m.nes.cart.DecodesExpansion() {
+			return m.nes.cart.Mapper.Read8(addr)
+		}
+		return m.nes.openBus
 	default:
 		return m.nes.cart.Mapper.Read8(addr)
 	}
-	return 0
+}
+
+// Peek8 reads the memory without side effects, the APU and the controller
+// registers are skipped
+func (m *cpuMapper) Peek8(addr uint16) uint8 {
+	switch {
+	case addr < 0x2000:
+		return m.nes.ram.Read8(addr % 2048)
+	case addr < 0x4000:
+		return m.nes.ppu.PeekRegister(addr)
+	case addr < 0x4020:
+		return m.nes.openBus
+	case addr < 0x6000 && !m.nes.cart.DecodesExpansion():
+		return m.nes.openBus
+	}
+	return m.nes.cart.Peek8(addr)
 }
 
 func (m *cpuMapper) Write8(addr uint16, val uint8) {
+	m.nes.openBus = val
+	if m.nes.debugger != nil {
+		m.nes.debugger.Access(debugger.CPU, addr, val, true)
+	}
+
 	switch {
 	case addr < 0x2000:
 		m.nes.ram.Write8(addr%2048, val)
@@ -63,12 +113,11 @@ func (m *cpuMapper) Write8(addr uint16, val uint8) {
 	case addr < 0x4018:
 		m.nes.ctrl.Write8(addr, val)
 
-	case addr == 0x4025:
-		// FDS not implemented
-
 	case addr < 0x6000:
-		// todo: not sure what these are
-		log.Printf("write to address 0x%04x not implemented", addr)
+		// expansion area, only decoded by a few mappers
+		if m.nes.cart.DecodesExpansion() {
+			m.nes.cart.Mapper.Write8(addr, val)
+		}
 	default:
 		m.nes.cart.Mapper.Write8(addr, val)
 	}
@@ -122,15 +171,29 @@ type ppuMapper struct {
 }
 
 func (m *ppuMapper) Read8(addr uint16) uint8 {
+	if m.nes.cdl != nil {
+		m.nes.cart.ClearLastReads()
+	}
+	val := m.read8(addr)
+	if m.nes.cdl != nil {
+		m.nes.cdlPpuRead()
+	}
+	if m.nes.debugger != nil {
+		m.nes.debugger.Access(debugger.PPU, addr, val, false)
+	}
+	return val
+}
+
+func (m *ppuMapper) read8(addr uint16) uint8 {
 	switch {
 	// PPU VRAM or controlled via the Cartridge Mapper
 	case addr < 0x2000:
 		return m.nes.cart.Mapper.Read8(addr)
 	// normally mapped to the internal vRAM but it can be remapped!
 	case addr < 0x3000:
-		return m.nes.cart.Tables.Read8(addr)
+		return m.nes.cart.ReadTable(addr)
 	case addr < 0x3F00:
-		return m.nes.cart.Tables.Read8(addr - 0x1000)
+		return m.nes.cart.ReadTable(addr - 0x1000)
 
 	// internal palette control - not configurable
 	case addr < 0x4000:
@@ -139,15 +202,27 @@ func (m *ppuMapper) Read8(addr uint16) uint8 {
 	return 0
 }
 
+// Peek8 reads the memory without side effects, eg: the CHR latches
+func (m *ppuMapper) Peek8(addr uint16) uint8 {
+	if addr < 0x2000 {
+		return m.nes.cart.Peek8(addr)
+	}
+	return m.read8(addr)
+}
+
 func (m *ppuMapper) Write8(addr uint16, val uint8) {
+	if m.nes.debugger != nil {
+		m.nes.debugger.Access(debugger.PPU, addr, val, true)
+	}
+
 	switch {
 	// PPU VRAM or controlled via the Cartridge Mapper
 	case addr < 0x2000:
 		m.nes.cart.Mapper.Write8(addr, val)
 	case addr < 0x3000:
-		m.nes.cart.Tables.Write8(addr, val)
+		m.nes.cart.WriteTable(addr, val)
 	case addr < 0x3F00:
-		m.nes.cart.Tables.Write8(addr-0x1000, val)
+		m.nes.cart.WriteTable(addr-0x1000, val)
 
 	// internal palette control
 	case addr < 0x4000:
@@ -162,6 +237,13 @@ type apuMapper struct {
 }
 
 func (a *apuMapper) Read8(addr uint16) uint8 {
-	return a.cpu.Read8(addr)
+	if a.nes.cdl == nil {
+		return a.cpu.Read8(addr)
+	}
+	// the DMC sample fetches
+	a.nes.cdl.sample = true
+	val := a.cpu.Read8(addr)
+	a.nes.cdl.sample = false
+	return val
 }
 func (a *apuMapper) Write8(uint16, uint8) {}
@@ -0,0 +1,158 @@
+package nesInternal
+
+import (
+	"github.com/tiagolobocastro/gones/lib/mappers"
+)
+
+// MemorySpace is one of the address spaces of the Memory
+type MemorySpace int
+
+const (
+	// the cpu and the ppu buses, as the cpu and the ppu see them
+	MemoryCPU MemorySpace = iota
+	MemoryPPU
+	// the primary OAM and the palette RAM indexes
+	MemoryOAM
+	MemoryPalette
+	// the cartridge memories by physical offset, whichever bank is mapped
+	MemoryPrgRom
+	MemoryPrgRam
+	// the CHR-ROM, or the CHR-RAM
+	MemoryChr
+)
+
+func (s MemorySpace) String() string {
+	return [...]string{"CPU", "PPU", "OAM", "Palette", "PRG-ROM", "PRG-RAM", "CHR"}[s]
+}
+
+// Memory peeks and pokes every address space without the side effects of the
+// cpu and the ppu accesses, eg: peeking $2002 leaves the vblank flag set and
+// peeking the CHR leaves the MMC2 latches alone. It's meant for the tools
+// watching the memory, eg: RAM watches, cheat finders or bots
+type Memory struct {
+	nes *nes
+}
+
+// Memory of the nes, the accesses are not synchronised with the emulation
+func (n *nes) Memory() *Memory {
+	return &Memory{nes: n}
+}
+
+// Size of a space, its addresses start at 0
+func (m *Memory) Size(space MemorySpace) int {
+	switch space {
+	case MemoryCPU:
+		return 0x10000
+	case MemoryPPU:
+		return 0x4000
+	case MemoryOAM:
+		return 256
+	case MemoryPalette:
+		return 32
+	case MemoryPrgRom:
+		return m.nes.cart.PrgRomSize()
+	case MemoryPrgRam:
+		return m.nes.cart.PrgRamSize()
+	case MemoryChr:
+		return m.nes.cart.ChrSize()
+	}
+	return 0
+}
+
+// Peek reads a byte, the addresses out of the space read 0. The cpu registers
+// which can't be peeked, eg: the controllers, read the open bus
+func (m *Memory) Peek(space MemorySpace, addr int) uint8 {
+	if addr < 0 || addr >= m.Size(space) {
+		return 0
+	}
+	switch space {
+	case MemoryCPU:
+		return (&cpuMapper{m.nes}).Peek8(uint16(addr))
+	case MemoryPPU:
+		return (&ppuMapper{m.nes}).Peek8(uint16(addr))
+	case MemoryOAM:
+		return m.nes.ppu.PeekOAM(uint8(addr))
+	case MemoryPalette:
+		return m.nes.ppu.Palette.Read8(uint16(addr))
+	case MemoryPrgRom:
+		return m.nes.cart.PeekPrgRom(addr)
+	case MemoryPrgRam:
+		return m.nes.cart.PeekPrgRam(addr)
+	case MemoryChr:
+		return m.nes.cart.PeekChr(addr)
+	}
+	return 0
+}
+
+// PeekRange reads n bytes from addr
+func (m *Memory) PeekRange(space MemorySpace, addr int, n int) []uint8 {
+	vals := make([]uint8, n)
+	for i := range vals {
+		vals[i] = m.Peek(space, addr+i)
+	}
+	return vals
+}
+
+// Poke writes a byte, the addresses out of the space are not written. In the
+// cpu space the registers are not written either, even when the mapper has
+// some at $6000-$7FFF, and the PRG-RAM and the PRG-ROM are written where the
+// mapper maps them, regardless of any write protection. In the ppu space the
+// CHR-ROM is patched too
+func (m *Memory) Poke(space MemorySpace, addr int, val uint8) {
+	if addr < 0 || addr >= m.Size(space) {
+		return
+	}
+	switch space {
+	case MemoryCPU:
+		m.pokeCpu(uint16(addr), val)
+	case MemoryPPU:
+		m.pokePpu(uint16(addr), val)
+	case MemoryOAM:
+		m.nes.ppu.PokeOAM(uint8(addr), val)
+	case MemoryPalette:
+		m.nes.ppu.Palette.Write8(uint16(addr), val)
+	case MemoryPrgRom:
+		m.nes.cart.PokePrgRom(addr, val)
+	case MemoryPrgRam:
+		m.nes.cart.PokePrgRam(addr, val)
+	case MemoryChr:
+		m.nes.cart.PokeChr(addr, val)
+	}
+}
+
+func (m *Memory) pokeCpu(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		m.nes.ram.Write8(addr%2048, val)
+	case addr < 0x6000:
+		// the registers
+	case addr < 0x8000:
+		if offset := m.nes.cart.PrgRamOffset(addr); offset >= 0 {
+			m.nes.cart.PokePrgRam(offset, val)
+		}
+	default:
+		if offset := m.nes.cart.PrgOffset(addr); offset >= 0 {
+			m.nes.cart.PokePrgRom(offset, val)
+		}
+	}
+}
+
+func (m *Memory) pokePpu(addr uint16, val uint8) {
+	switch {
+	case addr < 0x2000:
+		if offset := m.nes.cart.ChrOffset(addr); offset >= 0 {
+			m.nes.cart.PokeChr(offset, val)
+		}
+	case addr < 0x3000:
+		m.nes.cart.WriteTable(addr, val)
+	case addr < 0x3F00:
+		m.nes.cart.WriteTable(addr-0x1000, val)
+	default:
+		m.nes.ppu.Palette.Write8(addr%32, val)
+	}
+}
+
+// MapperRegisters lists the registers of the mapper, which can only be peeked
+func (m *Memory) MapperRegisters() []mappers.MapperRegister {
+	return m.nes.cart.MapperRegisters()
+}
@@ -0,0 +1,106 @@
+package nesInternal
+
+import "testing"
+
+const memoryTestCode = `
+        .org $E000
+reset:  sei
+        lda #$00
+        sta $2000
+        sta $2001
+loop:   jmp loop
+
+        .org $FFFA
+        .word reset, reset, reset
+`
+
+// a rom with 32 KB of PRG-ROM and 8 KB of CHR-ROM, the mapper and the
+// PRG-RAM are picked by the header flags 6, 7 and 8
+func writeMemoryTestRom(t *testing.T, flags6, flags7, flags8 uint8) string {
+	header := []byte{'N', 'E', 'S', 0x1A, 2, 1, flags6, flags7, flags8, 0, 0, 0, 0, 0, 0, 0}
+	chr := make([]byte, 0x1000)
+	chr[0x0FE8] = 0x42
+	rom, _ := writeTestRom(t, header, memoryTestCode, chr)
+	return rom
+}
+
+func mapperRegister(t *testing.T, m *Memory, name string) int {
+	for _, r := range m.MapperRegisters() {
+		if r.Name == name {
+			return r.Value
+		}
+	}
+	t.Fatalf("no mapper register %s", name)
+	return 0
+}
+
+func Test_Memory(t *testing.T) {
+	// MMC2 with 8 KB of PRG-RAM
+	nes := newNES(CartPath(writeMemoryTestRom(t, 0x90, 0, 1)), Headless(true))
+	mem := nes.Memory()
+
+	// the MMC2 latch only flips on the ppu reads
+	if val := mem.Peek(MemoryPPU, 0x0FE8); val != 0x42 {
+		t.Errorf("peeked $%02X from the CHR", val)
+	}
+	if latch := mapperRegister(t, mem, "latch[0]"); latch != 0xFD {
+		t.Errorf("the peek flipped the latch to $%02X", latch)
+	}
+	(&ppuMapper{nes}).Read8(0x0FE8)
+	if latch := mapperRegister(t, mem, "latch[0]"); latch != 0xFE {
+		t.Errorf("the read left the latch at $%02X", latch)
+	}
+
+	// the vblank flag is only cleared by the cpu reads
+	for i := 0; mem.Peek(MemoryCPU, 0x2002)&0x80 == 0; i++ {
+		if i == 100000 {
+			t.Fatal("no vblank")
+		}
+		nes.cpu.Tick()
+	}
+	if mem.Peek(MemoryCPU, 0x2002)&0x80 == 0 {
+		t.Error("the peek cleared the vblank flag")
+	}
+	(&cpuMapper{nes}).Read8(0x2002)
+	if mem.Peek(MemoryCPU, 0x2002)&0x80 != 0 {
+		t.Error("the read left the vblank flag set")
+	}
+
+	mem.Poke(MemoryCPU, 0x0010, 0x99)
+	if val := mem.Peek(MemoryCPU, 0x0810); val != 0x99 {
+		t.Errorf("peeked $%02X from the RAM mirror", val)
+	}
+	mem.Poke(MemoryCPU, 0xFFF0, 0x77)
+	if val := mem.Peek(MemoryPrgRom, 0x7FF0); val != 0x77 {
+		t.Errorf("peeked $%02X from the patched PRG-ROM", val)
+	}
+	mem.Poke(MemoryCPU, 0x6010, 0x66)
+	if val := mem.Peek(MemoryPrgRam, 0x0010); val != 0x66 {
+		t.Errorf("peeked $%02X from the PRG-RAM", val)
+	}
+	mem.Poke(MemoryOAM, 4, 0x55)
+	if vals := mem.PeekRange(MemoryOAM, 3, 2); vals[1] != 0x55 {
+		t.Errorf("peeked %v from the OAM", vals)
+	}
+	if val := mem.Peek(MemoryChr, mem.Size(MemoryChr)); val != 0 {
+		t.Errorf("peeked $%02X out of the CHR", val)
+	}
+}
+
+// the Bandai FCG-1/2 registers at $6000-$7FFF are not poked
+func Test_MemoryPokeRegisters(t *testing.T) {
+	nes := newNES(CartPath(writeMemoryTestRom(t, 0, 0x10, 0)), Headless(true))
+	mem := nes.Memory()
+	for addr := 0x6000; addr < 0x6010; addr++ {
+		mem.Poke(MemoryCPU, addr, 0x0F)
+	}
+	for _, r := range mem.MapperRegisters() {
+		if (r.Name == "prgReg" || r.Name == "chrRegs[0]" || r.Name == "irqLatch") && r.Value != 0 {
+			t.Errorf("poked the register %s to $%02X", r.Name, r.Value)
+		}
+	}
+	(&cpuMapper{nes}).Write8(0x6008, 0x0F)
+	if mapperRegister(t, mem, "prgReg") != 0x0F {
+		t.Error("the registers are not decoded at $6000-$7FFF")
+	}
+}
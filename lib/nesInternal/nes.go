@@ -1,18 +1,23 @@
 package nesInternal
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/tiagolobocastro/gones/lib/apu"
+	"github.com/tiagolobocastro/gones/lib/asm"
 	"github.com/tiagolobocastro/gones/lib/common"
 	"github.com/tiagolobocastro/gones/lib/cpu"
+	"github.com/tiagolobocastro/gones/lib/debugger"
 	"github.com/tiagolobocastro/gones/lib/mappers"
 	"github.com/tiagolobocastro/gones/lib/ppu"
 	"github.com/tiagolobocastro/gones/lib/speakers"
+	"github.com/tiagolobocastro/gones/lib/symbols"
 	"github.com/tiagolobocastro/gones/lib/ui"
 )
 
@@ -33,6 +38,9 @@ func (g *GoNes) Nes() *nes {
 func (n *nes) Stop() {
 	n.cart.Stop()
 	n.apu.Stop()
+	n.saveCdl()
+	n.saveProfile()
+	n.closeTracer()
 }
 
 func (n *nes) Request(request common.NesOpRequest) {
@@ -63,7 +71,9 @@ func (n *nes) Poke(controllerId uint8, button uint8, pressed bool) {
 }
 
 func (n *nes) Run() {
-	n.screen.Run()
+	if !n.headless {
+		n.screen.Run()
+	}
 	if n.freeRun == true {
 		n.runFree()
 	} else {
@@ -93,6 +103,25 @@ func (n *nes) PPU() *ppu.Ppu {
 func (n *nes) CPU() *cpu.Cpu {
 	return &n.cpu
 }
+func (n *nes) OpenBus() uint8 {
+	return n.openBus
+}
+
+// the verbose logs and the cpu trace go to log.log
+func (n *nes) initLog() io.Writer {
+	if !n.verbose {
+		// set log to stdout just in case we change it during debugging
+		log.SetOutput(os.Stdout)
+		return nil
+	}
+
+	f, err := os.OpenFile("log.log", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		log.Fatalf("error opening file: %v", err)
+	}
+	log.SetOutput(f)
+	return f
+}
 
 func (n *nes) init() {
 	n.bus.Init()
@@ -105,8 +134,28 @@ func (n *nes) init() {
 
 	n.ctrl.Init()
 	n.screen.Init(n)
+	if n.headless {
+		// nobody waits for the frames
+		n.screen.Framebuffer.FrameUpdated = nil
+	}
 
-	n.cpu.Init(n.bus.GetBusInt(MapCPUId), n.verbose)
+	logFile := n.initLog()
+
+	n.initSymbols()
+	n.cpu.Init(n.bus.GetBusInt(MapCPUId), n, cpu.Variant2A03, n.verbose)
+	if logFile != nil {
+		n.cpu.Trace(logFile, (&cpuMapper{n}).Peek8, n.ppu.Position)
+		if n.symbols != nil {
+			n.cpu.TraceLabels(n.label)
+		}
+	}
+	n.initTracer()
+	n.initDebugger()
+	n.initCdl()
+	n.initProfiler()
+	if n.debugger != nil || n.cdl != nil {
+		n.cpu.OnExec(n.exec)
+	}
 	n.ppu.Init(n.bus.GetBusInt(MapPPUId), &n.cpu, n.verbose, &n.screen.Framebuffer, n.spriteLimit)
 	n.dma.Init(n.bus.GetBusInt(MapDMAId))
 	n.apu.Init(n.bus.GetBusInt(MapAPUId), &n.cpu, n.verbose, n.audioLog, n.audioLib)
@@ -115,17 +164,41 @@ func (n *nes) init() {
 	n.bus.Connect(MapPPUId, &ppuMapper{n})
 	n.bus.Connect(MapDMAId, &dmaMapper{n})
 	n.bus.Connect(MapAPUId, &apuMapper{n})
+	n.connectCart()
 
 	n.cpu.Reset()
 }
 
+// the cpu hook, only set when debugging or logging as it slows down the
+// emulation
+func (n *nes) exec(pc uint16) {
+	if n.cdl != nil {
+		n.cdlExec(pc)
+	}
+	if n.debugger != nil {
+		n.debugger.Exec(pc)
+	}
+}
+
+// wires up the optional cartridge features, which need redoing every time
+// the cartridge mapper is recreated
+func (n *nes) connectCart() {
+	if audio, ok := n.cart.Mapper.(apu.ExpansionAudio); ok {
+		n.apu.SetExpansionAudio(audio)
+	} else {
+		n.apu.SetExpansionAudio(nil)
+	}
+}
+
+// the reset button, the cartridge keeps its contents and only the mappers
+// with the reset line wired see it, before the cpu fetches the reset vector
 func (n *nes) reset() {
+	n.cart.SoftReset()
 	n.ppu.Reset()
 	n.dma.Reset()
 	n.cpu.Reset()
 	n.apu.Reset()
 	n.ctrl.Reset()
-	n.cart.Reset()
 
 	n.opRequests &= ^(1 << common.ResetRequest)
 }
@@ -142,6 +215,8 @@ func (n *nes) load() {
 	// we need to reset the nest because otherwise the gob encoder
 	// does a gob out: https://github.com/golang/go/issues/21929
 	n.reset()
+	n.cart.Reset()
+	n.connectCart()
 	if err := n.DeSerialise(common.NewSerialiser(n.cart.GetStateSaveFile())); err != nil {
 		log.Printf("Failed to Load State: %v", err)
 	}
@@ -172,29 +247,34 @@ func (n *nes) Step(seconds float64) {
 	runCycles := int(cyclesPerSecond)
 
 	for runCycles > 0 {
+		// the cpu clocks the rest of the system on each of its cycles
+		runCycles -= n.cpu.Tick()
+	}
 
-		ticks := 1
-		if !n.dma.Active() {
-			// cpu stalled whilst dma is active
-			ticks = n.cpu.Tick()
-		}
-
-		// 3 ppu ticks per 1 cpu
-		for i := 0; i < 3*ticks; i++ {
-			n.ppu.Ticks(1)
-			n.cart.Ticks(1)
-		}
-
-		n.dma.Ticks(ticks)
+	n.processOpRequest()
+}
 
-		// since we are more sensitive to sound
-		// so we might have to replace the cpu as the "tick master"
-		n.apu.Ticks(ticks)
+// CpuCycle is called by the cpu right before each of its bus accesses
+func (n *nes) CpuCycle(read bool) {
+	// the OAM DMA halts the cpu on its next read, until the transfer is done
+	for read && n.dma.Active() {
+		n.cycle()
+		n.dma.Ticks(1)
+	}
+	n.cycle()
+}
 
-		runCycles -= ticks
+// runs everything but the cpu for one cpu cycle
+func (n *nes) cycle() {
+	// 3 ppu ticks per 1 cpu
+	for i := 0; i < 3; i++ {
+		n.ppu.Ticks(1)
+		n.cart.Ticks(1)
 	}
 
-	n.processOpRequest()
+	n.dma.Cycle()
+	n.cart.CpuTicks(1)
+	n.apu.Ticks(1)
 }
 
 func (n *nes) processOpRequest() {
@@ -208,21 +288,16 @@ func (n *nes) processOpRequest() {
 	}
 }
 
+// runs the easy6502 test programs, which end on a BRK. The BRK is skipped
+// instead of going through the IRQ vector
 func (n *nes) Test() {
 	for {
-		ticks := 1
-		if !n.dma.Active() {
-			// cpu stalled whilst dma is active
-			ticks = n.cpu.Tick()
-		}
-
-		if ticks == 0 {
+		pc := n.cpu.Rg.Spc.Pc.Read()
+		if n.cpu.Read8(pc) == 0x00 {
+			n.cpu.Rg.Spc.Pc.Write(pc + 1)
 			return
 		}
-
-		// 3 ppu ticks per 1 cpu
-		n.ppu.Ticks(3 * ticks)
-		n.dma.Ticks(ticks)
+		n.cpu.Tick()
 	}
 }
 
@@ -271,6 +346,22 @@ func (n *nes) loadEasyCode(code string) {
 	}
 }
 
+// assembles the source from $0600, like easy6502, unless it has an .org
+func (n *nes) loadAsm(src string) error {
+	prog, err := asm.Assemble(cpu.Variant2A03, 0x0600, src)
+	if err != nil {
+		return err
+	}
+	if len(prog.Segments) == 0 {
+		return fmt.Errorf("no code to load")
+	}
+
+	// the first segment is where the program starts
+	n.cart.WriteRom16(0xFFFC, prog.Segments[0].Org)
+	prog.Load(n.cpu.Write8)
+	return nil
+}
+
 type nes struct {
 	bus common.Bus
 
@@ -281,18 +372,40 @@ type nes struct {
 	dma  common.Dma
 	apu  apu.Apu
 	ctrl common.Controllers
+	// last value read or written on the cpu data bus
+	openBus uint8
 
 	screen ui.Screen
 
+	// nil unless debugging
+	debugger *debugger.Debugger
+	// nil without symbol files next to the rom
+	symbols *symbols.DebugInfo
+	// nil unless logging, see cdl.go
+	cdl *cdlHooks
+	// nil unless profiling, see profiler.go
+	profiler *cpu.Profiler
+	// the frame count when the profiler started
+	profileFrames int
+	// nil unless tracing, see tracer.go
+	tracer      *cpu.Tracer
+	traceFile   *os.File
+	traceWriter *bufio.Writer
+
 	opRequests common.NesOpRequest
 
 	// Options
-	verbose     bool
-	cartPath    string
-	freeRun     bool
-	audioLib    speakers.AudioLib
-	audioLog    bool
-	spriteLimit bool
+	verbose      bool
+	cartPath     string
+	freeRun      bool
+	audioLib     speakers.AudioLib
+	audioLog     bool
+	spriteLimit  bool
+	headless     bool
+	debug        bool
+	cdlPath      string
+	profilePath  string
+	traceOptions TraceOptions
 }
 
 const (
@@ -29,6 +29,29 @@ func (g *GoNes) SetSpriteLimit(limit bool) error {
 	g.nes.spriteLimit = limit
 	return nil
 }
+func (g *GoNes) SetHeadless(headless bool) error {
+	g.nes.headless = headless
+	return nil
+}
+func (g *GoNes) SetDebug(debug bool) error {
+	g.nes.debug = debug
+	return nil
+}
+
+func (g *GoNes) SetCodeDataLog(path string) error {
+	g.nes.cdlPath = path
+	return nil
+}
+
+func (g *GoNes) SetProfile(path string) error {
+	g.nes.profilePath = path
+	return nil
+}
+
+func (g *GoNes) SetTrace(options TraceOptions) error {
+	g.nes.traceOptions = options
+	return nil
+}
 
 func (g *GoNes) SetOptions(options ...func(*GoNes) error) error {
 	for i, option := range options {
@@ -74,3 +97,33 @@ func SpriteLimit(limit bool) func(n *GoNes) error {
 		return n.SetSpriteLimit(limit)
 	}
 }
+
+func Headless(headless bool) func(n *GoNes) error {
+	return func(n *GoNes) error {
+		return n.SetHeadless(headless)
+	}
+}
+
+func Debug(debug bool) func(n *GoNes) error {
+	return func(n *GoNes) error {
+		return n.SetDebug(debug)
+	}
+}
+
+func CodeDataLog(path string) func(n *GoNes) error {
+	return func(n *GoNes) error {
+		return n.SetCodeDataLog(path)
+	}
+}
+
+func Profile(path string) func(n *GoNes) error {
+	return func(n *GoNes) error {
+		return n.SetProfile(path)
+	}
+}
+
+func Trace(options TraceOptions) func(n *GoNes) error {
+	return func(n *GoNes) error {
+		return n.SetTrace(options)
+	}
+}
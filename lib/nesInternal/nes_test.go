@@ -1,7 +1,10 @@
 package nesInternal
 
 import (
+	"github.com/tiagolobocastro/gones/lib/asm"
 	"github.com/tiagolobocastro/gones/lib/cpu"
+	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -18,6 +21,34 @@ func newNES(options ...func(n *GoNes) error) *nes {
 	return nes.nes
 }
 
+// writes a rom with the iNES header and src assembled at the end of the
+// PRG-ROM, the CHR-ROM is blank but for the chr bytes at its start. The
+// sizes are the ones of the header. The battery saves of the test go to a
+// temporary HOME
+func writeTestRom(t *testing.T, header []byte, src string, chr []byte) (string, *asm.Program) {
+	t.Setenv("HOME", t.TempDir())
+	prog, err := asm.Assemble(cpu.Variant2A03, 0, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prg := make([]byte, int(header[4])*0x4000)
+	base := 0x10000 - len(prg)
+	prog.Load(func(addr uint16, val uint8) {
+		if int(addr) < base {
+			t.Fatalf("$%04X is not in the PRG-ROM", addr)
+		}
+		prg[int(addr)-base] = val
+	})
+	data := append(append(header, prg...), make([]byte, int(header[5])*0x2000)...)
+	copy(data[len(header)+len(prg):], chr)
+
+	rom := filepath.Join(t.TempDir(), "test.nes")
+	if err := ioutil.WriteFile(rom, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return rom, prog
+}
+
 func Test_newNES(t *testing.T) {
 	nes := newNES(Verbose(false))
 	if nes == nil {
@@ -29,6 +60,7 @@ type cpuTest struct {
 	prefix  func()
 	name    string
 	code    string
+	asm     string
 	result  string
 	postfix func()
 }
@@ -41,7 +73,13 @@ func cmpMem(nes *nes, t *testing.T, checkAddr uint16, expectedVal uint8) {
 }
 
 func testCpuTest(nes *nes, t *testing.T, cpuTest cpuTest) {
-	nes.loadEasyCode(cpuTest.code)
+	if cpuTest.asm != "" {
+		if err := nes.loadAsm(cpuTest.asm); err != nil {
+			t.Fatalf("[%s][%s) %v", t.Name(), cpuTest.name, err)
+		}
+	} else {
+		nes.loadEasyCode(cpuTest.code)
+	}
 	nes.reset()
 
 	if cpuTest.prefix != nil {
@@ -203,6 +241,81 @@ func Test_JMP(t *testing.T) {
 	testCpuTest(nes, t, bmi)
 	var jsrRts = cpuTest{code: "0600: 20 04 06 00 a9 11 60", result: "Pc: 0x0604, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x11, X: 0x00, Y: 0x00"}
 	testCpuTest(nes, t, jsrRts)
+
+	// the same programs, assembled
+	tests := []cpuTest{
+		{name: "jmpABS", asm: `
+			lda #1
+			jmp end
+			lda #$22
+		end:
+			brk`, result: "Pc: 0x0608, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x01, X: 0x00, Y: 0x00"},
+		{name: "jmpIND", asm: `
+			lda #<target
+			sta a:$f0
+			lda #>target
+			sta a:$f1
+			jmp ($00f0)
+			brk
+		target:
+			lda #$22
+			brk`, result: "Pc: 0x0611, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x22, X: 0x00, Y: 0x00"},
+		// the pointer wraps inside its page and the high byte is read from $0100
+		{name: "jmpINDBug", asm: `
+			lda #<target
+			sta $01ff
+			lda #>target
+			sta $0100
+			jmp ($01ff)
+			brk
+		target:
+			lda #$22
+			brk`, result: "Pc: 0x0611, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x22, X: 0x00, Y: 0x00"},
+		{name: "bpl", asm: `
+			lda #$81
+			bpl taken
+			lda #$22
+			brk
+		taken:
+			lda #$33
+			brk`, result: "Pc: 0x0607, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x22, X: 0x00, Y: 0x00"},
+		{name: "bplFw", asm: `
+			lda #$51
+			bpl taken
+			lda #$22
+			brk
+		taken:
+			lda #$33
+			brk`, result: "Pc: 0x060a, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x33, X: 0x00, Y: 0x00"},
+		{name: "bplBw", asm: `
+			jmp start
+		taken:
+			lda #$33
+			brk
+		start:
+			lda #$51
+			bpl taken
+			lda #$44
+			brk`, result: "Pc: 0x0606, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x33, X: 0x00, Y: 0x00"},
+		{name: "bmi", asm: `
+			lda #$51
+			bmi taken
+			lda #$22
+			brk
+		taken:
+			lda #$33
+			brk`, result: "Pc: 0x0607, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x22, X: 0x00, Y: 0x00"},
+		{name: "jsrRts", asm: `
+			jsr sub
+			brk
+		sub:
+			lda #$11
+			rts`, result: "Pc: 0x0604, Sp: 0xff, Ps: 0x34 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x11, X: 0x00, Y: 0x00"},
+	}
+
+	for _, test := range tests {
+		testCpuTest(nes, t, test)
+	}
 }
 
 func Test_LA(t *testing.T) {
@@ -220,6 +333,16 @@ func Test_LA(t *testing.T) {
 		{name: "cmpIMM2", code: "0600: a9 03 c9 03 00", result: "Pc: 0x0605, Sp: 0xff, Ps: 0x37 (N:0 V:0 E:1 B:1 D:0 I:1 Z:1 C:1), Ac: 0x03, X: 0x00, Y: 0x00"},
 		{name: "cmpIMM3", code: "0600: a9 03 c9 01 00", result: "Pc: 0x0605, Sp: 0xff, Ps: 0x35 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:1), Ac: 0x03, X: 0x00, Y: 0x00"},
 		{name: "cmpIMM4", code: "0600: a9 85 c9 01 00", result: "Pc: 0x0605, Sp: 0xff, Ps: 0xb5 (N:1 V:0 E:1 B:1 D:0 I:1 Z:0 C:1), Ac: 0x85, X: 0x00, Y: 0x00"},
+
+		// the same programs, assembled
+		{name: "sbcIMMAsm", asm: "clc\nlda #$fe\nsbc #$7e\nbrk", result: "Pc: 0x0606, Sp: 0xff, Ps: 0x75 (N:0 V:1 E:1 B:1 D:0 I:1 Z:0 C:1), Ac: 0x7f, X: 0x00, Y: 0x00"},
+		{name: "sbcIMM2Asm", asm: "clc\nlda #$fe\nsbc #$7d\nbrk", result: "Pc: 0x0606, Sp: 0xff, Ps: 0xb5 (N:1 V:0 E:1 B:1 D:0 I:1 Z:0 C:1), Ac: 0x80, X: 0x00, Y: 0x00"},
+		{name: "sbcIMM3Asm", asm: "lda #$fe\nsbc #$7e\nbrk", result: "Pc: 0x0605, Sp: 0xff, Ps: 0x75 (N:0 V:1 E:1 B:1 D:0 I:1 Z:0 C:1), Ac: 0x7f, X: 0x00, Y: 0x00"},
+
+		{name: "cmpIMMAsm", asm: "lda #3\ncmp #5\nbrk", result: "Pc: 0x0605, Sp: 0xff, Ps: 0xb4 (N:1 V:0 E:1 B:1 D:0 I:1 Z:0 C:0), Ac: 0x03, X: 0x00, Y: 0x00"},
+		{name: "cmpIMM2Asm", asm: "lda #3\ncmp #3\nbrk", result: "Pc: 0x0605, Sp: 0xff, Ps: 0x37 (N:0 V:0 E:1 B:1 D:0 I:1 Z:1 C:1), Ac: 0x03, X: 0x00, Y: 0x00"},
+		{name: "cmpIMM3Asm", asm: "lda #3\ncmp #1\nbrk", result: "Pc: 0x0605, Sp: 0xff, Ps: 0x35 (N:0 V:0 E:1 B:1 D:0 I:1 Z:0 C:1), Ac: 0x03, X: 0x00, Y: 0x00"},
+		{name: "cmpIMM4Asm", asm: "lda #$85\ncmp #1\nbrk", result: "Pc: 0x0605, Sp: 0xff, Ps: 0xb5 (N:1 V:0 E:1 B:1 D:0 I:1 Z:0 C:1), Ac: 0x85, X: 0x00, Y: 0x00"},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,69 @@
+package nesInternal
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The test roms are not part of the repo, point GONES_TEST_ROMS at a folder
+// with them or copy them to testdata
+const testRomsEnv = "GONES_TEST_ROMS"
+
+func testRom(t *testing.T, name string) string {
+	dir := os.Getenv(testRomsEnv)
+	if dir == "" {
+		dir = "testdata"
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		t.Skipf("%s not found, set %s", path, testRomsEnv)
+	}
+	return path
+}
+
+// nestest.nes in automation mode, starting at $C000 without the PPU, traced
+// against the Nintendulator log from https://www.qmtpro.com/~nes/misc/
+func Test_Nestest(t *testing.T) {
+	rom := testRom(t, "nestest.nes")
+	golden, err := os.Open(testRom(t, "nestest.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer golden.Close()
+
+	nes := newNES(CartPath(rom), Verbose(false))
+
+	var trace bytes.Buffer
+	nes.cpu.Trace(&trace, (&cpuMapper{nes}).Peek8, nes.ppu.Position)
+
+	// the log starts at the top of the frame, after the 7 reset cycles
+	nes.ppu.Ticks(341)
+	nes.cpu.Rg.Spc.Pc.Write(0xC000)
+	nes.cpu.Rg.Spc.Sp.Write(0xFD)
+	nes.cpu.Rg.Spc.Ps.Write(0x24)
+	nes.cpu.Stall(7)
+
+	scanner := bufio.NewScanner(golden)
+	prev := ""
+	for line := 1; scanner.Scan(); line++ {
+		expected := strings.TrimRight(scanner.Text(), "\r\n ")
+
+		trace.Reset()
+		nes.cpu.Tick()
+		got := strings.TrimRight(trace.String(), "\n")
+
+		if got != expected {
+			t.Fatalf("nestest.log:%d mismatch\n  %s\n- %s\n+ %s", line, prev, expected, got)
+		}
+		prev = expected
+	}
+
+	// the official and unofficial opcode tests leave their error codes here
+	if r := nes.ram.Read8(0x02) | nes.ram.Read8(0x03); r != 0 {
+		t.Errorf("nestest failed with 0x%02x%02x", nes.ram.Read8(0x02), nes.ram.Read8(0x03))
+	}
+}
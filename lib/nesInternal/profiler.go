@@ -0,0 +1,65 @@
+package nesInternal
+
+import (
+	"log"
+	"os"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// the routines in the report printed on Stop
+const profileReportTop = 20
+
+// the profiler starts right away, the routines are named by the symbols
+func (n *nes) initProfiler() {
+	n.profiler = nil
+	if n.profilePath == "" {
+		return
+	}
+	var labels func(addr uint16) (string, bool)
+	if n.symbols != nil {
+		labels = n.label
+	}
+	n.profiler = cpu.NewProfiler(n.prgBank, labels)
+	n.profileFrames = n.screen.Framebuffer.Frames
+	n.cpu.Profile(n.profiler)
+}
+
+// the 8 KB PRG-ROM bank mapped at a cpu address, or -1
+func (n *nes) prgBank(addr uint16) int {
+	offset := n.cart.PrgOffset(addr)
+	if offset < 0 {
+		return -1
+	}
+	return offset >> 13
+}
+
+// Profiler is nil unless the Profile option is set
+func (n *nes) Profiler() *cpu.Profiler {
+	return n.profiler
+}
+
+// ProfiledFrames returns the number of frames run since the profiler started
+func (n *nes) ProfiledFrames() int {
+	return n.screen.Framebuffer.Frames - n.profileFrames
+}
+
+// prints the report and writes the pprof profile
+func (n *nes) saveProfile() {
+	if n.profiler == nil {
+		return
+	}
+	if err := n.profiler.Report(os.Stdout, profileReportTop, n.ProfiledFrames()); err != nil {
+		log.Printf("Failed to print the profile, err=%v", err)
+	}
+	f, err := os.Create(n.profilePath)
+	if err == nil {
+		err = n.profiler.WriteProfile(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to save the profile, err=%v", err)
+	}
+}
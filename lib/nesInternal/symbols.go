@@ -0,0 +1,34 @@
+package nesInternal
+
+import (
+	"log"
+
+	"github.com/tiagolobocastro/gones/lib/symbols"
+)
+
+// loads the symbol files next to the rom, the bad ones are only logged
+func (n *nes) initSymbols() {
+	n.symbols = nil
+	if n.cartPath == "" {
+		return
+	}
+	info, err := symbols.Load(n.cartPath)
+	if err != nil {
+		log.Printf("Failed to load the symbols, err=%v", err)
+		return
+	}
+	n.symbols = info
+}
+
+// names a cpu address, in the PRG bank mapped there now
+func (n *nes) label(addr uint16) (string, bool) {
+	if n.symbols == nil {
+		return "", false
+	}
+	return n.symbols.Label(addr, n.cart.PrgFileOffset(addr))
+}
+
+// Symbols is nil unless there are symbol files next to the rom
+func (n *nes) Symbols() *symbols.DebugInfo {
+	return n.symbols
+}
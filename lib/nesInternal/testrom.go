@@ -0,0 +1,115 @@
+package nesInternal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// blargg's test roms report through the PRG RAM
+// https://github.com/christopherpow/nes-test-roms/blob/master/readme.txt
+//
+// $6000: status, $80 while running, $81 when the reset button should be
+// pressed (at least 100 ms later) and the result code when done
+// $6001-$6003: $DE $B0 $61 signature, the status is only valid once it's set
+// $6004-$7FFF: null terminated text output
+const (
+	testRomStatus    = 0x6000
+	testRomSignature = 0x6001
+	testRomText      = 0x6004
+
+	testRomRunning    = 0x80
+	testRomNeedsReset = 0x81
+)
+
+var testRomMagic = [3]uint8{0xDE, 0xB0, 0x61}
+
+// how long the reset button is held, in emulated time
+const testRomResetDelay = 100 * time.Millisecond
+
+// TestRomResult is the final status of a test rom, 0 is a pass and anything
+// else is an error code particular to the test
+type TestRomResult struct {
+	Status  uint8
+	Message string
+}
+
+func (r TestRomResult) Passed() bool {
+	return r.Status == 0
+}
+
+func (r TestRomResult) String() string {
+	if r.Passed() {
+		return fmt.Sprintf("passed: %s", r.Message)
+	}
+	return fmt.Sprintf("failed with %d: %s", r.Status, r.Message)
+}
+
+// RunTestRom runs a test rom headless until it reports its result, or until
+// timeout of emulated time elapses
+func RunTestRom(path string, timeout time.Duration) (TestRomResult, error) {
+	g := NewNesInternal()
+	if err := g.SetOptions(CartPath(path), Headless(true)); err != nil {
+		return TestRomResult{}, err
+	}
+	g.Init()
+	return g.nes.runTestRom(timeout)
+}
+
+func (n *nes) peekTestRom(addr uint16) uint8 {
+	return n.cart.Mapper.Read8(addr)
+}
+
+func (n *nes) testRomText() string {
+	var text strings.Builder
+	for addr := uint16(testRomText); addr < 0x8000; addr++ {
+		c := n.peekTestRom(addr)
+		if c == 0 {
+			break
+		}
+		text.WriteByte(c)
+	}
+	return strings.TrimSpace(text.String())
+}
+
+func (n *nes) runTestRom(timeout time.Duration) (TestRomResult, error) {
+	const frame = time.Second / 60
+
+	// a stale status could be left in a battery backed RAM, the result is
+	// only taken after the test is seen running
+	running := false
+	var resetAt time.Duration
+
+	for elapsed := time.Duration(0); elapsed < timeout; elapsed += frame {
+		n.Step(frame.Seconds())
+
+		signed := true
+		for i, b := range testRomMagic {
+			if n.peekTestRom(testRomSignature+uint16(i)) != b {
+				signed = false
+			}
+		}
+		if !signed {
+			continue
+		}
+
+		switch status := n.peekTestRom(testRomStatus); {
+		case status == testRomRunning:
+			running = true
+			resetAt = 0
+		case status == testRomNeedsReset:
+			running = true
+			if resetAt == 0 {
+				resetAt = elapsed + testRomResetDelay
+			} else if elapsed >= resetAt {
+				n.reset()
+				// wait for the test to clear the request
+				resetAt = timeout
+			}
+		case running:
+			return TestRomResult{status, n.testRomText()}, nil
+		}
+	}
+
+	return TestRomResult{}, fmt.Errorf("timed out after %v: %s", timeout, n.testRomText())
+}
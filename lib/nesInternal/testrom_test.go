@@ -0,0 +1,38 @@
+package nesInternal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTestRoms runs every rom under dir as a subtest
+func runTestRoms(t *testing.T, dir string, timeout time.Duration) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".nes") {
+			return err
+		}
+		name, _ := filepath.Rel(dir, path)
+		t.Run(name, func(t *testing.T) {
+			result, err := RunTestRom(path, timeout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Passed() {
+				t.Error(result)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blargg's roms which use the $6000 protocol, eg: instr_test-v5, ppu_vbl_nmi,
+// apu_test and mmc3_test_2
+func Test_BlarggRoms(t *testing.T) {
+	runTestRoms(t, testRom(t, "blargg"), time.Minute)
+}
@@ -0,0 +1,62 @@
+package nesInternal
+
+import (
+	"bufio"
+	"log"
+	"os"
+
+	"github.com/tiagolobocastro/gones/lib/cpu"
+)
+
+// TraceOptions of the instruction trace, see the Trace option
+type TraceOptions struct {
+	// the file the trace is written to, no trace if empty
+	Path   string
+	Fields cpu.TraceFields
+	Filter cpu.TraceFilter
+	// keeps the last Ring instructions only, which are written when the cpu
+	// jams on an invalid opcode or the emulation panics
+	Ring int
+}
+
+// the trace replaces the one of the verbose logs
+func (n *nes) initTracer() {
+	n.tracer = nil
+	if n.traceOptions.Path == "" {
+		return
+	}
+	f, err := os.Create(n.traceOptions.Path)
+	if err != nil {
+		log.Printf("Failed to create the trace, err=%v", err)
+		return
+	}
+	n.traceFile = f
+	n.traceWriter = bufio.NewWriter(f)
+
+	n.tracer = cpu.NewTracer(n.traceWriter, n.traceOptions.Fields, n.traceOptions.Filter)
+	n.tracer.Banks(n.prgBank)
+	n.tracer.Ring(n.traceOptions.Ring)
+	n.cpu.TraceSources((&cpuMapper{n}).Peek8, n.ppu.Position)
+	if n.symbols != nil {
+		n.cpu.TraceLabels(n.label)
+	}
+	n.cpu.SetTracer(n.tracer)
+}
+
+// Tracer is nil unless the Trace option is set
+func (n *nes) Tracer() *cpu.Tracer {
+	return n.tracer
+}
+
+func (n *nes) closeTracer() {
+	if n.tracer == nil {
+		return
+	}
+	err := n.traceWriter.Flush()
+	if closeErr := n.traceFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("Failed to save the trace, err=%v", err)
+	}
+}
@@ -131,10 +131,12 @@ func (p *Ppu) startVBlank() {
 		p.frameBuffer.FrameIndex ^= 1
 	}
 
-	select {
-	case p.frameBuffer.FrameUpdated <- true:
-		// todo: control "vsync" channel
-		//default:
+	if p.frameBuffer.FrameUpdated != nil {
+		select {
+		case p.frameBuffer.FrameUpdated <- true:
+			// todo: control "vsync" channel
+			//default:
+		}
 	}
 
 	p.setVBlank()
@@ -341,6 +343,7 @@ func (p *Ppu) execOldPpu() {
 		if p.scanLine > 260 {
 			p.clearOAM()
 			p.scanLine = -1
+			p.frames++
 			p.stopVBlank()
 		} else if p.scanLine == 241 {
 			p.startVBlank()
@@ -205,6 +205,7 @@ func (p *Ppu) exec() {
 		if p.scanLine > 260 {
 			p.clearOAM()
 			p.scanLine = -1
+			p.frames++
 		}
 	} else if p.cycle == 1 {
 		if vBlankLn {
@@ -227,6 +228,42 @@ func (p *Ppu) exec() {
 	}
 }
 
+// Position is the scanline (-1 being the pre-render one) and the dot
+func (p *Ppu) Position() (scanline, dot int) {
+	return p.scanLine, p.cycle
+}
+
+// PeekRegister reads a register as Read8 does but without side effects, eg:
+// the vblank flag of PPUSTATUS stays set and PPUDATA returns the read buffer
+// without moving the VRAM address
+func (p *Ppu) PeekRegister(addr uint16) uint8 {
+	switch 0x2000 + addr%8 {
+	case 0x2002:
+		return p.regs[PPUSTATUS].Val
+	case 0x2004:
+		return p.rOAM.Read8(uint16(p.regs[OAMADDR].Val))
+	case 0x2007:
+		if p.vRAM.Val%0x4000 < 0x3F00 {
+			return p.vRAMBuffer
+		}
+		return p.Palette.Read8(p.vRAM.Val % 32)
+	}
+	return 0
+}
+
+// PeekOAM reads the primary OAM, without moving OAMADDR
+func (p *Ppu) PeekOAM(addr uint8) uint8 {
+	return p.rOAM.Read8(uint16(addr))
+}
+func (p *Ppu) PokeOAM(addr uint8, val uint8) {
+	p.rOAM.Write8(uint16(addr), val)
+}
+
+// Frame counts the frames since power up
+func (p *Ppu) Frame() int {
+	return p.frames
+}
+
 func (p *Ppu) A12OutputHigh() bool {
 	visibleFrame := p.scanLine >= 0 && p.scanLine < 240
 
@@ -0,0 +1,329 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ld65 debug files, written with --dbgfile, eg:
+//
+// version	major=2,minor=0
+// file	id=0,name="main.s",size=1024,mtime=0x5F5E1000,mod=0
+// line	id=3,file=0,line=12,span=2
+// seg	id=0,name="CODE",start=0x00C000,size=0x0016,addrsize=absolute,type=ro,oname="game.nes",ooffs=16
+// span	id=2,seg=0,start=3,size=3
+// scope	id=0,name="",mod=0,size=22
+// sym	id=0,name="reset",addrsize=absolute,scope=0,def=3,val=0xC000,seg=0,type=lab
+//
+// https://cc65.github.io/doc/debugging.html
+
+// Line is the source of the code at an address
+type Line struct {
+	Addr uint16
+	Size int
+	// offset of the code in the rom file, -1 if it's not in there, eg: RAM
+	FileOffset int
+	File       string
+	Line       int
+}
+
+// Symbol is a label, the scopes are joined with ::
+type Symbol struct {
+	Name       string
+	Addr       uint16
+	FileOffset int
+}
+
+type DebugInfo struct {
+	// the source files, as absolute paths when they can be found
+	Files   []string
+	Lines   []Line
+	Symbols []Symbol
+
+	// the names of the rom file offsets, see index
+	offsets map[int]string
+}
+
+// index sorts the lines and the symbols by address, the loaders call it once
+// they're done
+func (d *DebugInfo) index() {
+	sort.Slice(d.Lines, func(i, j int) bool {
+		a, b := d.Lines[i], d.Lines[j]
+		if a.Addr != b.Addr {
+			return a.Addr < b.Addr
+		}
+		return a.FileOffset < b.FileOffset
+	})
+	sort.SliceStable(d.Symbols, func(i, j int) bool {
+		return d.Symbols[i].Addr < d.Symbols[j].Addr
+	})
+	d.offsets = make(map[int]string)
+	for _, s := range d.Symbols {
+		if _, ok := d.offsets[s.FileOffset]; !ok && s.FileOffset >= 0 {
+			d.offsets[s.FileOffset] = s.Name
+		}
+	}
+}
+
+// merge adds the files, lines and symbols of o
+func (d *DebugInfo) merge(o *DebugInfo) {
+	d.Files = append(d.Files, o.Files...)
+	d.Lines = append(d.Lines, o.Lines...)
+	d.Symbols = append(d.Symbols, o.Symbols...)
+	d.index()
+}
+
+type dbgRecord map[string]string
+
+func (r dbgRecord) int(key string) int {
+	v, err := strconv.ParseInt(r[key], 0, 64)
+	if err != nil {
+		return -1
+	}
+	return int(v)
+}
+
+// splits the key=value pairs, the strings are quoted and may have commas
+func parseDbgRecord(s string) (dbgRecord, error) {
+	r := dbgRecord{}
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("missing = in %q", s)
+		}
+		key, value := s[:eq], s[eq+1:]
+		end := strings.IndexByte(value, ',')
+		if strings.HasPrefix(value, "\"") {
+			unquoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return nil, err
+			}
+			end = len(unquoted)
+			if value, err = strconv.Unquote(unquoted); err != nil {
+				return nil, err
+			}
+			s = s[eq+1+end:]
+		} else if end >= 0 {
+			value, s = value[:end], value[end:]
+		} else {
+			s = ""
+		}
+		r[key] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return r, nil
+}
+
+// LoadCa65 reads an ld65 debug file, the relative source paths are resolved
+// from its directory
+func LoadCa65(path string) (*DebugInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records := map[string]map[int]dbgRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		r, err := parseDbgRecord(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		if fields[0] == "version" && r["major"] != "2" {
+			return nil, fmt.Errorf("%s: unsupported version %s.%s", path, r["major"], r["minor"])
+		}
+		if records[fields[0]] == nil {
+			records[fields[0]] = map[int]dbgRecord{}
+		}
+		records[fields[0]][r.int("id")] = r
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	info := &DebugInfo{}
+	files := map[int]string{}
+	for id, r := range records["file"] {
+		name := r["name"]
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(filepath.Dir(path), name)
+		}
+		files[id] = filepath.Clean(name)
+		info.Files = append(info.Files, files[id])
+	}
+	sort.Strings(info.Files)
+
+	// the address and the rom offset of a segment offset
+	locate := func(seg int, offset int) (uint16, int) {
+		s := records["seg"][seg]
+		fileOffset := -1
+		if s.int("ooffs") >= 0 {
+			fileOffset = s.int("ooffs") + offset
+		}
+		return uint16(s.int("start") + offset), fileOffset
+	}
+
+	for _, r := range records["line"] {
+		// 1 is for C sources and 2 for the macro expansions, whose lines are
+		// in the macro definition
+		if r.int("type") > 0 || r["span"] == "" {
+			continue
+		}
+		for _, id := range strings.Split(r["span"], "+") {
+			spanId, _ := strconv.Atoi(id)
+			span, ok := records["span"][spanId]
+			if !ok {
+				continue
+			}
+			addr, fileOffset := locate(span.int("seg"), span.int("start"))
+			info.Lines = append(info.Lines, Line{
+				Addr:       addr,
+				Size:       span.int("size"),
+				FileOffset: fileOffset,
+				File:       files[r.int("file")],
+				Line:       r.int("line"),
+			})
+		}
+	}
+
+	scopeName := func(id int) string {
+		var names []string
+		for s, ok := records["scope"][id]; ok && s["name"] != ""; s, ok = records["scope"][s.int("parent")] {
+			names = append([]string{s["name"]}, names...)
+		}
+		return strings.Join(names, "::")
+	}
+	for _, r := range records["sym"] {
+		if r["type"] != "lab" || r["val"] == "" {
+			continue
+		}
+		name := r["name"]
+		if scope := scopeName(r.int("scope")); scope != "" {
+			name = scope + "::" + name
+		}
+		sym := Symbol{Name: name, Addr: uint16(r.int("val")), FileOffset: -1}
+		if seg := r.int("seg"); seg >= 0 {
+			if s := records["seg"][seg]; s.int("ooffs") >= 0 {
+				sym.FileOffset = s.int("ooffs") + r.int("val") - s.int("start")
+			}
+		}
+		info.Symbols = append(info.Symbols, sym)
+	}
+	info.index()
+
+	return info, nil
+}
+
+// LineAt finds the source of the instruction at addr, offset is where addr
+// is in the rom file or -1 if not known. With banked code only the line at
+// that offset matches, or one which isn't in the rom, eg: RAM. Without an
+// offset the lowest one wins
+func (d *DebugInfo) LineAt(addr uint16, offset int) (Line, bool) {
+	line, found := Line{}, false
+	i := sort.Search(len(d.Lines), func(i int) bool { return d.Lines[i].Addr >= addr })
+	for ; i < len(d.Lines) && d.Lines[i].Addr == addr; i++ {
+		l := d.Lines[i]
+		switch {
+		case offset >= 0 && l.FileOffset == offset:
+			return l, true
+		case !found && (offset < 0 || l.FileOffset < 0):
+			line, found = l, true
+		}
+	}
+	return line, found
+}
+
+// SourceFile finds the source file matching a path, which may be relative
+// or come from a different machine
+func (d *DebugInfo) SourceFile(path string) (string, bool) {
+	path = filepath.Clean(path)
+	for _, f := range d.Files {
+		if f == path {
+			return f, true
+		}
+	}
+	for _, f := range d.Files {
+		if filepath.Base(f) == filepath.Base(path) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+// Addresses returns the code of the first line with any code, at or after
+// line, in every bank it was assembled in
+func (d *DebugInfo) Addresses(file string, line int) ([]Line, int) {
+	found := -1
+	for _, l := range d.Lines {
+		if l.File == file && l.Line >= line && (found < 0 || l.Line < found) {
+			found = l.Line
+		}
+	}
+	var lines []Line
+	for _, l := range d.Lines {
+		if l.File == file && l.Line == found {
+			lines = append(lines, l)
+		}
+	}
+	return lines, found
+}
+
+// Label returns the symbol at addr, offset is where addr is in the rom file
+// or -1 if not known. With banked code the symbol at that offset wins, even
+// if it was given another address, over the ones which aren't in the rom,
+// eg: RAM
+func (d *DebugInfo) Label(addr uint16, offset int) (string, bool) {
+	if name, ok := d.offsets[offset]; ok && offset >= 0 {
+		return name, true
+	}
+	name, found := "", false
+	i := sort.Search(len(d.Symbols), func(i int) bool { return d.Symbols[i].Addr >= addr })
+	for ; i < len(d.Symbols) && d.Symbols[i].Addr == addr; i++ {
+		s := d.Symbols[i]
+		switch {
+		case offset >= 0 && s.FileOffset == offset:
+			return s.Name, true
+		case !found && (offset < 0 || s.FileOffset < 0):
+			name, found = s.Name, true
+		}
+	}
+	return name, found
+}
+
+// Nearest returns the symbol at or before addr, eg: the routine the
+// address is in
+func (d *DebugInfo) Nearest(addr uint16) (string, bool) {
+	i := sort.Search(len(d.Symbols), func(i int) bool { return d.Symbols[i].Addr > addr })
+	if i == 0 {
+		return "", false
+	}
+	return d.Symbols[i-1].Name, true
+}
+
+// Lookup returns the address of a symbol
+func (d *DebugInfo) Lookup(name string) (uint16, bool) {
+	s, ok := d.Find(name)
+	return s.Addr, ok
+}
+
+// Find returns a symbol by name, with its rom file offset, eg: for a
+// breakpoint on a banked routine
+func (d *DebugInfo) Find(name string) (Symbol, bool) {
+	for _, s := range d.Symbols {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Symbol{}, false
+}
@@ -0,0 +1,108 @@
+package symbols
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDbgRecord(t *testing.T) {
+	r, err := parseDbgRecord(`id=3,name="a,b \"c\"",val=0x00C000,span=1+2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{"id": "3", "name": `a,b "c"`, "val": "0x00C000", "span": "1+2"} {
+		if r[key] != expected {
+			t.Errorf("%s=%q, expected %q", key, r[key], expected)
+		}
+	}
+	if r.int("val") != 0xC000 || r.int("missing") != -1 {
+		t.Errorf("val=%d, missing=%d", r.int("val"), r.int("missing"))
+	}
+}
+
+func TestLoadCa65(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.dbg")
+	err := ioutil.WriteFile(path, []byte(`version	major=2,minor=0
+file	id=0,name="src/main.s",size=100,mtime=0x00000000,mod=0
+seg	id=0,name="ZEROPAGE",start=0x000000,size=0x0002,addrsize=zeropage,type=rw
+seg	id=1,name="CODE",start=0x00C000,size=0x0010,addrsize=absolute,type=ro,oname="game.nes",ooffs=16
+span	id=0,seg=1,start=4,size=3
+span	id=1,seg=1,start=7,size=1
+line	id=0,file=0,line=12,span=0
+line	id=1,file=0,line=13,span=1
+line	id=2,file=0,line=30,type=2,span=1
+scope	id=0,name="",mod=0,size=16
+scope	id=1,name="player",mod=0,type=scope,size=4,parent=0
+sym	id=0,name="update",addrsize=absolute,scope=1,def=1,val=0xC004,seg=1,type=lab
+sym	id=1,name="x_pos",addrsize=zeropage,scope=0,def=2,val=0x00,seg=0,type=lab
+sym	id=2,name="SPEED",addrsize=zeropage,scope=0,def=3,val=0x02,type=equ
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := LoadCa65(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(filepath.Dir(path), "src", "main.s")
+	if len(info.Lines) != 2 {
+		t.Fatalf("%d lines, the macro line should be skipped", len(info.Lines))
+	}
+	if l, ok := info.LineAt(0xC007, -1); !ok || l.File != main || l.Line != 13 || l.FileOffset != 16+7 {
+		t.Errorf("$C007 is at %+v", l)
+	}
+	if f, ok := info.SourceFile("/elsewhere/main.s"); !ok || f != main {
+		t.Errorf("main.s is %q", f)
+	}
+	if lines, line := info.Addresses(main, 5); len(lines) != 1 || lines[0].Addr != 0xC004 || lines[0].FileOffset != 16+4 || line != 12 {
+		t.Errorf("line 5 moved to %d at %+v", line, lines)
+	}
+	if name, ok := info.Label(0xC004, 16+4); !ok || name != "player::update" {
+		t.Errorf("$C004 is %q", name)
+	}
+	if _, ok := info.Label(0xC004, 0x4010); ok {
+		t.Error("$C004 is unnamed in other banks")
+	}
+	if name, _ := info.Nearest(0xC006); name != "player::update" {
+		t.Errorf("$C006 is in %q", name)
+	}
+	if addr, ok := info.Lookup("x_pos"); !ok || addr != 0 {
+		t.Errorf("x_pos is at $%04X", addr)
+	}
+	if _, ok := info.Lookup("SPEED"); ok {
+		t.Error("the constants are not labels")
+	}
+}
+
+// the same address in two PRG banks, and in RAM
+func TestLineAtBanks(t *testing.T) {
+	info := &DebugInfo{Lines: []Line{
+		{Addr: 0x8000, FileOffset: 0x4010, File: "bank1.s", Line: 1},
+		{Addr: 0x8000, FileOffset: 0x0010, File: "bank0.s", Line: 1},
+		{Addr: 0x0300, FileOffset: -1, File: "ram.s", Line: 1},
+	}}
+	info.index()
+
+	for _, test := range []struct {
+		addr   uint16
+		offset int
+		file   string
+	}{
+		{0x8000, 0x4010, "bank1.s"},
+		{0x8000, 0x0010, "bank0.s"},
+		{0x8000, -1, "bank0.s"},
+		{0x8000, 0x8010, ""},
+		{0x0300, -1, "ram.s"},
+		{0x0300, 0x0310, "ram.s"},
+	} {
+		l, ok := info.LineAt(test.addr, test.offset)
+		if ok != (test.file != "") || l.File != test.file {
+			t.Errorf("$%04X at offset %d is in %q, expected %q", test.addr, test.offset, l.File, test.file)
+		}
+	}
+	if lines, _ := info.Addresses("bank1.s", 1); len(lines) != 1 || lines[0].FileOffset != 0x4010 {
+		t.Errorf("bank1.s line 1 is at %+v", lines)
+	}
+}
@@ -0,0 +1,73 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FCEUX name lists, one per 16 KB PRG bank and one for the rest of the cpu
+// space, eg: game.nes.ram.nl, game.nes.0.nl, game.nes.1.nl...
+//
+// $0200/40#oam#sprite buffer
+// $C000#reset#
+//
+// The /size marks arrays, only their first byte is named
+
+// LoadFceux reads a name list, bank is the 16 KB PRG bank it names or -1
+// for the ram list, prgStart is where the PRG-ROM starts in the rom file
+func LoadFceux(path string, bank int, prgStart int) (*DebugInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &DebugInfo{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "$") {
+			continue
+		}
+		fields := strings.SplitN(line[1:], "#", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: missing # in %q", path, n, line)
+		}
+		addr, err := strconv.ParseUint(strings.SplitN(fields[0], "/", 2)[0], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad address %q", path, n, fields[0])
+		}
+		if fields[1] == "" {
+			// only a comment
+			continue
+		}
+
+		sym := Symbol{Name: fields[1], Addr: uint16(addr), FileOffset: -1}
+		if bank >= 0 && addr >= 0x8000 {
+			sym.FileOffset = prgStart + bank*0x4000 + int(addr%0x4000)
+		}
+		info.Symbols = append(info.Symbols, sym)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	info.index()
+	return info, nil
+}
+
+// the bank of a name list from its name, -1 for the ram list
+func fceuxBank(path string) (int, error) {
+	ext := filepath.Ext(strings.TrimSuffix(path, ".nl"))
+	if ext == ".ram" {
+		return -1, nil
+	}
+	bank, err := strconv.ParseUint(strings.TrimPrefix(ext, "."), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a ram or a bank name list", path)
+	}
+	return int(bank), nil
+}
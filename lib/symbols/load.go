@@ -0,0 +1,78 @@
+package symbols
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiagolobocastro/gones/lib/mappers"
+)
+
+// Load reads all the symbol files next to a rom, eg: for game.nes the ld65
+// game.dbg, the Mesen game.mlb and the FCEUX game.nes.ram.nl,
+// game.nes.0.nl... Returns nil if there are none
+func Load(rom string) (*DebugInfo, error) {
+	base := strings.TrimSuffix(rom, filepath.Ext(rom))
+	paths := []string{base + ".dbg", base + ".mlb"}
+	lists, err := filepath.Glob(globEscape(rom) + ".*.nl")
+	if err != nil {
+		return nil, err
+	}
+	paths = append(paths, lists...)
+
+	var info *DebugInfo
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		found, err := LoadFile(path, rom)
+		if err != nil {
+			return nil, err
+		}
+		if info == nil {
+			info = found
+		} else {
+			info.merge(found)
+		}
+	}
+	return info, nil
+}
+
+// LoadFile reads a symbol file of any of the formats, by its extension. The
+// rom locates the PRG-ROM labels of the Mesen and FCEUX files
+func LoadFile(path string, rom string) (*DebugInfo, error) {
+	switch filepath.Ext(path) {
+	case ".dbg":
+		return LoadCa65(path)
+	case ".mlb":
+		prg, prgStart, err := mappers.ReadPrgRom(rom)
+		if err != nil {
+			return nil, err
+		}
+		return LoadMesen(path, prgStart, len(prg))
+	case ".nl":
+		bank, err := fceuxBank(path)
+		if err != nil {
+			return nil, err
+		}
+		_, prgStart, err := mappers.ReadPrgRom(rom)
+		if err != nil {
+			return nil, err
+		}
+		return LoadFceux(path, bank, prgStart)
+	}
+	return nil, fmt.Errorf("unknown symbol file %s, expected a .dbg, .mlb or .nl", path)
+}
+
+// the rom name may have glob characters, eg: "game [!].nes"
+func globEscape(path string) string {
+	var b strings.Builder
+	for _, c := range path {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package symbols
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	rom := filepath.Join(dir, "game [!].nes")
+	// 4 PRG banks of 16 KB
+	header := []byte{'N', 'E', 'S', 0x1A, 4, 0, 0x20, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	files := map[string]string{
+		rom: string(append(header, make([]byte, 4*0x4000)...)),
+		filepath.Join(dir, "game [!].mlb"): "NesPrgRom:4010:bank_routine:in the switched bank\n" +
+			"P:FFF0:fixed\n" +
+			"R:0010-0011:ptr\n" +
+			"W:0100:save_slot\n" +
+			"NesPrgRom:0020::only a comment\n",
+		rom + ".ram.nl": "$0300/40#buffer#sprites\n$0010#zp_ptr#\n",
+		rom + ".1.nl":   "$8000#bank1#\n$8000##only a comment\n",
+		rom + ".3.nl":   "$FFFA#vectors#\n",
+		rom + ".bak":    "ignored\n",
+	}
+	for path, data := range files {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := Load(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Symbols) != 8 {
+		t.Fatalf("%d symbols, expected 8", len(info.Symbols))
+	}
+	for _, test := range []struct {
+		addr   uint16
+		offset int
+		name   string
+	}{
+		// Mesen guesses the bank 1 is at $8000
+		{0x8010, 16 + 0x4010, "bank_routine"},
+		// but it could be mapped anywhere
+		{0xA010, 16 + 0x4010, "bank_routine"},
+		{0xFFF0, 16 + 0xFFF0, "fixed"},
+		{0x6100, -1, "save_slot"},
+		{0x0300, -1, "buffer"},
+		{0x8000, 16 + 0x4000, "bank1"},
+		{0xFFFA, 16 + 0xFFFA, "vectors"},
+	} {
+		if name, ok := info.Label(test.addr, test.offset); !ok || name != test.name {
+			t.Errorf("$%04X at %X is %q, expected %s", test.addr, test.offset, name, test.name)
+		}
+	}
+	if _, ok := info.Label(0x8000, 16); ok {
+		t.Error("$8000 is unnamed in the bank 0")
+	}
+	if s, ok := info.Find("bank_routine"); !ok || s.Addr != 0x8010 || s.FileOffset != 16+0x4010 {
+		t.Errorf("bank_routine is %+v", s)
+	}
+	if s, ok := info.Find("fixed"); !ok || s.Addr != 0xFFF0 {
+		t.Errorf("fixed is %+v", s)
+	}
+
+	if _, err := LoadFile(rom+".text.nl", rom); err == nil {
+		t.Error("expected an error for a name list which is not for a bank")
+	}
+	empty := filepath.Join(t.TempDir(), "empty.nes")
+	if info, err := Load(empty); info != nil || err != nil {
+		t.Errorf("loaded %v, %v without symbol files", info, err)
+	}
+}
@@ -0,0 +1,86 @@
+package symbols
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Mesen label files, the address is an offset in the memory of the type,
+// with the Mesen 2 type names or the older letters, eg:
+//
+// NesPrgRom:0010:reset:the entry point
+// NesInternalRam:0200-023F:oam
+// R:0010:x_pos
+// P:4010-4011:table
+//
+// The ranges name their first byte
+var mesenTypes = map[string]string{
+	"P": "NesPrgRom", "R": "NesInternalRam", "S": "NesSaveRam", "W": "NesWorkRam", "G": "NesMemory",
+}
+
+// LoadMesen reads a label file, prgStart and prgSize locate the PRG-ROM in
+// the rom file. The PRG-ROM labels are given the address of their bank in
+// the usual layout, with the last 16 KB at $C000 and the others at $8000
+func LoadMesen(path string, prgStart int, prgSize int) (*DebugInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &DebugInfo{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 4)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%s:%d: missing : in %q", path, n, line)
+		}
+		kind := fields[0]
+		if name, ok := mesenTypes[kind]; ok {
+			kind = name
+		}
+		offset, err := strconv.ParseUint(strings.SplitN(fields[1], "-", 2)[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad address %q", path, n, fields[1])
+		}
+		if fields[2] == "" {
+			// only a comment
+			continue
+		}
+
+		sym := Symbol{Name: fields[2], FileOffset: -1}
+		switch kind {
+		case "NesPrgRom":
+			if int(offset) >= prgSize {
+				return nil, fmt.Errorf("%s:%d: $%X is past the end of the PRG-ROM", path, n, offset)
+			}
+			sym.FileOffset = prgStart + int(offset)
+			sym.Addr = 0x8000 + uint16(offset%0x4000)
+			if int(offset)/0x4000 == (prgSize-1)/0x4000 {
+				sym.Addr += 0x4000
+			}
+		case "NesInternalRam":
+			sym.Addr = uint16(offset)
+		case "NesSaveRam", "NesWorkRam":
+			sym.Addr = 0x6000 + uint16(offset%0x2000)
+		case "NesMemory":
+			sym.Addr = uint16(offset)
+		default:
+			// eg: the CHR and the PPU memory
+			continue
+		}
+		info.Symbols = append(info.Symbols, sym)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	info.index()
+	return info, nil
+}
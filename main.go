@@ -4,8 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	gones "github.com/tiagolobocastro/gones/lib"
+	"github.com/tiagolobocastro/gones/lib/cpu"
 	"github.com/tiagolobocastro/gones/lib/speakers"
 )
 
@@ -21,7 +24,38 @@ func validateINesPath(romPath string) error {
 	return nil
 }
 
+// parses an address range, eg: $8000-$BFFF
+func parseRange(s string) (uint16, uint16, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%q is not a range, eg: $8000-$BFFF", s)
+	}
+	var addrs [2]uint16
+	for i, part := range parts {
+		addr, err := strconv.ParseUint(strings.Replace(strings.TrimSpace(part), "$", "0x", 1), 0, 16)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid address %q", part)
+		}
+		addrs[i] = uint16(addr)
+	}
+	if addrs[1] < addrs[0] {
+		return 0, 0, fmt.Errorf("%q ends before it starts", s)
+	}
+	return addrs[0], addrs[1], nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "testrom":
+			os.Exit(testRoms(os.Args[2:]))
+		case "dap":
+			os.Exit(debugAdapter(os.Args[2:]))
+		case "disasm":
+			os.Exit(disassemble(os.Args[2:]))
+		}
+	}
+
 	romPath := ""
 	positionalArgs := 0
 	if len(os.Args) > 1 && os.Args[1][0] != '-' {
@@ -35,11 +69,39 @@ func main() {
 	verbose := flag.Bool("verbose", false, "verbose logs (debug only)")
 	freeRun := flag.Bool("freerun", false, "run as fast as possible with double buffered sync (debug only)")
 	spriteLimit := flag.Bool("spritelimit", false, "limit number of sprites per scanline to 8 (true to the NES)")
+	cdlPath := flag.String("cdl", "", "log the PRG and CHR usage to an FCEUX .cdl file, saved on exit")
+	profilePath := flag.String("profile", "", "profile the cpu routines, the top ones are printed on exit and a pprof profile is saved")
+	tracePath := flag.String("trace", "", "trace the instructions to a file")
+	traceFields := flag.String("tracefields", "regs,ppu,cycles", "what the trace shows after the disassembly: regs, ppu, cycles and bank")
+	traceRange := flag.String("tracerange", "", "only trace the instructions in an address range, eg: $8000-$BFFF")
+	traceBank := flag.Int("tracebank", -1, "only trace the instructions in an 8 KB PRG-ROM bank")
+	traceNmi := flag.Bool("tracenmi", false, "only trace the instructions of the NMI handler")
+	traceRing := flag.Int("tracering", 0, "only keep the last instructions, written to the trace when the cpu jams or panics")
 	if err := flag.CommandLine.Parse(os.Args[positionalArgs+1:]); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Failed to parse the commandline parameters, err=%v\n", err)
 		return
 	}
 
+	traceOptions := gones.TraceOptions{
+		Path:   *tracePath,
+		Filter: cpu.TraceFilter{Bank: *traceBank, NmiOnly: *traceNmi},
+		Ring:   *traceRing,
+	}
+	fields, err := cpu.ParseTraceFields(*traceFields)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Invalid trace fields, err=%v\n", err)
+		return
+	}
+	traceOptions.Fields = fields
+	if *traceRange != "" {
+		from, to, err := parseRange(*traceRange)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Invalid trace range, err=%v\n", err)
+			return
+		}
+		traceOptions.Filter.From, traceOptions.Filter.To = from, to
+	}
+
 	if err := validateINesPath(romPath); err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Rom image path is not valid? err=%v\n", err)
 		return
@@ -53,6 +115,9 @@ func main() {
 		gones.AudioLibrary(*audioLib),
 		gones.AudioLogging(*logAudio),
 		gones.SpriteLimit(*spriteLimit),
+		gones.CodeDataLog(*cdlPath),
+		gones.Profile(*profilePath),
+		gones.Trace(traceOptions),
 	)
 
 	nes.Run()
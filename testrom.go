@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gones "github.com/tiagolobocastro/gones/lib"
+)
+
+// testRoms runs blargg style test roms headless, the paths can be roms or
+// directories with roms. Returns the exit code, 1 if any test failed
+func testRoms(args []string) int {
+	flags := flag.NewFlagSet("testrom", flag.ExitOnError)
+	timeout := flags.Duration("timeout", time.Minute, "emulated time after which a test rom fails")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(flags.Output(), "Usage of gones testrom: [flags] rom|dir...\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	var roms []string
+	for _, path := range flags.Args() {
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".nes") {
+				roms = append(roms, path)
+			}
+			return err
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Failed to find the test roms, err=%v\n", err)
+			return 1
+		}
+	}
+	if len(roms) == 0 {
+		flags.Usage()
+		return 1
+	}
+
+	failed := 0
+	for _, rom := range roms {
+		result, err := gones.RunTestRom(rom, *timeout)
+		switch {
+		case err != nil:
+			fmt.Printf("FAIL %s: %v\n", rom, err)
+			failed++
+		case !result.Passed():
+			fmt.Printf("FAIL %s: %v\n", rom, result)
+			failed++
+		default:
+			fmt.Printf("PASS %s\n", rom)
+		}
+	}
+
+	fmt.Printf("%d/%d test roms passed\n", len(roms)-failed, len(roms))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}